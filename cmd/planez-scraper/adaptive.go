@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// adaptiveTransport limits how many requests are in flight to each host at
+// once, tuning the limit to how well the host is keeping up, for
+// -adaptive-concurrency. Each host starts with one request in flight at a
+// time. Each full round of responses that show the host keeping up, as many
+// as the limit, raises the limit by one, up to max. A 429, a 5xx, or a
+// timeout halves it, as TCP does with its congestion window.
+//
+// Like hostSlotsTransport, a request holds its place from when it starts
+// until its body is closed.
+type adaptiveTransport struct {
+	next http.RoundTripper
+	max  int
+
+	mu      sync.Mutex
+	windows map[string]*adaptiveWindow
+}
+
+func newAdaptiveTransport(next http.RoundTripper, n int) *adaptiveTransport {
+	return &adaptiveTransport{next: next, max: n, windows: make(map[string]*adaptiveWindow)}
+}
+
+// window returns the window for requests to host, starting it at one.
+func (t *adaptiveTransport) window(host string) *adaptiveWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[host]
+	if !ok {
+		w = &adaptiveWindow{host: host, max: t.max, limit: 1, changed: make(chan struct{})}
+		t.windows[host] = w
+	}
+
+	return w
+}
+
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := t.window(req.URL.Host)
+	epoch, err := w.acquire(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(req)
+	load := loadShownBy(req, res, err)
+	if err != nil {
+		w.release(req.Context(), epoch, load)
+		return nil, err
+	}
+
+	res.Body = &adaptiveBody{ReadCloser: res.Body, release: func() { w.release(req.Context(), epoch, load) }}
+	return res, nil
+}
+
+// hostLoad is what the outcome of a request shows about how well its host is
+// keeping up.
+type hostLoad int
+
+const (
+	// loadUnknown is for requests that failed for other reasons, such as
+	// being canceled or refused, which leave the limit as it is.
+	loadUnknown hostLoad = iota
+	loadKeepingUp
+	loadOverloaded
+)
+
+// loadShownBy returns what a response, or the error in place of one, shows
+// about the load on the host. 429s, 5xx responses, and timeouts are signs
+// that it is taking more requests than it can handle.
+func loadShownBy(req *http.Request, res *http.Response, err error) hostLoad {
+	if err != nil {
+		var netErr net.Error
+		if req.Context().Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+			return loadOverloaded
+		}
+
+		return loadUnknown
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		return loadOverloaded
+	}
+
+	return loadKeepingUp
+}
+
+// adaptiveWindow is the limit on requests in flight to one host.
+type adaptiveWindow struct {
+	host string
+	max  int
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+
+	// keptUp counts the requests that showed the host keeping up since the
+	// limit last changed.
+	keptUp int
+
+	// epoch counts the times the limit has been halved. A request that
+	// started before the last halving doesn't halve it again, so that a
+	// burst of failures among the requests already in flight only backs
+	// off once.
+	epoch int
+
+	// changed is closed, and replaced, whenever a request may have room to
+	// start.
+	changed chan struct{}
+}
+
+// acquire waits for room under the limit, or until ctx is canceled,
+// returning the epoch the request started in.
+func (w *adaptiveWindow) acquire(ctx context.Context) (int, error) {
+	for {
+		w.mu.Lock()
+		if w.inFlight < w.limit {
+			w.inFlight++
+			epoch := w.epoch
+			w.mu.Unlock()
+			return epoch, nil
+		}
+
+		changed := w.changed
+		w.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// release gives back the room taken by a request that started in epoch,
+// adjusting the limit by the load it showed.
+func (w *adaptiveWindow) release(ctx context.Context, epoch int, load hostLoad) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.inFlight--
+	switch {
+	case load == loadOverloaded && epoch == w.epoch:
+		w.limit = max(1, w.limit/2)
+		w.keptUp = 0
+		w.epoch++
+		loggerFrom(ctx).Info("Lowering concurrency, the site is overloaded", "host", w.host, "concurrency", w.limit)
+	case load == loadKeepingUp && w.limit < w.max:
+		w.keptUp++
+		if w.keptUp >= w.limit {
+			w.limit++
+			w.keptUp = 0
+			loggerFrom(ctx).Debug("Raising concurrency", "host", w.host, "concurrency", w.limit)
+		}
+	}
+
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// adaptiveBody releases the place of the request it answers once it's
+// closed.
+type adaptiveBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *adaptiveBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveWindow(t *testing.T) {
+	ctx := quietContext()
+	w := &adaptiveWindow{max: 4, limit: 1, changed: make(chan struct{})}
+
+	// start acquires room for a request, failing if there's none.
+	start := func() int {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		epoch, err := w.acquire(ctx)
+		if err != nil {
+			t.Fatalf("acquire() error = %v with %d of %d in flight", err, w.inFlight, w.limit)
+		}
+
+		return epoch
+	}
+
+	// A round of requests that keep up raises the limit by one.
+	for _, want := range []int{2, 3, 4} {
+		var epochs []int
+		for range w.limit {
+			epochs = append(epochs, start())
+		}
+
+		for _, epoch := range epochs {
+			w.release(ctx, epoch, loadKeepingUp)
+		}
+
+		if w.limit != want {
+			t.Fatalf("limit = %d after a round of successes, want %d", w.limit, want)
+		}
+	}
+
+	// The limit stops at max.
+	for range 10 {
+		w.release(ctx, start(), loadKeepingUp)
+	}
+
+	if w.limit != 4 {
+		t.Errorf("limit = %d, want it capped at 4", w.limit)
+	}
+
+	// A full window can't take another request.
+	var epochs []int
+	for range 4 {
+		epochs = append(epochs, start())
+	}
+
+	over, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := w.acquire(over); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire() error = %v on a full window, want it to wait", err)
+	}
+
+	// The requests in flight when the host became overloaded halve the limit
+	// once between them.
+	for _, epoch := range epochs {
+		w.release(ctx, epoch, loadOverloaded)
+	}
+
+	if w.limit != 2 {
+		t.Errorf("limit = %d after a window of overloads, want 2", w.limit)
+	}
+
+	// Later overloads keep halving it, down to one.
+	w.release(ctx, start(), loadOverloaded)
+	w.release(ctx, start(), loadOverloaded)
+	if w.limit != 1 {
+		t.Errorf("limit = %d after two more overloads, want 1", w.limit)
+	}
+
+	// Failures that say nothing about the load leave it alone.
+	w.release(ctx, start(), loadUnknown)
+	if w.limit != 1 || w.inFlight != 0 {
+		t.Errorf("limit, in flight = %d, %d after an unrelated failure, want 1, 0", w.limit, w.inFlight)
+	}
+}
+
+func TestAdaptiveTransport(t *testing.T) {
+	// The server is overloaded by more than three requests at once.
+	var inFlight, peak atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		if n > 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	adaptive := newAdaptiveTransport(http.DefaultTransport, 8)
+	var wg sync.WaitGroup
+	var overloaded atomic.Int64
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				res, _, err := get(t, adaptive, server.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if res.StatusCode != http.StatusOK {
+					overloaded.Add(1)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if peak.Load() > 8 {
+		t.Errorf("%d requests were in flight at once, want at most 8", peak.Load())
+	}
+
+	if peak.Load() < 3 {
+		t.Errorf("at most %d requests were in flight at once, want the limit to have been raised", peak.Load())
+	}
+
+	// Backing off keeps most requests under the server's limit.
+	if n := overloaded.Load(); n > 320/4 {
+		t.Errorf("%d of 320 requests were overloaded, want the limit to have backed off", n)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// earliestCreatedDate is well before the site existed, so any question
+// created earlier has a bad date.
+var earliestCreatedDate = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// questionAnomalies lists the ways a question looks wrong even though it was
+// fetched and decoded successfully.
+func questionAnomalies(q Question, now time.Time) []string {
+	var anomalies []string
+
+	if strings.TrimSpace(stripHTML(q.Question)) == "" {
+		anomalies = append(anomalies, "missing question text")
+	}
+
+	if strings.TrimSpace(stripHTML(q.Answer)) == "" {
+		anomalies = append(anomalies, "missing answer text")
+	}
+
+	if strings.TrimSpace(string(q.Certificate)) == "" {
+		anomalies = append(anomalies, "empty certificate")
+	}
+
+	if strings.TrimSpace(string(q.Type)) == "" {
+		anomalies = append(anomalies, "empty type")
+	}
+
+	created := time.UnixMilli(int64(q.CreatedDate))
+	if created.Before(earliestCreatedDate) || created.After(now.Add(24*time.Hour)) {
+		anomalies = append(anomalies, fmt.Sprintf("invalid createdDate %d", q.CreatedDate))
+	}
+
+	if q.ImageFile != nil && strings.TrimSpace(*q.ImageFile) == "" {
+		anomalies = append(anomalies, "empty imageFile")
+	}
+
+	return anomalies
+}
+
+// newTaxonomyValues lists the certificate and type of a question if they
+// aren't among the values the site is known to use, which usually means the
+// site has added a certificate or aircraft. Empty values are anomalies
+// instead, and aren't listed.
+func newTaxonomyValues(q Question) []string {
+	var values []string
+
+	if q.Certificate != "" && !q.Certificate.Known() {
+		values = append(values, fmt.Sprintf("certificate %q", q.Certificate))
+	}
+
+	if q.Type != "" && !q.Type.Known() {
+		values = append(values, fmt.Sprintf("type %q", q.Type))
+	}
+
+	return values
+}
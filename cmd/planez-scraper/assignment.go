@@ -0,0 +1,571 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// assignment is a set of questions given as homework, in the order they are
+// to be answered.
+type assignment struct {
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Created     time.Time `json:"created"`
+	Due         time.Time `json:"due,omitzero"`
+	QuestionIDs []int     `json:"questionIds"`
+}
+
+// assignmentFormats are the formats assignment export can write.
+var assignmentFormats = []string{"latex", "markdown", "pdf"}
+
+func assignmentPath(name string) string {
+	return filepath.Join(activeWorkspace.AssignmentsDir(), name+".json")
+}
+
+func readAssignment(name string) (assignment, error) {
+	path := assignmentPath(name)
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return assignment{}, fmt.Errorf("no assignment named %s, create it with assignment create", name)
+	} else if err != nil {
+		return assignment{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var a assignment
+	if err := json.Unmarshal(contents, &a); err != nil {
+		return assignment{}, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	return a, nil
+}
+
+// loadQuestionBank loads the scraped questions in dir along with the custom
+// questions in customDir, if it isn't empty, which assignments are drawn
+// from.
+func loadQuestionBank(dir string, customDir string) (exportDataset, error) {
+	data, err := loadExportDataset(dir)
+	if err != nil {
+		return exportDataset{}, err
+	}
+
+	if customDir != "" {
+		questions, images, err := loadCustomQuestions(customDir, dir)
+		if err != nil {
+			return exportDataset{}, err
+		}
+
+		data.Questions = append(data.Questions, questions...)
+		maps.Copy(data.Images, images)
+	}
+
+	return data, nil
+}
+
+// assignedQuestions returns the assignment's questions in order. Questions
+// that are no longer in the bank are an error, since the numbering of the
+// rest would no longer match what was handed out.
+func assignedQuestions(a assignment, bank []Question) ([]Question, error) {
+	byID := make(map[int]Question, len(bank))
+	for _, q := range bank {
+		byID[q.QuestionID] = q
+	}
+
+	questions := make([]Question, 0, len(a.QuestionIDs))
+	for _, id := range a.QuestionIDs {
+		q, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("question %d of assignment %s is not in the data", id, a.Name)
+		}
+
+		questions = append(questions, q)
+	}
+
+	return questions, nil
+}
+
+var assignmentCommands = map[string]func(args []string) error{
+	"create": runAssignmentCreate,
+	"export": runAssignmentExport,
+	"import": runAssignmentImport,
+	"list":   runAssignmentList,
+}
+
+func runAssignment(args []string) error {
+	names := slices.Sorted(maps.Keys(assignmentCommands))
+
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: %s", strings.Join(names, ", "))
+	}
+
+	command, ok := assignmentCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q%s", args[0], didYouMean(args[0], names))
+	}
+
+	return command(args[1:])
+}
+
+func runAssignmentCreate(args []string) error {
+	flags := flag.NewFlagSet("assignment create", flag.ExitOnError)
+	dir := flags.String("data", activeWorkspace.DataDir(), "Data directory to draw questions from")
+	custom := flags.String("custom", activeWorkspace.CustomDir(), "Directory of custom questions to draw from as well (empty for none)")
+	title := flags.String("title", "", "Title printed on the assignment (default the name)")
+	due := flags.String("due", "", "Date the assignment is due, e.g. 2024-06-01")
+	certificateSpec := flags.String("certificate", "", "Comma separated certificates to draw questions from (default all)")
+	typeSpec := flags.String("type", "", "Comma separated question types to draw questions from (default all)")
+	idSpec := flags.String("ids", "", "Comma separated question IDs and ranges to draw questions from (default all)")
+	starredOnly := flags.Bool("starred-only", false, "Only draw questions starred with the star command")
+	count := flags.Int("count", 0, "Number of the matching questions to pick at random (0 for all of them)")
+	order := flags.String("order", "id", "Order of the questions: id, or random")
+	seed := flags.Int64("seed", 0, "Seed for -count and -order random, to make the same assignment again (default random)")
+	force := flags.Bool("force", false, "Replace an assignment that already exists")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper assignment create [flags] NAME")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 1, "expected the name of the assignment, got %d arguments", flags.NArg())
+	name := flags.Arg(0)
+	v.Check(profileNamePattern.MatchString(name), "NAME: invalid name %q, use letters, digits, '.', '-', and '_'", name)
+	v.CheckFile("-data", filepath.Join(*dir, "questions.json"))
+	var dueDate time.Time
+	if *due != "" {
+		var err error
+		dueDate, err = parseReviewDate(*due)
+		v.CheckErr("-due", err)
+	}
+	var certificates *Set[planez.Certificate]
+	if *certificateSpec != "" {
+		var err error
+		certificates, err = parseCertificates(*certificateSpec)
+		v.CheckErr("-certificate", err)
+	}
+	var types *Set[planez.QuestionType]
+	if *typeSpec != "" {
+		var err error
+		types, err = parseQuestionTypes(*typeSpec)
+		v.CheckErr("-type", err)
+	}
+	var ids *Set[int]
+	if *idSpec != "" {
+		ranges, err := parseIDRanges(*idSpec)
+		v.CheckErr("-ids", err)
+		ids = NewSet[int]()
+		for _, id := range ranges.IDs() {
+			ids.Add(id)
+		}
+	}
+	v.Check(*count >= 0, "-count: must not be negative, got %d", *count)
+	v.Check(*order == "id" || *order == "random", "-order: expected id or random, got %q", *order)
+	v.Check(*seed == 0 || *count > 0 || *order == "random", "-seed: only applies with -count or -order random")
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(assignmentPath(name)); err == nil && !*force {
+		return fmt.Errorf("assignment %s already exists, pass -force to replace it", name)
+	}
+
+	data, err := loadQuestionBank(*dir, *custom)
+	if err != nil {
+		return err
+	}
+
+	var stars *Set[int]
+	if *starredOnly {
+		if stars, err = readStars(activeWorkspace.StarsPath()); err != nil {
+			return err
+		}
+	}
+
+	var matching []int
+	for _, q := range data.Questions {
+		switch {
+		case certificates != nil && !certificates.Contains(q.Certificate):
+		case types != nil && !types.Contains(q.Type):
+		case ids != nil && !ids.Contains(q.QuestionID):
+		case stars != nil && !stars.Contains(q.QuestionID):
+		default:
+			matching = append(matching, q.QuestionID)
+		}
+	}
+
+	if len(matching) == 0 {
+		return fmt.Errorf("no questions match, so there is nothing to assign")
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	// A sample is drawn at random, but is in ID order unless asked for
+	// otherwise.
+	slices.Sort(matching)
+	if *count > 0 {
+		matching = sampleIDs(matching, *count, *seed)
+	}
+
+	if *order == "random" {
+		rng := rand.New(rand.NewSource(*seed))
+		rng.Shuffle(len(matching), func(i, j int) { matching[i], matching[j] = matching[j], matching[i] })
+	}
+
+	a := assignment{
+		Name:        name,
+		Title:       *title,
+		Created:     time.Now().UTC(),
+		Due:         dueDate,
+		QuestionIDs: matching,
+	}
+	if a.Title == "" {
+		a.Title = name
+	}
+
+	if err := os.MkdirAll(activeWorkspace.AssignmentsDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", activeWorkspace.AssignmentsDir(), err)
+	}
+
+	if err := writeJSONFile(assignmentPath(name), a); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created assignment %s with %d questions\n", name, len(a.QuestionIDs))
+
+	return nil
+}
+
+func runAssignmentList(args []string) error {
+	flags := flag.NewFlagSet("assignment list", flag.ExitOnError)
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(activeWorkspace.AssignmentsDir(), "*.json"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tQUESTIONS\tCREATED\tDUE\tTITLE")
+	for _, path := range paths {
+		a, err := readAssignment(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return err
+		}
+
+		due := "-"
+		if !a.Due.IsZero() {
+			due = a.Due.Format(time.DateOnly)
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", a.Name, len(a.QuestionIDs), a.Created.Format(time.DateOnly), due, a.Title)
+	}
+
+	return w.Flush()
+}
+
+// assignmentTemplateData is what the assignment's LaTeX template is executed
+// with.
+type assignmentTemplateData struct {
+	templateData
+	Assignment assignment
+	Key        bool
+}
+
+// writeAssignmentMarkdown writes the assignment as a numbered worksheet, or
+// with key, as its answer key.
+func writeAssignmentMarkdown(w io.Writer, a assignment, data exportDataset, key bool) error {
+	heading := a.Title
+	if key {
+		heading += ": Answer Key"
+	}
+
+	fmt.Fprintf(w, "# %s\n\n", markdownEscaper.Replace(heading))
+	if !a.Due.IsZero() {
+		fmt.Fprintf(w, "Due %s.\n\n", a.Due.Format("January 2, 2006"))
+	}
+
+	if !key {
+		fmt.Fprintf(w, "Name: ______________________\n\n")
+	}
+
+	for i, q := range data.Questions {
+		fmt.Fprintf(w, "## %d. %s\n\n", i+1, strings.Join(markdownLines(q.Question), " "))
+		if image := data.ImagePath(q); image != "" && !key {
+			fmt.Fprintf(w, "![](%s/%s)\n\n", data.DataDir, image)
+		}
+
+		if !key {
+			continue
+		}
+
+		if lines := markdownLines(q.Answer); len(lines) > 0 {
+			fmt.Fprintf(w, "%s\n\n", strings.Join(lines, "\n"))
+		}
+
+		if q.CustomSource != "" {
+			fmt.Fprintf(w, "*Custom question from %s*\n\n", markdownEscaper.Replace(q.CustomSource))
+		} else {
+			fmt.Fprintf(w, "*Question %d*\n\n", q.QuestionID)
+		}
+	}
+
+	return nil
+}
+
+func writeAssignmentLaTeX(w io.Writer, a assignment, data exportDataset, key bool) error {
+	text, err := builtinTemplates.ReadFile("templates/assignment.tmpl")
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("assignment.tmpl").Funcs(templateFuncs(data)).Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, assignmentTemplateData{templateData{data, exportOptions{}}, a, key})
+}
+
+// writeAssignmentPDF typesets the assignment's LaTeX with pdflatex, which
+// must be installed, and writes the PDF to out.
+func writeAssignmentPDF(out string, a assignment, data exportDataset, key bool) error {
+	pdflatex, err := exec.LookPath("pdflatex")
+	if err != nil {
+		return fmt.Errorf("pdflatex not found, install a TeX distribution or use -format latex and typeset it yourself")
+	}
+
+	tmp, err := os.MkdirTemp("", "planez-assignment-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	texPath := filepath.Join(tmp, "assignment.tex")
+	file, err := os.Create(texPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", texPath, err)
+	}
+
+	err = writeAssignmentLaTeX(file, a, data, key)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", texPath, err)
+	}
+
+	// The exam class needs a second pass to number the pages.
+	for range 2 {
+		cmd := exec.Command(pdflatex, "-interaction=nonstopmode", "-halt-on-error", "assignment.tex")
+		cmd.Dir = tmp
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("pdflatex failed: %v\n%s", err, output)
+		}
+	}
+
+	contents, err := os.ReadFile(filepath.Join(tmp, "assignment.pdf"))
+	if err != nil {
+		return fmt.Errorf("failed to read typeset assignment: %v", err)
+	}
+
+	return replaceFileContents(out, contents)
+}
+
+func runAssignmentExport(args []string) error {
+	flags := flag.NewFlagSet("assignment export", flag.ExitOnError)
+	dir := flags.String("data", activeWorkspace.DataDir(), "Data directory the assignment was drawn from")
+	custom := flags.String("custom", activeWorkspace.CustomDir(), "Directory of custom questions the assignment was drawn from (empty for none)")
+	format := flags.String("format", "markdown", "Format to write: "+strings.Join(assignmentFormats, ", "))
+	key := flags.Bool("key", false, "Write the answer key instead of the worksheet")
+	out := flags.String("o", "", "Path to write the assignment to (default stdout, required for pdf)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper assignment export [flags] NAME")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 1, "expected the name of an assignment, got %d arguments", flags.NArg())
+	v.Check(slices.Contains(assignmentFormats, *format), "-format: unknown format %q%s", *format, didYouMean(*format, assignmentFormats))
+	v.CheckFile("-data", filepath.Join(*dir, "questions.json"))
+	v.Check(*format != "pdf" || *out != "", "-o: required with -format pdf")
+	if *out != "" {
+		v.CheckParentDir("-o", *out)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	a, err := readAssignment(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	data, err := loadQuestionBank(*dir, *custom)
+	if err != nil {
+		return err
+	}
+
+	if data.Questions, err = assignedQuestions(a, data.Questions); err != nil {
+		return err
+	}
+
+	// PDFs are typeset in a temporary directory, so they find images by
+	// their absolute path.
+	data.SourceDir = *dir
+	data.DataDir = filepath.ToSlash(*dir)
+	if *format == "pdf" {
+		if abs, err := filepath.Abs(*dir); err == nil {
+			data.DataDir = filepath.ToSlash(abs)
+		}
+
+		return writeAssignmentPDF(*out, a, data, *key)
+	} else if *out != "" {
+		if rel, err := relativeTo(filepath.Dir(*out), *dir); err == nil {
+			data.DataDir = filepath.ToSlash(rel)
+		}
+	}
+
+	export := func(w io.Writer) error {
+		if *format == "latex" {
+			return writeAssignmentLaTeX(w, a, data, *key)
+		}
+
+		return writeAssignmentMarkdown(w, a, data, *key)
+	}
+
+	if *out == "" {
+		err = export(os.Stdout)
+	} else {
+		err = replaceFile(*out, export)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to export assignment %s: %v", a.Name, err)
+	}
+
+	return nil
+}
+
+func runAssignmentImport(args []string) error {
+	flags := flag.NewFlagSet("assignment import", flag.ExitOnError)
+	path := flags.String("progress", activeWorkspace.ProgressPath(), "Progress file to import into, such as a student's")
+	date := flags.String("date", "", "Date the assignment was done, for rows without one (default the due date, or today)")
+	byID := flags.Bool("by-id", false, "Rows give question IDs rather than the numbers of the questions in the assignment")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper assignment import [flags] NAME FILE.csv")
+		fmt.Fprintln(flags.Output(), "Each row of FILE.csv is a question number, right or wrong, and optionally a date.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 2, "expected the name of an assignment and a CSV file of results, got %d arguments", flags.NArg())
+	if flags.NArg() == 2 {
+		v.CheckFile("FILE", flags.Arg(1))
+	}
+	var at time.Time
+	if *date != "" {
+		var err error
+		at, err = parseReviewDate(*date)
+		v.CheckErr("-date", err)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	a, err := readAssignment(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if at.IsZero() {
+		at = a.Due
+	}
+
+	if at.IsZero() {
+		at = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	name := flags.Arg(1)
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", name, err)
+	}
+
+	reviews, err := readResults(file, name, at, "assignment:"+a.Name)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	for i, r := range reviews {
+		if *byID && !slices.Contains(a.QuestionIDs, r.QuestionID) {
+			return fmt.Errorf("%s: question %d is not in assignment %s", name, r.QuestionID, a.Name)
+		} else if !*byID {
+			if r.QuestionID < 1 || r.QuestionID > len(a.QuestionIDs) {
+				return fmt.Errorf("%s: assignment %s has questions 1 to %d, not %d", name, a.Name, len(a.QuestionIDs), r.QuestionID)
+			}
+
+			reviews[i].QuestionID = a.QuestionIDs[r.QuestionID-1]
+		}
+	}
+
+	store, err := readProgress(*path)
+	if err != nil {
+		return err
+	}
+
+	added := store.Add(reviews)
+	if err := writeProgress(*path, store); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d results, %d of them already recorded\n", len(reviews), len(reviews)-added)
+	right, answered := scoreResults(reviews)
+	fmt.Printf("Scored %d of %d right, with %d of %d questions answered\n", right, answered, answered, len(a.QuestionIDs))
+
+	return nil
+}
+
+// scoreResults returns how many questions the results answer, and how many
+// of them were answered right the last time they were answered, so that a
+// question answered more than once counts once.
+func scoreResults(reviews []review) (right int, answered int) {
+	last := make(map[int]review)
+	for _, r := range reviews {
+		if prev, ok := last[r.QuestionID]; !ok || !r.At.Before(prev.At) {
+			last[r.QuestionID] = r
+		}
+	}
+
+	for _, r := range last {
+		if r.Correct {
+			right++
+		}
+	}
+
+	return right, len(last)
+}
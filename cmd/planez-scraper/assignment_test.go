@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoreResults(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name     string
+		reviews  []review
+		right    int
+		answered int
+	}{
+		{name: "none"},
+		{
+			name:     "once each",
+			reviews:  []review{{QuestionID: 1000, Correct: true, At: monday}, {QuestionID: 1001, At: monday}},
+			right:    1,
+			answered: 2,
+		},
+		{
+			name: "answered again",
+			reviews: []review{
+				{QuestionID: 1000, At: monday},
+				{QuestionID: 1001, Correct: true, At: tuesday},
+				{QuestionID: 1000, Correct: true, At: tuesday},
+				{QuestionID: 1001, At: monday},
+			},
+			right:    2,
+			answered: 2,
+		},
+		{
+			name:     "twice on the same day",
+			reviews:  []review{{QuestionID: 1000, Correct: true, At: monday}, {QuestionID: 1000, At: monday}},
+			right:    0,
+			answered: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			right, answered := scoreResults(tt.reviews)
+			if right != tt.right || answered != tt.answered {
+				t.Errorf("scoreResults() = %d right of %d answered, want %d of %d", right, answered, tt.right, tt.answered)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const backupManifestName = "manifest.json"
+
+// backupVersion is the version of the archives backup writes. Version 1
+// archives, which have no version in their manifest, held only the data
+// directory, and version 2 archives hold the whole workspace.
+const backupVersion = 2
+
+type backupManifest struct {
+	Version   int          `json:"version,omitempty"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Files     []backupFile `json:"files"`
+}
+
+type backupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := flags.String("dir", activeWorkspace.Dir, "Workspace directory to back up")
+	out := flags.String("o", "", "Path to write the archive to (default planez-backup-<timestamp>.tar.gz)")
+	encrypt := flags.String("encrypt", "", "Encrypt the archive for a recipient, as age:RECIPIENT or gpg:RECIPIENT")
+	flags.Parse(args)
+
+	var v validator
+	v.CheckDir("-dir", *dir)
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	if *out != "" {
+		v.CheckParentDir("-o", *out)
+	}
+	if *encrypt != "" {
+		_, _, err := parseEncryptionSpec(*encrypt)
+		v.CheckErr("-encrypt", err)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = "planez-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		if *encrypt != "" {
+			*out += encryptionExtension(*encrypt)
+		}
+	}
+
+	manifest, err := buildBackupManifest(*dir)
+	if err != nil {
+		return err
+	}
+
+	sum, err := writeBackup(*out, *dir, manifest, *encrypt)
+	if err != nil {
+		return err
+	}
+
+	checksum := fmt.Sprintf("%s  %s\n", sum, filepath.Base(*out))
+	err = replaceFile(*out+".sha256", func(w io.Writer) error {
+		_, err := io.WriteString(w, checksum)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Wrote backup", "files", len(manifest.Files), "path", *out)
+
+	return nil
+}
+
+// buildBackupManifest lists the files of the workspace in dir, leaving out
+// anything else in it, such as the other files of the current directory
+// when the workspace is kept there.
+func buildBackupManifest(dir string) (backupManifest, error) {
+	manifest := backupManifest{Version: backupVersion, CreatedAt: time.Now().UTC()}
+
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		size, sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, backupFile{
+			Path:   filepath.ToSlash(rel),
+			Size:   size,
+			SHA256: sum,
+		})
+
+		return nil
+	}
+
+	for _, p := range (workspace{Dir: dir}).StatePaths() {
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err := filepath.WalkDir(p, walk); err != nil {
+			return backupManifest{}, fmt.Errorf("failed to read %s: %v", p, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// writeBackup writes the manifest followed by every file it lists into a
+// gzipped tarball, returning the SHA-256 of the archive itself. If encrypt
+// is set, the tarball is encrypted before being written. An archive already
+// at out is only replaced once the new one is complete.
+func writeBackup(out string, dir string, manifest backupManifest, encrypt string) (string, error) {
+	file, err := createPendingFile(out)
+	if err != nil {
+		return "", err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			file.Discard()
+		}
+	}()
+
+	hash := sha256.New()
+	var dest io.WriteCloser = nopWriteCloser{io.MultiWriter(file, hash)}
+	if encrypt != "" {
+		dest, err = encryptWriter(encrypt, dest)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	gz := gzip.NewWriter(dest)
+	tw := tar.NewWriter(gz)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    backupManifestName,
+		Mode:    0644,
+		Size:    int64(len(manifestData)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write to %s: %v", out, err)
+	}
+
+	if _, err := tw.Write(manifestData); err != nil {
+		return "", fmt.Errorf("failed to write to %s: %v", out, err)
+	}
+
+	for _, f := range manifest.Files {
+		if err := addBackupFile(tw, dir, f); err != nil {
+			return "", fmt.Errorf("failed to write to %s: %v", out, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write to %s: %v", out, err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to write to %s: %v", out, err)
+	}
+
+	if err := dest.Close(); err != nil {
+		return "", fmt.Errorf("failed to write to %s: %v", out, err)
+	}
+
+	committed = true
+	if err := file.Commit(); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func addBackupFile(tw *tar.Writer, dir string, f backupFile) error {
+	src, err := os.Open(filepath.Join(dir, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    path.Join("data", f.Path),
+		Mode:    0644,
+		Size:    f.Size,
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(tw, src, f.Size)
+	return err
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := flags.String("dir", activeWorkspace.Dir, "Workspace directory to restore into, created if missing")
+	force := flags.Bool("force", false, "Replace the workspace's files if it already has any the archive holds")
+	identity := flags.String("identity", "", "age identity file used to decrypt an encrypted archive")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper restore [flags] ARCHIVE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 1, "expected exactly one archive, got %d", flags.NArg())
+	if flags.NArg() == 1 {
+		v.CheckFile("archive", flags.Arg(0))
+	}
+	if info, err := os.Stat(*dir); err == nil {
+		v.Check(info.IsDir(), "-dir: %s is not a directory", *dir)
+	}
+	if *identity != "" {
+		v.CheckFile("-identity", *identity)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	archive := flags.Arg(0)
+
+	if err := verifyBackupChecksum(archive); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", *dir, err)
+	}
+
+	tmp, err := os.MkdirTemp(*dir, ".restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	staged := filepath.Join(tmp, "files")
+	if err := os.Mkdir(staged, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	manifest, err := extractBackup(archive, staged, *identity)
+	if err != nil {
+		return err
+	}
+
+	if manifest.Version > backupVersion {
+		return fmt.Errorf("%s is a version %d backup, which this version can't restore", archive, manifest.Version)
+	}
+
+	for _, f := range manifest.Files {
+		size, sum, err := hashFile(filepath.Join(staged, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return fmt.Errorf("backup is missing %s: %v", f.Path, err)
+		}
+
+		if size != f.Size || sum != f.SHA256 {
+			return fmt.Errorf("backup file %s does not match the manifest", f.Path)
+		}
+	}
+
+	// Older archives hold only the data directory, so they are restored as
+	// a workspace holding just that.
+	if manifest.Version < 2 {
+		workspaceDir := filepath.Join(tmp, "workspace")
+		if err := os.MkdirAll(workspaceDir, 0755); err != nil {
+			return fmt.Errorf("failed to create temporary directory: %v", err)
+		}
+
+		if err := os.Rename(staged, filepath.Join(workspaceDir, "data")); err != nil {
+			return fmt.Errorf("failed to move restored data: %v", err)
+		}
+
+		staged = workspaceDir
+	}
+
+	entries, err := os.ReadDir(staged)
+	if err != nil {
+		return fmt.Errorf("failed to read restored data: %v", err)
+	}
+
+	var existing []string
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(*dir, entry.Name())); err == nil {
+			existing = append(existing, entry.Name())
+		}
+	}
+
+	if len(existing) > 0 && !*force {
+		return fmt.Errorf("-dir: %s already has %s, use -force to replace them", *dir, strings.Join(existing, ", "))
+	}
+
+	// Only the files the archive holds are replaced, leaving anything else
+	// in the directory, such as the rest of the current directory when the
+	// workspace is kept there.
+	for _, entry := range entries {
+		dest := filepath.Join(*dir, entry.Name())
+		if err := os.RemoveAll(dest); err != nil {
+			return fmt.Errorf("failed to clear %s: %v", dest, err)
+		}
+
+		if err := os.Rename(filepath.Join(staged, entry.Name()), dest); err != nil {
+			return fmt.Errorf("failed to move restored data to %s: %v", dest, err)
+		}
+	}
+
+	slog.Info("Restored backup", "files", len(manifest.Files), "dir", *dir)
+
+	return nil
+}
+
+// verifyBackupChecksum compares the archive against the checksum file
+// written next to it by the backup command, if one is present.
+func verifyBackupChecksum(archive string) error {
+	checksumPath := archive + ".sha256"
+	contents, err := os.ReadFile(checksumPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("No checksum found, skipping archive verification", "path", checksumPath)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %v", checksumPath, err)
+	}
+
+	want, _, _ := strings.Cut(strings.TrimSpace(string(contents)), " ")
+
+	_, got, err := hashFile(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", archive, err)
+	}
+
+	if got != want {
+		return fmt.Errorf("checksum of %s does not match %s", archive, checksumPath)
+	}
+
+	return nil
+}
+
+func extractBackup(archive string, dest string, identityFile string) (backupManifest, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return backupManifest{}, fmt.Errorf("failed to open %s: %v", archive, err)
+	}
+
+	defer file.Close()
+
+	plain, wait, err := decryptReader(file, identityFile)
+	if err != nil {
+		return backupManifest{}, err
+	}
+
+	manifest, err := extractTarball(archive, plain, dest)
+	io.Copy(io.Discard, plain)
+	if waitErr := wait(); err == nil {
+		err = waitErr
+	}
+
+	return manifest, err
+}
+
+func extractTarball(archive string, r io.Reader, dest string) (backupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return backupManifest{}, fmt.Errorf("failed to read %s: %v", archive, err)
+	}
+
+	defer gz.Close()
+
+	var manifest backupManifest
+	foundManifest := false
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return backupManifest{}, fmt.Errorf("failed to read %s: %v", archive, err)
+		}
+
+		if header.Name == backupManifestName {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return backupManifest{}, fmt.Errorf("failed to decode backup manifest: %v", err)
+			}
+
+			foundManifest = true
+			continue
+		}
+
+		rel, ok := strings.CutPrefix(header.Name, "data/")
+		if !ok || !filepath.IsLocal(rel) || header.Typeflag != tar.TypeReg {
+			return backupManifest{}, fmt.Errorf("unexpected entry %q in %s", header.Name, archive)
+		}
+
+		destPath := filepath.Join(dest, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+			return backupManifest{}, fmt.Errorf("failed to create %s: %v", filepath.Dir(destPath), err)
+		}
+
+		out, err := os.Create(destPath)
+		if err != nil {
+			return backupManifest{}, fmt.Errorf("failed to create %s: %v", destPath, err)
+		}
+
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return backupManifest{}, fmt.Errorf("failed to write %s: %v", destPath, err)
+		}
+
+		os.Chtimes(destPath, header.ModTime, header.ModTime)
+	}
+
+	if !foundManifest {
+		return backupManifest{}, fmt.Errorf("%s does not contain a %s", archive, backupManifestName)
+	}
+
+	return manifest, nil
+}
+
+func hashFile(p string) (int64, string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	workspace := map[string]string{
+		"data/questions.json":     `[]`,
+		"custom/questions.json":   `[]`,
+		"notes.json":              `{"1000":"Check the chart supplement"}`,
+		"progress.json":           `[]`,
+		"stars.json":              `[1000]`,
+		"assignments/week-1.json": `{}`,
+		"group/sam/notes.json":    `{}`,
+		"synced/notes.json":       `{}`,
+		historyFileName:           "history",
+	}
+
+	// The workspace is kept in a directory holding other files, as it is
+	// when kept in the current directory, which the backup leaves out.
+	src := t.TempDir()
+	writeTestFiles(t, src, workspace)
+	writeTestFiles(t, src, map[string]string{"unrelated.txt": "not the tool's", "debug/1000.html": "<html>"})
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := runBackup([]string{"-dir", src, "-o", archive}); err != nil {
+		t.Fatalf("backup error = %v", err)
+	}
+
+	// Restoring onto a fresh machine creates the workspace's directory.
+	dest := filepath.Join(t.TempDir(), "share", "planez-scraper")
+	if err := runRestore([]string{"-dir", dest, archive}); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+
+	checkTestFiles(t, dest, workspace)
+	for _, name := range []string{"unrelated.txt", "debug"} {
+		if _, err := os.Stat(filepath.Join(dest, name)); err == nil {
+			t.Errorf("restore wrote %s, which isn't part of the workspace", name)
+		}
+	}
+
+	// Restoring over the workspace needs -force, which replaces only what
+	// the archive holds.
+	writeTestFiles(t, dest, map[string]string{"notes.json": "{}", "unrelated.txt": "kept"})
+	err := runRestore([]string{"-dir", dest, archive})
+	if err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Fatalf("restore over a workspace error = %v, want one suggesting -force", err)
+	}
+
+	if err := runRestore([]string{"-dir", dest, "-force", archive}); err != nil {
+		t.Fatalf("restore -force error = %v", err)
+	}
+
+	checkTestFiles(t, dest, workspace)
+	checkTestFiles(t, dest, map[string]string{"unrelated.txt": "kept"})
+
+	entries, _ := filepath.Glob(filepath.Join(dest, ".restore-*"))
+	archives, _ := filepath.Glob(filepath.Join(filepath.Dir(archive), ".*.tmp"))
+	if len(entries) > 0 || len(archives) > 0 {
+		t.Errorf("temporary files left behind: %v %v", entries, archives)
+	}
+}
+
+// TestRestoreDataBackup restores an archive written before backups held the
+// whole workspace, which holds the data directory alone.
+func TestRestoreDataBackup(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	questions := `[{"questionId":1000}]`
+
+	manifest := backupManifest{
+		CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Files:     []backupFile{{Path: "questions.json", Size: int64(len(questions))}},
+	}
+	sum := sha256.Sum256([]byte(questions))
+	manifest.Files[0].SHA256 = hex.EncodeToString(sum[:])
+
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	manifestData, _ := json.Marshal(manifest)
+	for name, contents := range map[string][]byte{backupManifestName: manifestData, "data/questions.json": []byte(questions)} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))})
+		tw.Write(contents)
+	}
+	tw.Close()
+	gz.Close()
+	file.Close()
+
+	dest := t.TempDir()
+	if err := runRestore([]string{"-dir", dest, archive}); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+
+	checkTestFiles(t, dest, map[string]string{"data/questions.json": questions})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// maxCapturedPages bounds how many responses a run saves, so that a site
+// serving an error page for everything doesn't fill the disk before the run
+// stops.
+const maxCapturedPages = 50
+
+// DebugDir holds the responses saved by a scrape because they came back in
+// the wrong shape, to look at after the run.
+func (w workspace) DebugDir() string {
+	return filepath.Join(w.Dir, "debug")
+}
+
+// pageCapture saves the bodies of responses that came back in the wrong
+// shape, such as an error page served in place of a question. It is safe for
+// concurrent use.
+type pageCapture struct {
+	dir string
+
+	mu    sync.Mutex
+	saved int
+}
+
+// Save writes the body of the response err is about to the capture
+// directory, if err is a *planez.ShapeError, returning the path it was
+// written to. It returns an empty path if there is nothing to save, the
+// capture is disabled, or the run has saved maxCapturedPages already.
+func (c *pageCapture) Save(err error) (string, error) {
+	var shapeErr *planez.ShapeError
+	if c == nil || c.dir == "" || !errors.As(err, &shapeErr) || len(shapeErr.Body) == 0 {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	if c.saved >= maxCapturedPages {
+		c.mu.Unlock()
+		return "", nil
+	}
+
+	c.saved++
+	c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", c.dir, err)
+	}
+
+	name := time.Now().UTC().Format("20060102T150405Z") + "-" + pageNames.Replace(shapeErr.What) + pageExtension(shapeErr.Body)
+	path := filepath.Join(c.dir, name)
+	if err := replaceFileContents(path, shapeErr.Body); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Keep saves the response a failed fetch is about, as Save does, logging
+// where it was saved with the given attributes.
+func (c *pageCapture) Keep(err error, args ...any) {
+	path, err := c.Save(err)
+	if err != nil {
+		slog.Warn("Failed to save the unexpected response", append(args, "error", err)...)
+	} else if path != "" {
+		slog.Info("Saved the unexpected response", append(args, "path", path)...)
+	}
+}
+
+// pageNames turns what a response was for, such as "image a/b.png", into
+// part of a file name.
+var pageNames = strings.NewReplacer(" ", "-", "/", "_", "\\", "_")
+
+// pageExtension returns the extension to save a response's body with, so that
+// it opens with something that can show it.
+func pageExtension(body []byte) string {
+	switch contentType := http.DetectContentType(body); {
+	case strings.HasPrefix(contentType, "text/html"):
+		return ".html"
+	case json.Valid(body):
+		return ".json"
+	case strings.HasPrefix(contentType, "text/"):
+		return ".txt"
+	}
+
+	return ".bin"
+}
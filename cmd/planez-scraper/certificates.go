@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// certificateAliases are the usual abbreviations of the known certificates,
+// which -certificate accepts as well as the site's names.
+var certificateAliases = map[string]planez.Certificate{
+	"PPL": planez.CertificatePrivate,
+	"CPL": planez.CertificateCommercial,
+}
+
+// parseCertificates parses a comma separated list of certificates, by the
+// site's name or an alias, ignoring case.
+func parseCertificates(spec string) (*Set[planez.Certificate], error) {
+	var names []string
+	for _, certificate := range planez.Certificates {
+		names = append(names, string(certificate))
+	}
+
+	for alias := range certificateAliases {
+		names = append(names, alias)
+	}
+
+	certificates := NewSet[planez.Certificate]()
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		if certificate, ok := certificateAliases[value]; ok {
+			certificates.Add(certificate)
+		} else if certificate := planez.Certificate(value); certificate.Known() {
+			certificates.Add(certificate)
+		} else {
+			return nil, fmt.Errorf("unknown certificate %q%s", value, didYouMean(value, names))
+		}
+	}
+
+	if certificates.Len() == 0 {
+		return nil, fmt.Errorf("no certificates given")
+	}
+
+	return certificates, nil
+}
+
+// parseQuestionTypes parses a comma separated list of question types,
+// ignoring case.
+func parseQuestionTypes(spec string) (*Set[planez.QuestionType], error) {
+	var names []string
+	for _, t := range planez.QuestionTypes {
+		names = append(names, string(t))
+	}
+
+	types := NewSet[planez.QuestionType]()
+	for _, value := range strings.Split(spec, ",") {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		if t := planez.QuestionType(value); t.Known() {
+			types.Add(t)
+		} else {
+			return nil, fmt.Errorf("unknown type %q%s", value, didYouMean(value, names))
+		}
+	}
+
+	if types.Len() == 0 {
+		return nil, fmt.Errorf("no types given")
+	}
+
+	return types, nil
+}
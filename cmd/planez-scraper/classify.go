@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// maxRetryDelay caps the backoff between attempts, however many have been
+// made.
+const maxRetryDelay = 30 * time.Second
+
+type errorClass int
+
+const (
+	// classError failures are logged and counted, and the run continues.
+	classError errorClass = iota
+	classRetry
+	classFatal
+	classSkip
+)
+
+var errorClassNames = map[string]errorClass{
+	"error": classError,
+	"retry": classRetry,
+	"fatal": classFatal,
+	"skip":  classSkip,
+}
+
+type statusRules map[int]errorClass
+
+// parseStatusRules parses a comma separated list of STATUS=CLASS rules, such
+// as "403=fatal,410=skip,5xx=retry".
+func parseStatusRules(spec string) (statusRules, error) {
+	rules := make(statusRules)
+	if spec == "" {
+		return rules, nil
+	}
+
+	for _, rule := range strings.Split(spec, ",") {
+		status, className, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule %q, expected STATUS=CLASS", rule)
+		}
+
+		class, ok := errorClassNames[className]
+		if !ok {
+			return nil, fmt.Errorf("unknown class %q in rule %q, expected error, retry, fatal, or skip", className, rule)
+		}
+
+		if len(status) == 3 && strings.HasSuffix(status, "xx") && status[0] >= '1' && status[0] <= '5' {
+			base := int(status[0]-'0') * 100
+			for code := base; code < base+100; code++ {
+				if _, exists := rules[code]; !exists {
+					rules[code] = class
+				}
+			}
+
+			continue
+		}
+
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status %q in rule %q", status, rule)
+		}
+
+		rules[code] = class
+	}
+
+	return rules, nil
+}
+
+// classify returns the class of a failed fetch. Statuses without a rule are
+// retried if they are 429 or 5xx, since those usually clear up on their own,
+// as are requests that never got a response.
+func (r statusRules) classify(err error) errorClass {
+	var statusErr *planez.StatusError
+	if errors.As(err, &statusErr) {
+		return r.classifyStatus(statusErr.Status)
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return classRetry
+	}
+
+	return classError
+}
+
+// classifyStatus returns the class of a response with an unexpected status.
+func (r statusRules) classifyStatus(status int) errorClass {
+	if class, ok := r[status]; ok {
+		return class
+	}
+
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return classRetry
+	}
+
+	return classError
+}
+
+// retryPolicy is how often and how patiently failed fetches classified as
+// retry are attempted again.
+type retryPolicy struct {
+	maxAttempts int
+	delay       time.Duration
+}
+
+// backoff returns how long to wait after the given attempt failed. The delay
+// doubles with each attempt up to maxRetryDelay, and a random half of it is
+// jittered so that workers which failed together don't retry together.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.delay
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+
+	d = min(d, maxRetryDelay)
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// panicError is returned in place of a panic raised while processing a
+// single item, so that one bad record can't bring down the whole run.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+func recoverFetch[T any](fetch func() (T, error)) (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+
+	return fetch()
+}
+
+// describeFailure formats an error for the failure report, including the
+// stack trace of recovered panics when verbose is set.
+func describeFailure(err error, verbose bool) string {
+	var panicErr *panicError
+	if verbose && errors.As(err, &panicErr) {
+		return err.Error() + "\n" + string(panicErr.stack)
+	}
+
+	return err.Error()
+}
+
+// fetchWithRules calls fetch and classifies its failure, if it fails. Failed
+// requests are retried by the client's transport, if it retries, before
+// fetch returns. fetch is given ctx carrying logger, which names the item
+// being fetched, so that the transport's records name it too. The failure
+// is left to the caller to report.
+func fetchWithRules[T any](ctx context.Context, logger *slog.Logger, rules statusRules, fetch func(context.Context) (T, error)) (T, errorClass, error) {
+	start := time.Now()
+	value, err := recoverFetch(func() (T, error) { return fetch(withLogger(ctx, logger)) })
+	if err == nil {
+		logger.Debug("Fetched", "duration", time.Since(start))
+		return value, classError, nil
+	}
+
+	if ctx.Err() != nil {
+		return value, classError, err
+	}
+
+	return value, rules.classify(err), err
+}
+
+// apiChangeThreshold is how many fetches of a kind in a row have to fail
+// with a *planez.ShapeError for a run to stop, rather than an odd response
+// or two.
+const apiChangeThreshold = 3
+
+// apiChangedError stops a run once responses of a kind keep coming back in a
+// shape the scraper doesn't know, which means the site's API has changed and
+// every fetch after them would fail the same way.
+type apiChangedError struct {
+	kind   string
+	errors []*planez.ShapeError
+}
+
+func (e *apiChangedError) Error() string {
+	if page := e.Page(); page != "" {
+		return fmt.Sprintf("the site is serving a %s page in place of its API: the last %d %s failed with the wrong shape, the first with: %s", page, len(e.errors), e.kind, e.errors[0].Reason)
+	}
+
+	return fmt.Sprintf("the upstream API appears to have changed: the last %d %s failed with the wrong shape, the first with: %s", len(e.errors), e.kind, e.errors[0].Reason)
+}
+
+// pageAdvice is what to do about a site serving each kind of page in place of
+// its API, which, unlike a change to the API, the scraper can't be updated
+// for.
+var pageAdvice = map[planez.PageKind]string{
+	planez.PageMaintenance: "the site is down for maintenance, try again later",
+	planez.PageCaptcha:     "the site is challenging automated requests, try again later with a lower -rate",
+	planez.PageLogin:       "the site now requires signing in, which the scraper doesn't support",
+}
+
+// Page returns the kind of page every response was, if they were all
+// recognized as the same kind, or an empty string otherwise.
+func (e *apiChangedError) Page() planez.PageKind {
+	page := e.errors[0].Page
+	for _, err := range e.errors[1:] {
+		if err.Page != page {
+			return ""
+		}
+	}
+
+	return page
+}
+
+// Reasons returns the distinct reasons the responses had the wrong shape.
+func (e *apiChangedError) Reasons() []string {
+	reasons := NewSet[string]()
+	for _, err := range e.errors {
+		reasons.Add(err.Reason)
+	}
+
+	values := reasons.Values()
+	slices.Sort(values)
+
+	return values
+}
+
+// shapeWatch watches the fetches of a kind for a run of failures with a
+// *planez.ShapeError. It is safe for concurrent use.
+type shapeWatch struct {
+	kind string
+
+	mu     sync.Mutex
+	streak []*planez.ShapeError
+}
+
+// Observe records the outcome of a fetch, returning an *apiChangedError once
+// apiChangeThreshold fetches in a row have failed with the wrong shape. A
+// fetch that succeeds starts the count over, and one that fails for another
+// reason, such as a timeout, doesn't change it.
+func (w *shapeWatch) Observe(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var shapeErr *planez.ShapeError
+	switch {
+	case err == nil:
+		w.streak = nil
+	case errors.As(err, &shapeErr):
+		w.streak = append(w.streak, shapeErr)
+		if len(w.streak) >= apiChangeThreshold {
+			return &apiChangedError{kind: w.kind, errors: slices.Clone(w.streak)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the names of the environment variables that set flags,
+// such as PLANEZ_SCRAPER_RATE for the scrape's -rate and
+// PLANEZ_SCRAPER_EXPORT_FORMAT for export's -format.
+const envPrefix = "PLANEZ_SCRAPER_"
+
+// repeatableFlags are the flags of each command that are given once for
+// each value, such as -notify, rather than taking a comma separated list.
+// Each item of an array for one of these is given as a flag of its own.
+var repeatableFlags = map[string][]string{
+	"scrape": {"notify"},
+}
+
+// configSetting is one key of a config file, naming a flag of a command.
+// Values holds the items of an array, or the one value of any other key.
+type configSetting struct {
+	Key    string
+	Values []string
+}
+
+// configFile holds the settings of a config file by command. Keys before
+// any table are for the scrape, and the keys of a table such as [export] are
+// for the command it names.
+type configFile map[string][]configSetting
+
+// ConfigPath returns the config file read for every command run in the
+// workspace unless -config names another.
+func (w workspace) ConfigPath() string {
+	return filepath.Join(w.ConfigDir, "config.toml")
+}
+
+// readConfig reads the config file at path, which may have tables for the
+// given commands. A missing file is an empty config unless required is set.
+func readConfig(path string, required bool, commands []string) (configFile, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return configFile{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	config, err := parseConfig(file, commands)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", path, err)
+	}
+
+	return config, nil
+}
+
+// parseConfig parses the subset of TOML used for config files: tables,
+// bare or quoted keys, and values that are strings, numbers, booleans, or
+// single line arrays of them. Errors start with the line number.
+func parseConfig(r io.Reader, commands []string) (configFile, error) {
+	config := make(configFile)
+	command := "scrape"
+	table := false
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name, rest, ok := strings.Cut(line[1:], "]")
+			if rest = strings.TrimSpace(rest); !ok || name == "" || (rest != "" && !strings.HasPrefix(rest, "#")) {
+				return nil, fmt.Errorf("%d: invalid table %s", n, line)
+			}
+
+			command = strings.TrimSpace(name)
+			if command != "scrape" && !slices.Contains(commands, command) {
+				return nil, fmt.Errorf("%d: unknown command [%s]%s", n, command, didYouMean(command, commands))
+			}
+
+			table = true
+
+			continue
+		}
+
+		key, rest, err := parseConfigKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+
+		rest, ok := strings.CutPrefix(strings.TrimSpace(rest), "=")
+		if !ok {
+			return nil, fmt.Errorf("%d: expected = after %s", n, key)
+		}
+
+		values, rest, err := parseConfigValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %v", n, key, err)
+		}
+
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("%d: %s: unexpected %s after the value", n, key, rest)
+		}
+
+		// Global settings are read before the command is, so they can only
+		// come before any table. The ones that choose the config file can't
+		// come from it at all.
+		if name := strings.ReplaceAll(key, "_", "-"); slices.Contains(globalFlags, name) {
+			switch {
+			case name == "config" || name == "local" || name == "profile":
+				return nil, fmt.Errorf("%d: %s can't be set in a config file, since it decides which config file is read", n, key)
+			case command != "scrape" || table:
+				return nil, fmt.Errorf("%d: %s is a global setting, so it goes before any table", n, key)
+			}
+		}
+
+		config[command] = append(config[command], configSetting{Key: key, Values: values})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseConfigKey parses the key at the start of s, returning the rest.
+func parseConfigKey(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return parseConfigString(s)
+	}
+
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	})
+	if end == -1 {
+		end = len(s)
+	}
+
+	if end == 0 {
+		return "", "", fmt.Errorf("expected a key, got %s", s)
+	}
+
+	return s[:end], s[end:], nil
+}
+
+// parseConfigValue parses the value at the start of s, returning it as it
+// would be given to a flag, and the rest. An array returns its items, and
+// any other value returns just the one.
+func parseConfigValue(s string) ([]string, string, error) {
+	switch {
+	case s == "":
+		return nil, "", errors.New("missing value")
+	case s[0] == '"' || s[0] == '\'':
+		value, rest, err := parseConfigString(s)
+		return []string{value}, rest, err
+	case s[0] == '[':
+		var items []string
+		rest := strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(rest, "]") {
+			item, after, err := parseConfigValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+
+			items = append(items, item...)
+			rest = strings.TrimSpace(after)
+			if after, ok := strings.CutPrefix(rest, ","); ok {
+				rest = strings.TrimSpace(after)
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", errors.New("expected , or ] in array")
+			}
+		}
+
+		return items, rest[1:], nil
+	}
+
+	end := strings.IndexAny(s, " \t,]#")
+	if end == -1 {
+		end = len(s)
+	}
+
+	value := s[:end]
+	if _, err := strconv.ParseBool(value); err != nil {
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err != nil {
+			return nil, "", fmt.Errorf("invalid value %s, strings must be quoted", value)
+		}
+
+		value = strings.ReplaceAll(value, "_", "")
+	}
+
+	return []string{value}, s[end:], nil
+}
+
+// parseConfigString parses the basic ("...") or literal ('...') string at
+// the start of s, returning the rest.
+func parseConfigString(s string) (string, string, error) {
+	if s[0] == '\'' {
+		value, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return "", "", errors.New("unterminated string")
+		}
+
+		return value, rest, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", errors.New("unterminated string")
+			}
+
+			switch s[i] {
+			case '"', '\\':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return "", "", fmt.Errorf(`unknown escape \%c`, s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", "", errors.New("unterminated string")
+}
+
+// Args returns the settings for command as flags. Keys name flags with
+// either dashes or underscores, such as image-concurrency or
+// image_concurrency. The items of an array are joined with commas, as list
+// flags expect, except for repeatableFlags, which are given once per item.
+// Global settings are left to GlobalArgs.
+func (c configFile) Args(command string) []string {
+	var args []string
+	for _, setting := range c[command] {
+		name := strings.ReplaceAll(setting.Key, "_", "-")
+		if slices.Contains(globalFlags, name) {
+			continue
+		}
+
+		if slices.Contains(repeatableFlags[command], name) {
+			for _, value := range setting.Values {
+				args = append(args, "-"+name+"="+value)
+			}
+
+			continue
+		}
+
+		args = append(args, "-"+name+"="+strings.Join(setting.Values, ","))
+	}
+
+	return args
+}
+
+// GlobalArgs returns the global settings, such as log-level, as flags to
+// come before the command.
+func (c configFile) GlobalArgs() []string {
+	var args []string
+	for _, setting := range c["scrape"] {
+		if name := strings.ReplaceAll(setting.Key, "_", "-"); slices.Contains(globalFlags, name) {
+			args = append(args, "-"+name+"="+strings.Join(setting.Values, ","))
+		}
+	}
+
+	return args
+}
+
+// envGlobalArgs returns the global options set by environment variables,
+// such as PLANEZ_SCRAPER_LOG_LEVEL, as flags to come before the command.
+func envGlobalArgs(environ []string) []string {
+	var args []string
+	for _, name := range globalFlags {
+		key := envPrefix + envName(name) + "="
+		for _, entry := range environ {
+			if value, ok := strings.CutPrefix(entry, key); ok {
+				args = append(args, "-"+name+"="+value)
+			}
+		}
+	}
+
+	return args
+}
+
+// layeredArgs returns the flags for command from each place they can be
+// set, in the order that lets later ones win: the config file, then the
+// environment, then the flags files, then the command line.
+func layeredArgs(command string, config configFile, environ []string, names []string, configured []string, args []string) []string {
+	return slices.Concat(config.Args(command), envArgs(command, environ, names), configured, args)
+}
+
+// envArgs returns the flags set for command by environment variables,
+// sorted by name. The scrape's variables leave out the ones that start with
+// the name of another command, and the global options.
+func envArgs(command string, environ []string, names []string) []string {
+	prefix := envPrefix
+	if command != "scrape" {
+		prefix += envName(command) + "_"
+	}
+
+	var args []string
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok || name == "" {
+			continue
+		}
+
+		if command == "scrape" && slices.ContainsFunc(names, func(other string) bool { return strings.HasPrefix(name, envName(other)+"_") }) {
+			continue
+		}
+
+		// Global options are left to envGlobalArgs.
+		if command == "scrape" && slices.ContainsFunc(globalFlags, func(global string) bool { return name == envName(global) }) {
+			continue
+		}
+
+		args = append(args, "-"+strings.ReplaceAll(strings.ToLower(name), "_", "-")+"="+value)
+	}
+
+	slices.Sort(args)
+
+	return args
+}
+
+// envName returns a name as it appears in an environment variable.
+func envName(name string) string {
+	return strings.ReplaceAll(strings.ToUpper(name), "-", "_")
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  configFile
+	}{
+		{
+			name:  "bare values",
+			input: "rate = 2\nyes = true\nburst = 1_000\n",
+			want: configFile{"scrape": {
+				{Key: "rate", Values: []string{"2"}},
+				{Key: "yes", Values: []string{"true"}},
+				{Key: "burst", Values: []string{"1000"}},
+			}},
+		},
+		{
+			name:  "basic strings",
+			input: `base-url = "https://example.com/a \"b\" \\ c\td"`,
+			want: configFile{"scrape": {
+				{Key: "base-url", Values: []string{"https://example.com/a \"b\" \\ c\td"}},
+			}},
+		},
+		{
+			name:  "literal strings",
+			input: `out = 'C:\data\planez'`,
+			want: configFile{"scrape": {
+				{Key: "out", Values: []string{`C:\data\planez`}},
+			}},
+		},
+		{
+			name:  "quoted keys",
+			input: `"base-url" = "a"` + "\n" + `'out' = "b"`,
+			want: configFile{"scrape": {
+				{Key: "base-url", Values: []string{"a"}},
+				{Key: "out", Values: []string{"b"}},
+			}},
+		},
+		{
+			name:  "comments",
+			input: "# the scrape\n\nrate = 2 # per second\nout = \"a # b\"\n[export] # exports\nformat = \"csv\"\n",
+			want: configFile{
+				"scrape": {
+					{Key: "rate", Values: []string{"2"}},
+					{Key: "out", Values: []string{"a # b"}},
+				},
+				"export": {
+					{Key: "format", Values: []string{"csv"}},
+				},
+			},
+		},
+		{
+			name:  "arrays",
+			input: "ids = [\"1000-1305\", \"2000-2100\",]\nempty = []\nmixed = [1, 'a', [true]]\n",
+			want: configFile{"scrape": {
+				{Key: "ids", Values: []string{"1000-1305", "2000-2100"}},
+				{Key: "empty", Values: nil},
+				{Key: "mixed", Values: []string{"1", "a", "true"}},
+			}},
+		},
+		{
+			name:  "unknown keys are kept for the command to reject",
+			input: "no-such-flag = 1\n",
+			want: configFile{"scrape": {
+				{Key: "no-such-flag", Values: []string{"1"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(strings.NewReader(tt.input), []string{"export"})
+			if err != nil {
+				t.Fatalf("parseConfig() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unterminated string", `out = "a`, "1: out: unterminated string"},
+		{"unterminated literal string", `out = 'a`, "1: out: unterminated string"},
+		{"unknown escape", `out = "a\qb"`, `1: out: unknown escape \q`},
+		{"unquoted string", "out = data", "1: out: invalid value data, strings must be quoted"},
+		{"missing value", "out =", "1: out: missing value"},
+		{"missing equals", "rate 2", "1: expected = after rate"},
+		{"after the value", `out = "a" "b"`, `1: out: unexpected "b" after the value`},
+		{"unterminated array", `ids = ["a" "b"]`, "1: ids: expected , or ] in array"},
+		{"unknown table", "rate = 1\n[exprot]", "2: unknown command [exprot]"},
+		{"invalid table", "[export", "1: invalid table [export"},
+		{"missing key", `= "a"`, `1: expected a key, got = "a"`},
+		{"global setting in a table", "[export]\nlog-level = \"debug\"", "2: log-level is a global setting, so it goes before any table"},
+		{"profile", `profile = "work"`, "1: profile can't be set in a config file"},
+		{"local", "local = true", "1: local can't be set in a config file"},
+		{"config", `config = "other.toml"`, "1: config can't be set in a config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfig(strings.NewReader(tt.input), []string{"export"})
+			if err == nil {
+				t.Fatalf("parseConfig() error = nil, want %q", tt.want)
+			}
+
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("parseConfig() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigArgs(t *testing.T) {
+	config, err := parseConfig(strings.NewReader(`
+ids = ["1000-1305", "2000-2100"]
+image_concurrency = 2
+notify = ["always:webhook=http://h/a", "always:ntfy=http://h/b"]
+`), nil)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	want := []string{
+		"-ids=1000-1305,2000-2100",
+		"-image-concurrency=2",
+		"-notify=always:webhook=http://h/a",
+		"-notify=always:ntfy=http://h/b",
+	}
+	if got := config.Args("scrape"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Args() = %q, want %q", got, want)
+	}
+}
+
+// parseScrapeFlags parses args with a few of the scrape's flags, returning
+// their values.
+func parseScrapeFlags(t *testing.T, args []string) (float64, string, stringsFlag, error) {
+	t.Helper()
+
+	flags := flag.NewFlagSet("scrape", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	rate := flags.Float64("rate", 0, "")
+	out := flags.String("out", "", "")
+	var notify stringsFlag
+	flags.Var(&notify, "notify", "")
+	err := flags.Parse(args)
+
+	return *rate, *out, notify, err
+}
+
+func TestLayeredArgs(t *testing.T) {
+	config, err := parseConfig(strings.NewReader(`
+rate = 1
+out = "config"
+notify = ["always:webhook=http://h/a", "always:ntfy=http://h/b"]
+
+[export]
+format = "csv"
+`), []string{"export"})
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	names := []string{"export"}
+	tests := []struct {
+		name       string
+		environ    []string
+		configured []string
+		args       []string
+		wantRate   float64
+		wantOut    string
+	}{
+		{
+			name:     "config",
+			wantRate: 1,
+			wantOut:  "config",
+		},
+		{
+			name:     "env over config",
+			environ:  []string{"PLANEZ_SCRAPER_RATE=2", "PLANEZ_SCRAPER_EXPORT_FORMAT=latex", "HOME=/root"},
+			wantRate: 2,
+			wantOut:  "config",
+		},
+		{
+			name:       "flags files over env",
+			environ:    []string{"PLANEZ_SCRAPER_RATE=2"},
+			configured: []string{"-rate=3", "-out=flags"},
+			wantRate:   3,
+			wantOut:    "flags",
+		},
+		{
+			name:       "command line over everything",
+			environ:    []string{"PLANEZ_SCRAPER_RATE=2", "PLANEZ_SCRAPER_OUT=env"},
+			configured: []string{"-rate=3"},
+			args:       []string{"-rate", "4"},
+			wantRate:   4,
+			wantOut:    "env",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := layeredArgs("scrape", config, tt.environ, names, tt.configured, tt.args)
+			rate, out, notify, err := parseScrapeFlags(t, args)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", args, err)
+			}
+
+			if rate != tt.wantRate || out != tt.wantOut {
+				t.Errorf("Parse(%q) rate, out = %g, %q, want %g, %q", args, rate, out, tt.wantRate, tt.wantOut)
+			}
+
+			want := stringsFlag{"always:webhook=http://h/a", "always:ntfy=http://h/b"}
+			if !reflect.DeepEqual(notify, want) {
+				t.Errorf("Parse(%q) notify = %q, want %q", args, notify, want)
+			}
+		})
+	}
+}
+
+func TestLayeredArgsUnknownKey(t *testing.T) {
+	config, err := parseConfig(strings.NewReader("no_such_flag = 1\n"), nil)
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	_, _, _, err = parseScrapeFlags(t, layeredArgs("scrape", config, nil, nil, nil, nil))
+	if err == nil || !strings.Contains(err.Error(), "no-such-flag") {
+		t.Errorf("Parse() error = %v, want an error naming -no-such-flag", err)
+	}
+}
+
+func TestConfigGlobalArgs(t *testing.T) {
+	config, err := parseConfig(strings.NewReader(`
+log_level = "debug"
+rate = 1
+log-format = "json"
+
+[export]
+format = "csv"
+`), []string{"export"})
+	if err != nil {
+		t.Fatalf("parseConfig() error = %v", err)
+	}
+
+	if got, want := config.GlobalArgs(), []string{"-log-level=debug", "-log-format=json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GlobalArgs() = %q, want %q", got, want)
+	}
+
+	// Commands, which reject global options after the command, don't get
+	// them.
+	if got, want := config.Args("scrape"), []string{"-rate=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args(scrape) = %q, want %q", got, want)
+	}
+
+	global, rest, err := parseGlobalArgs(config.GlobalArgs())
+	if err != nil {
+		t.Fatalf("parseGlobalArgs() error = %v", err)
+	}
+
+	if global.LogLevel != "debug" || global.LogFormat != "json" || len(rest) != 0 {
+		t.Errorf("parseGlobalArgs() = %+v, %q, want debug logs as json", global, rest)
+	}
+}
+
+func TestEnvGlobalArgs(t *testing.T) {
+	environ := []string{
+		"PLANEZ_SCRAPER_LOG_LEVEL=warn",
+		"PLANEZ_SCRAPER_PROFILE=work",
+		"PLANEZ_SCRAPER_RATE=2",
+		"PLANEZ_SCRAPER_LOCAL=false",
+	}
+
+	if got, want := envGlobalArgs(environ), []string{"-local=false", "-log-level=warn", "-profile=work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("envGlobalArgs() = %q, want %q", got, want)
+	}
+
+	if got, want := envArgs("scrape", environ, nil), []string{"-rate=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("envArgs(scrape) = %q, want %q", got, want)
+	}
+
+	// The command line comes after the environment, so it wins.
+	global, rest, err := parseGlobalArgs(append(envGlobalArgs(environ), "-log-level", "error", "export", "-format", "csv"))
+	if err != nil {
+		t.Fatalf("parseGlobalArgs() error = %v", err)
+	}
+
+	if global.LogLevel != "error" || global.Profile != "work" || global.Local {
+		t.Errorf("parseGlobalArgs() = %+v, want error logs for profile work", global)
+	}
+
+	if want := []string{"export", "-format", "csv"}; !reflect.DeepEqual(rest, want) {
+		t.Errorf("parseGlobalArgs() left %q, want %q", rest, want)
+	}
+}
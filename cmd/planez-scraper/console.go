@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// ANSI escape codes for the few styles the console uses.
+const (
+	styleReset  = "\x1b[0m"
+	styleBold   = "\x1b[1m"
+	styleDim    = "\x1b[2m"
+	styleRed    = "\x1b[31m"
+	styleGreen  = "\x1b[32m"
+	styleYellow = "\x1b[33m"
+)
+
+type status int
+
+const (
+	statusOK status = iota
+	statusSkip
+	statusWarn
+	statusFail
+)
+
+var statusStyles = map[status]struct{ label, style string }{
+	statusOK:   {"ok", styleGreen},
+	statusSkip: {"skip", styleDim},
+	statusWarn: {"warn", styleYellow},
+	statusFail: {"fail", styleRed},
+}
+
+// console writes the progress of a scrape for a person to read. A line is
+// written for each item as it completes, and warnings are collected into
+// groups so they can be listed together once the run is over rather than
+// lost among the progress lines. On a terminal, a line summarizing the run
+// is kept below them and redrawn as it goes.
+type console struct {
+	w        io.Writer
+	color    bool
+	terminal bool
+
+	// quiet leaves out the line for each item and the live summary, leaving
+	// only the warnings and counts at the end of the run.
+	quiet bool
+
+	mu       sync.Mutex
+	live     *runProgress
+	drawn    bool
+	groups   []string
+	warnings map[string][]string
+}
+
+// progressRedraw is how often the live summary is redrawn when no item has
+// finished, to keep its rate and estimate current.
+const progressRedraw = 250 * time.Millisecond
+
+// newConsole returns a console writing to f, which is colored when f is a
+// terminal unless noColor is set or the NO_COLOR environment variable is
+// non-empty. When quiet is set, only the end of the run is written.
+func newConsole(f *os.File, noColor bool, quiet bool) *console {
+	terminal := isatty.IsTerminal(f.Fd())
+	color := !noColor && os.Getenv("NO_COLOR") == "" && terminal
+	return &console{w: f, color: color, terminal: terminal, quiet: quiet, warnings: make(map[string][]string)}
+}
+
+// ShowProgress keeps a line summarizing p below the progress lines until the
+// returned function is called, if the console is writing to a terminal and
+// isn't quiet. Logs are written above the line while it's shown. The
+// returned function may be called more than once.
+func (c *console) ShowProgress(p *runProgress) func() {
+	if !c.terminal || c.quiet {
+		return func() {}
+	}
+
+	c.mu.Lock()
+	c.live = p
+	c.draw()
+	c.mu.Unlock()
+
+	logOutput.Set(c)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(progressRedraw)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.mu.Lock()
+				c.clear()
+				c.draw()
+				c.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+
+			logOutput.Set(os.Stderr)
+
+			c.mu.Lock()
+			c.clear()
+			c.live = nil
+			c.mu.Unlock()
+		})
+	}
+}
+
+// clear erases the live summary, if it's drawn, so that a line can be
+// written in its place. c.mu must be held.
+func (c *console) clear() {
+	if c.drawn {
+		fmt.Fprint(c.w, "\r\x1b[K")
+		c.drawn = false
+	}
+}
+
+// draw writes the live summary, if it's shown, leaving the cursor at the
+// end of it. c.mu must be held.
+func (c *console) draw() {
+	if c.live != nil {
+		fmt.Fprint(c.w, c.style(styleBold, c.live.Line()))
+		c.drawn = true
+	}
+}
+
+// Write writes p above the live summary, so that other output, such as
+// logs, doesn't break it up.
+func (c *console) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clear()
+	n, err := c.w.Write(p)
+	c.draw()
+
+	return n, err
+}
+
+func (c *console) style(style, s string) string {
+	if !c.color || style == "" {
+		return s
+	}
+
+	return style + s + styleReset
+}
+
+// Status writes a progress line for a single item.
+func (c *console) Status(s status, format string, args ...any) {
+	if c.quiet {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := statusStyles[s]
+	c.clear()
+	fmt.Fprintf(c.w, "%s %s\n", c.style(st.style, fmt.Sprintf("%-4s", st.label)), fmt.Sprintf(format, args...))
+	c.draw()
+}
+
+// Warn writes a progress line for an item with a problem and keeps the
+// message to list under group at the end of the run.
+func (c *console) Warn(s status, group, format string, args ...any) {
+	c.Status(s, format, args...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.warnings[group]; !ok {
+		c.groups = append(c.groups, group)
+	}
+
+	c.warnings[group] = append(c.warnings[group], fmt.Sprintf(format, args...))
+}
+
+// summaryRow is one line of the table written at the end of a run. Rows with
+// a zero count are left out when optional is set, and a non-zero count is
+// highlighted with style.
+type summaryRow struct {
+	label    string
+	count    int
+	detail   string
+	style    string
+	optional bool
+}
+
+// Summary writes the collected warnings, grouped in the order they were
+// first seen, followed by a table of the run's counts.
+func (c *console) Summary(rows []summaryRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clear()
+	for _, group := range c.groups {
+		messages := c.warnings[group]
+		fmt.Fprintf(c.w, "\n%s\n", c.style(styleBold, fmt.Sprintf("%s (%d)", group, len(messages))))
+		for _, message := range messages {
+			fmt.Fprintf(c.w, "  %s\n", message)
+		}
+	}
+
+	labelWidth, countWidth := 0, 0
+	for _, row := range rows {
+		labelWidth = max(labelWidth, len(row.label))
+		countWidth = max(countWidth, len(fmt.Sprint(row.count)))
+	}
+
+	fmt.Fprintln(c.w)
+	for _, row := range rows {
+		if row.optional && row.count == 0 {
+			continue
+		}
+
+		count := fmt.Sprintf("%*d", countWidth, row.count)
+		if row.count > 0 {
+			count = c.style(row.style, count)
+		}
+
+		line := fmt.Sprintf("%-*s  %s", labelWidth, row.label, count)
+		if row.detail != "" {
+			line += "  " + c.style(styleDim, row.detail)
+		}
+
+		fmt.Fprintln(c.w, strings.TrimRight(line, " "))
+	}
+}
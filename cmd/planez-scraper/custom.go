@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// customAnswerHeading separates a custom question from its answer.
+const customAnswerHeading = "## Answer"
+
+// loadCustomQuestions reads the user-authored questions in dir, which is
+// fine to be missing. Each is a Markdown file with front matter, described in
+// the README. Custom questions are given negative IDs in the order of their
+// paths so they never clash with scraped ones, and their images are returned
+// keyed by ImageFile, stored relative to dataDir.
+func loadCustomQuestions(dir string, dataDir string) ([]Question, map[string]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to read custom questions: %v", err)
+	}
+
+	slices.Sort(paths)
+
+	var questions []Question
+	images := make(map[string]string)
+	for i, path := range paths {
+		q, image, err := readCustomQuestion(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		q.QuestionID = -(i + 1)
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			q.CustomSource = filepath.ToSlash(rel)
+		}
+
+		if image != "" {
+			stored, err := relativeTo(dataDir, image)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: failed to find image %s: %v", path, image, err)
+			}
+
+			name := filepath.ToSlash(stored)
+			q.ImageFile = &name
+			images[name] = name
+		}
+
+		questions = append(questions, q)
+	}
+
+	return questions, images, nil
+}
+
+// readCustomQuestion parses a custom question file, returning the question
+// and the path of its image, if it has one.
+func readCustomQuestion(path string) (Question, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Question{}, "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Question{}, "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	q := Question{planezQuestion: planezQuestion{Type: planez.TypeAll, CreatedDate: int(info.ModTime().UnixMilli())}}
+	var image string
+	var question, answer []string
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return Question{}, "", fmt.Errorf("%s:1: expected front matter starting with ---", path)
+	}
+
+	n := 1
+	for closed := false; !closed; {
+		if !scanner.Scan() {
+			return Question{}, "", fmt.Errorf("%s: front matter is not closed with ---", path)
+		}
+
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "---" {
+			closed = true
+			continue
+		} else if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return Question{}, "", fmt.Errorf("%s:%d: expected key: value, got %q", path, n, line)
+		}
+
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		switch key {
+		case "certificate":
+			q.Certificate = planez.Certificate(strings.ToUpper(value))
+		case "type":
+			q.Type = planez.QuestionType(strings.ToUpper(value))
+		case "image":
+			image = filepath.Join(filepath.Dir(path), filepath.FromSlash(value))
+		default:
+			return Question{}, "", fmt.Errorf("%s:%d: unknown key %q, expected certificate, type, or image", path, n, key)
+		}
+	}
+
+	inAnswer := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if !inAnswer && strings.TrimSpace(line) == customAnswerHeading {
+			inAnswer = true
+		} else if inAnswer {
+			answer = append(answer, line)
+		} else {
+			question = append(question, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Question{}, "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	q.Question = strings.TrimSpace(strings.Join(question, "\n"))
+	q.Answer = strings.TrimSpace(strings.Join(answer, "\n"))
+
+	if q.Certificate == "" {
+		return Question{}, "", fmt.Errorf("%s: front matter is missing a certificate", path)
+	}
+
+	if q.Question == "" {
+		return Question{}, "", fmt.Errorf("%s: question text is empty", path)
+	}
+
+	if image != "" {
+		if _, err := os.Stat(image); err != nil {
+			return Question{}, "", fmt.Errorf("%s: failed to find image: %v", path, err)
+		}
+	}
+
+	return q, image, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var diffFormats = []string{"text", "json"}
+
+// questionDiff describes how a questions file differs from an older one.
+type questionDiff struct {
+	Added   []Question       `json:"added"`
+	Removed []Question       `json:"removed"`
+	Changed []questionChange `json:"changed"`
+}
+
+// questionChange lists the fields of a question that differ between two
+// versions of it.
+type questionChange struct {
+	QuestionID int           `json:"questionId"`
+	Fields     []fieldChange `json:"fields"`
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Any reports whether there are any differences.
+func (d questionDiff) Any() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// previousQuestionsPath returns where a scrape keeps the questions file it
+// replaces, such as questions.previous.json beside questions.json.
+func previousQuestionsPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".previous" + ext
+}
+
+// keepPreviousQuestions copies the questions file at path to its previous
+// path, before a run replaces it. There is nothing to keep on the first run.
+func keepPreviousQuestions(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	return replaceFile(previousQuestionsPath(path), func(w io.Writer) error {
+		_, err := io.Copy(w, file)
+		return err
+	})
+}
+
+// diffQuestions compares the questions in current to those in previous by
+// ID. Each list is sorted by question ID.
+func diffQuestions(previous []Question, current []Question) questionDiff {
+	diff := questionDiff{Added: []Question{}, Removed: []Question{}, Changed: []questionChange{}}
+
+	before := make(map[int]Question, len(previous))
+	for _, q := range previous {
+		before[q.QuestionID] = q
+	}
+
+	after := NewSet[int]()
+	for _, q := range current {
+		after.Add(q.QuestionID)
+
+		old, ok := before[q.QuestionID]
+		if !ok {
+			diff.Added = append(diff.Added, q)
+			continue
+		}
+
+		if fields := changedFields(old, q); len(fields) > 0 {
+			change := questionChange{QuestionID: q.QuestionID}
+			for _, field := range fields {
+				change.Fields = append(change.Fields, fieldChange{Field: field, Old: fieldValue(old, field), New: fieldValue(q, field)})
+			}
+
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+
+	for _, q := range previous {
+		if !after.Contains(q.QuestionID) {
+			diff.Removed = append(diff.Removed, q)
+		}
+	}
+
+	slices.SortFunc(diff.Added, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+	slices.SortFunc(diff.Removed, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+	slices.SortFunc(diff.Changed, func(a, b questionChange) int { return a.QuestionID - b.QuestionID })
+
+	return diff
+}
+
+// fieldValue returns a field named by changedFields as text.
+func fieldValue(q Question, field string) string {
+	switch field {
+	case "question":
+		return q.Question
+	case "answer":
+		return q.Answer
+	case "certificate":
+		return string(q.Certificate)
+	case "type":
+		return string(q.Type)
+	case "createdDate":
+		return strconv.Itoa(q.CreatedDate)
+	case "imageFile":
+		if q.ImageFile == nil {
+			return ""
+		}
+
+		return *q.ImageFile
+	}
+
+	return ""
+}
+
+// wordTokens splits s into runs of spaces and runs of other characters, so
+// that joining the tokens gives s back.
+func wordTokens(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+
+		if i == start {
+			space = unicode.IsSpace(r)
+		}
+	}
+
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+
+	return tokens
+}
+
+// wordDiff marks the words removed from old as [-words-] and the words added
+// in new as {+words+}, like git diff --word-diff, leaving the words they
+// share as they are.
+func wordDiff(old, new string) string {
+	a, b := wordTokens(old), wordTokens(new)
+
+	// lengths[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var out, removed, added strings.Builder
+	flush := func() {
+		if removed.Len() > 0 {
+			fmt.Fprintf(&out, "[-%s-]", removed.String())
+			removed.Reset()
+		}
+
+		if added.Len() > 0 {
+			fmt.Fprintf(&out, "{+%s+}", added.String())
+			added.Reset()
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			out.WriteString(a[i])
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lengths[i+1][j] >= lengths[i][j+1]):
+			removed.WriteString(a[i])
+			i++
+		default:
+			added.WriteString(b[j])
+			j++
+		}
+	}
+
+	flush()
+
+	return out.String()
+}
+
+// writeQuestionDiff writes the differences for a person to read: a line per
+// added or removed question, and the changed fields of each changed
+// question, with the words that changed in its text marked.
+func writeQuestionDiff(w io.Writer, diff questionDiff) error {
+	fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+
+	for _, q := range diff.Added {
+		fmt.Fprintf(w, "\n+ %d [%s] %s\n", q.QuestionID, q.Certificate, truncate(stripHTML(q.Question), 80))
+	}
+
+	for _, q := range diff.Removed {
+		fmt.Fprintf(w, "\n- %d [%s] %s\n", q.QuestionID, q.Certificate, truncate(stripHTML(q.Question), 80))
+	}
+
+	for _, change := range diff.Changed {
+		fmt.Fprintf(w, "\n~ %d\n", change.QuestionID)
+		for _, field := range change.Fields {
+			if field.Field == "question" || field.Field == "answer" {
+				fmt.Fprintf(w, "  %s: %s\n", field.Field, wordDiff(field.Old, field.New))
+			} else {
+				fmt.Fprintf(w, "  %s: %s -> %s\n", field.Field, field.Old, field.New)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	questionsPath := flags.String("questions", filepath.Join(activeWorkspace.DataDir(), "questions.json"), "Questions file to compare with the one it replaced, when no files are given")
+	format := flags.String("format", "text", "Format to write: "+strings.Join(diffFormats, ", "))
+	exitCode := flags.Bool("exit-code", false, "Exit non-zero if the files differ")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper diff [flags] [OLD NEW]")
+		fmt.Fprintln(flags.Output(), "Without files, compares the questions with those the last scrape replaced.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	oldPath, newPath := previousQuestionsPath(*questionsPath), *questionsPath
+	if flags.NArg() == 2 {
+		oldPath, newPath = flags.Arg(0), flags.Arg(1)
+	}
+
+	var v validator
+	v.Check(flags.NArg() == 0 || flags.NArg() == 2, "expected an old and a new questions file, got %d arguments", flags.NArg())
+	v.Check(slices.Contains(diffFormats, *format), "-format: unknown format %q%s", *format, didYouMean(*format, diffFormats))
+	if flags.NArg() == 0 {
+		v.CheckFile("-questions", newPath)
+		_, err := os.Stat(oldPath)
+		v.Check(!errors.Is(err, fs.ErrNotExist), "no previous questions to compare with, a scrape keeps them in %s from the second run on", oldPath)
+	} else {
+		v.CheckFile("OLD", oldPath)
+		v.CheckFile("NEW", newPath)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	previous, err := readQuestions(oldPath)
+	if err != nil {
+		return err
+	}
+
+	current, err := readQuestions(newPath)
+	if err != nil {
+		return err
+	}
+
+	diff := diffQuestions(previous, current)
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = writeQuestionDiff(os.Stdout, diff)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if *exitCode && diff.Any() {
+		return errors.New("the questions differ")
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// discoveredBlock is a block of question IDs found by discover. Probed is
+// how many IDs in or around it were requested, and Found how many of those
+// exist. When probing outward from a known range, the IDs within the range
+// aren't requested again.
+type discoveredBlock struct {
+	idRange
+	Probed int
+	Found  int
+}
+
+// prober requests question IDs to check whether they exist.
+type prober struct {
+	client *http.Client
+}
+
+// exists reports whether the question with the given ID exists. Only a 404
+// counts as missing; any other failure is logged and treated as missing too,
+// so that a broken site doesn't make a block look endless.
+func (p prober) exists(id int) bool {
+	_, _, err := fetchWithRules(context.Background(), slog.With("question_id", id), statusRules{}, func(ctx context.Context) (Question, error) {
+		return scrape(ctx, apiClient(p.client), NewSet[string](), id)
+	})
+
+	var statusErr *planez.StatusError
+	if err == nil {
+		return true
+	} else if !errors.As(err, &statusErr) || statusErr.Status != http.StatusNotFound {
+		slog.Warn("Failed to probe question", "question_id", id, statusAttr(err), "error", err)
+	}
+
+	return false
+}
+
+// walk probes IDs from start in the direction of step until gap consecutive
+// IDs are missing, or the IDs run out below zero. It returns the last ID
+// found, or false if none were.
+func (p prober) walk(start int, step int, gap int, block *discoveredBlock) (int, bool) {
+	last, found := 0, false
+	for id, misses := start, 0; id >= 0 && misses < gap; id += step {
+		block.Probed++
+		if p.exists(id) {
+			block.Found++
+			last, found, misses = id, true, 0
+		} else {
+			misses++
+		}
+	}
+
+	return last, found
+}
+
+// extend probes outward from each end of a known range, returning the block
+// with its ends moved out to the furthest IDs found.
+func (p prober) extend(known idRange, gap int) discoveredBlock {
+	block := discoveredBlock{idRange: known}
+	if low, ok := p.walk(known.Start-1, -1, gap, &block); ok {
+		block.Start = low
+	}
+
+	if high, ok := p.walk(known.End+1, 1, gap, &block); ok {
+		block.End = high
+	}
+
+	return block
+}
+
+// scan probes every ID in a span, splitting it into blocks wherever gap
+// consecutive IDs are missing.
+func (p prober) scan(span idRange, gap int) []discoveredBlock {
+	var blocks []discoveredBlock
+	var current *discoveredBlock
+	flush := func() {
+		if current != nil {
+			current.Probed = current.End - current.Start + 1
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+
+	misses := 0
+	for id := span.Start; id <= span.End; id++ {
+		if !p.exists(id) {
+			if misses++; misses >= gap {
+				flush()
+			}
+
+			continue
+		}
+
+		misses = 0
+		if current == nil {
+			current = &discoveredBlock{idRange: idRange{Start: id}}
+		}
+
+		current.End = id
+		current.Found++
+	}
+
+	flush()
+
+	return blocks
+}
+
+func runDiscover(args []string) error {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	flags.StringVar(&baseURL, "base-url", defaultBaseURL, "Base URL of the site to discover question IDs on")
+	idSpec := flags.String("ids", defaultIDs, "Known question ID ranges to probe outward from")
+	spanSpec := flags.String("span", "", "Scan every ID in this range instead of probing outward from -ids, e.g. 1-5000")
+	gap := flags.Int("gap", 10, "Number of consecutive missing IDs that ends a block")
+	rate := flags.Float64("rate", defaultRate, "Maximum requests per second to the site (0 for no limit)")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	known, err := parseIDRanges(*idSpec)
+	v.CheckErr("-ids", err)
+	var span idRanges
+	if *spanSpec != "" {
+		span, err = parseIDRanges(*spanSpec)
+		v.CheckErr("-span", err)
+		v.Check(err != nil || len(span) == 1, "-span: expected a single range, got %s", span)
+	}
+	v.Check(*gap > 0, "-gap: must be at least 1, got %d", *gap)
+	v.CheckErr("-base-url", checkBaseURL(baseURL))
+	v.Check(*rate >= 0, "-rate: must not be negative, got %g", *rate)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	layers := []middleware{withRetries(statusRules{}, retryPolicy{maxAttempts: 3, delay: time.Second})}
+	if *rate > 0 {
+		layers = append(layers, withRateLimit(*rate, 1))
+	}
+
+	p := prober{client: &http.Client{Transport: chain(newBaseTransport(defaultConnectTimeout), layers...), Timeout: defaultRequestTimeout}}
+
+	var blocks []discoveredBlock
+	if span != nil {
+		slog.Info("Scanning for questions", "ids", span.String())
+		blocks = p.scan(span[0], *gap)
+	} else {
+		slog.Info("Probing outward for questions", "known", known.String())
+		for _, r := range known {
+			blocks = append(blocks, p.extend(r, *gap))
+		}
+	}
+
+	var ranges []idRange
+	for _, block := range blocks {
+		ranges = append(ranges, block.idRange)
+	}
+
+	discovered := mergeIDRanges(ranges)
+	if len(discovered) == 0 {
+		return errors.New("no questions found")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BLOCK\tPROBED\tFOUND")
+	for _, block := range blocks {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", idRanges{block.idRange}, block.Probed, block.Found)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n-ids %s\n", discovered)
+
+	return nil
+}
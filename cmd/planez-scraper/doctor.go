@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// doctorQuestionID is a question known to exist upstream that references an
+// image, so a single fetch exercises both endpoints.
+const doctorQuestionID = 1006
+
+// errSkipped marks a check that could not run because an earlier one failed or
+// didn't apply.
+type errSkipped string
+
+func (e errSkipped) Error() string {
+	return string(e)
+}
+
+type doctorCheck struct {
+	name string
+	run  func() (string, error)
+}
+
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	baseURL := flags.String("base-url", defaultBaseURL, "Base URL of the site to check")
+	questionID := flags.Int("question", doctorQuestionID, "Question to fetch")
+	timeout := flags.Duration("timeout", 30*time.Second, "Timeout for each request")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.CheckErr("-base-url", checkBaseURL(*baseURL))
+	v.Check(*timeout > 0, "-timeout: must be positive, got %s", *timeout)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	api := planez.NewClient(*baseURL, &http.Client{Timeout: *timeout})
+
+	tmp, err := os.MkdirTemp("", "planez-doctor-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	var question Question
+
+	checks := []doctorCheck{
+		{"data directory is writable", func() (string, error) {
+			return checkWritable(activeWorkspace.DataDir())
+		}},
+		{"state directory is writable", func() (string, error) {
+			return checkWritable(activeWorkspace.Dir)
+		}},
+		{"cache directory is writable", func() (string, error) {
+			return checkWritable(defaultCacheDir())
+		}},
+		{"resolve upstream host", func() (string, error) {
+			u, _ := url.Parse(api.BaseURL)
+			addrs, err := net.LookupHost(u.Hostname())
+			if err != nil {
+				return "", err
+			}
+
+			return strings.Join(addrs, ", "), nil
+		}},
+		{fmt.Sprintf("fetch question %d", *questionID), func() (string, error) {
+			question, err = scrape(context.Background(), api, NewSet[string](), *questionID)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("%s/%s", question.Certificate, question.Type), nil
+		}},
+		{"write question", func() (string, error) {
+			if question.QuestionID == 0 {
+				return "", errSkipped("no question was fetched")
+			}
+
+			data, err := json.MarshalIndent(question, "", "  ")
+			if err != nil {
+				return "", err
+			}
+
+			return "", os.WriteFile(filepath.Join(tmp, "question.json"), data, 0644)
+		}},
+		{"fetch and write image", func() (string, error) {
+			if question.QuestionID == 0 {
+				return "", errSkipped("no question was fetched")
+			}
+
+			if question.ImageFile == nil {
+				return "", errSkipped("the question does not reference an image")
+			}
+
+			return readImage(context.Background(), api, *question.ImageFile, planez.ImageStore{Dir: tmp})
+		}},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Checking %s\n", api.BaseURL)
+
+	failures := 0
+	for _, check := range checks {
+		start := time.Now()
+		detail, err := check.run()
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		var skipped errSkipped
+		if errors.As(err, &skipped) {
+			fmt.Fprintf(w, "  skip\t%s\t\t%v\n", check.name, err)
+		} else if err != nil {
+			failures++
+			fmt.Fprintf(w, "  FAIL\t%s\t%s\t%v\n", check.name, elapsed, err)
+		} else {
+			fmt.Fprintf(w, "  ok\t%s\t%s\t%s\n", check.name, elapsed, detail)
+		}
+	}
+
+	w.Flush()
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d checks failed", failures, len(checks))
+	}
+
+	fmt.Println("All checks passed")
+
+	return nil
+}
+
+// checkWritable checks that files can be created in dir, or, if it doesn't
+// exist yet, in the closest directory above it that does, where a run would
+// create it. The detail is the directory checked.
+func checkWritable(dir string) (string, error) {
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil && !info.IsDir() {
+			return "", fmt.Errorf("%s is not a directory", existing)
+		} else if err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", err
+		}
+
+		existing = parent
+	}
+
+	file, err := os.CreateTemp(existing, ".planez-doctor-")
+	if err != nil {
+		return "", err
+	}
+
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return "", err
+	}
+
+	if existing != dir {
+		return fmt.Sprintf("%s (to be created in %s)", dir, existing), nil
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"filippo.io/age"
+)
+
+const ageHeader = "age-encryption.org/v1"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encryptionExtension returns the file extension conventionally used for
+// output encrypted with the given spec.
+func encryptionExtension(spec string) string {
+	method, _, _ := strings.Cut(spec, ":")
+	return "." + method
+}
+
+func parseEncryptionSpec(spec string) (string, string, error) {
+	method, recipient, ok := strings.Cut(spec, ":")
+	if !ok || recipient == "" {
+		return "", "", fmt.Errorf("invalid encryption %q, expected age:RECIPIENT or gpg:RECIPIENT", spec)
+	}
+
+	if method != "age" && method != "gpg" {
+		return "", "", fmt.Errorf("unknown encryption method %q, expected age or gpg", method)
+	}
+
+	return method, recipient, nil
+}
+
+// encryptWriter wraps w so that everything written to the returned writer
+// is encrypted for the recipient in spec. The returned writer must be closed
+// to flush the encrypted output.
+func encryptWriter(spec string, w io.Writer) (io.WriteCloser, error) {
+	method, recipient, err := parseEncryptionSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if method == "age" {
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient: %v", err)
+		}
+
+		return age.Encrypt(w, r)
+	}
+
+	return startGPG(w, "--encrypt", "--recipient", recipient)
+}
+
+// decryptReader returns a reader over the plaintext of r. Unencrypted gzip
+// data is passed through unchanged, age data is decrypted with the
+// identities in identityFile, and anything else is handed to gpg.
+func decryptReader(r io.Reader, identityFile string) (io.Reader, func() error, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(ageHeader))
+
+	if bytes.HasPrefix(head, gzipMagic) {
+		return br, func() error { return nil }, nil
+	}
+
+	if string(head) == ageHeader {
+		if identityFile == "" {
+			return nil, nil, fmt.Errorf("archive is encrypted with age, use -identity to decrypt it")
+		}
+
+		file, err := os.Open(identityFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %v", identityFile, err)
+		}
+
+		defer file.Close()
+
+		identities, err := age.ParseIdentities(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", identityFile, err)
+		}
+
+		plain, err := age.Decrypt(br, identities...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt archive: %v", err)
+		}
+
+		return plain, func() error { return nil }, nil
+	}
+
+	return gpgDecrypt(br)
+}
+
+type gpgWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (w *gpgWriter) Close() error {
+	if err := w.WriteCloser.Close(); err != nil {
+		return err
+	}
+
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("gpg failed: %v", err)
+	}
+
+	return nil
+}
+
+func startGPG(w io.Writer, args ...string) (io.WriteCloser, error) {
+	cmd := exec.Command("gpg", append([]string{"--batch", "--yes", "--output", "-"}, args...)...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start gpg: %v", err)
+	}
+
+	return &gpgWriter{WriteCloser: stdin, cmd: cmd}, nil
+}
+
+func gpgDecrypt(r io.Reader) (io.Reader, func() error, error) {
+	cmd := exec.Command("gpg", "--batch", "--decrypt", "--output", "-")
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start gpg: %v", err)
+	}
+
+	wait := func() error {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("gpg failed: %v", err)
+		}
+
+		return nil
+	}
+
+	return stdout, wait, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// sampleStats totals the requests of one kind made while sampling.
+type sampleStats struct {
+	requests  int
+	successes int
+	bytes     int64
+	elapsed   time.Duration
+}
+
+func (s *sampleStats) record(bytes int64, elapsed time.Duration, err error) {
+	s.requests++
+	s.bytes += bytes
+	s.elapsed += elapsed
+	if err == nil {
+		s.successes++
+	}
+}
+
+// perRequest returns the average bytes and time taken by each request, or
+// zero if none were made.
+func (s sampleStats) perRequest() (float64, time.Duration) {
+	if s.requests == 0 {
+		return 0, 0
+	}
+
+	return float64(s.bytes) / float64(s.requests), s.elapsed / time.Duration(s.requests)
+}
+
+func runEstimate(args []string) error {
+	flags := flag.NewFlagSet("estimate", flag.ExitOnError)
+	flags.StringVar(&baseURL, "base-url", defaultBaseURL, "Base URL of the site to estimate a scrape of")
+	idSpec := flags.String("ids", defaultIDs, "Comma separated question IDs and ranges the scrape will cover")
+	n := flags.Int("n", 10, "Number of questions to sample")
+	seed := flags.Int64("seed", 0, "Seed for choosing the sample (default random)")
+	rate := flags.Float64("rate", defaultRate, "Requests per second the scrape will be run with (0 for no limit)")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	ids, err := parseIDRanges(*idSpec)
+	v.CheckErr("-ids", err)
+	total := ids.Len()
+	if err == nil {
+		v.Check(*n > 0 && *n <= total, "-n: must be between 1 and %d, got %d", total, *n)
+	}
+	v.CheckErr("-base-url", checkBaseURL(baseURL))
+	v.Check(*rate >= 0, "-rate: must not be negative, got %g", *rate)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	metrics := &requestMetrics{}
+	layers := []middleware{withMetrics(metrics)}
+	if *rate > 0 {
+		layers = append(layers, withRateLimit(*rate, 1))
+	}
+
+	client := &http.Client{Transport: chain(newBaseTransport(defaultConnectTimeout), layers...), Timeout: defaultRequestTimeout}
+
+	tmp, err := os.MkdirTemp("", "planez-estimate-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+
+	defer os.RemoveAll(tmp)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	candidates := ids.IDs()
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	var questions, images sampleStats
+	imgCache := NewSet[string]()
+	for _, id := range candidates[:*n] {
+		before, start := metrics.Bytes(), time.Now()
+		_, err := scrape(context.Background(), apiClient(client), imgCache, id)
+		questions.record(metrics.Bytes()-before, time.Since(start), err)
+	}
+
+	for _, image := range imgCache.Values() {
+		before, start := metrics.Bytes(), time.Now()
+		_, err := readImage(context.Background(), apiClient(client), image, planez.ImageStore{Dir: tmp})
+		images.record(metrics.Bytes()-before, time.Since(start), err)
+	}
+
+	if questions.successes == 0 {
+		return fmt.Errorf("none of the %d sampled questions could be fetched", *n)
+	}
+
+	questionBytes, questionTime := questions.perRequest()
+	imageBytes, imageTime := images.perRequest()
+
+	// Every ID in the range is requested, but only those that exist can
+	// reference an image.
+	found := total * questions.successes / questions.requests
+	imageCount := found * imgCache.Len() / questions.successes
+	bytes := questionBytes*float64(total) + imageBytes*float64(imageCount)
+
+	// With a rate limit, requests can't start more often than the limit
+	// allows, however quickly the site responds.
+	pace := "no limit"
+	if *rate > 0 {
+		interval := time.Duration(float64(time.Second) / *rate)
+		questionTime = max(questionTime, interval)
+		imageTime = max(imageTime, interval)
+		pace = fmt.Sprintf("%g per second", *rate)
+	}
+
+	duration := questionTime*time.Duration(total) + imageTime*time.Duration(imageCount)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Range\t%s (%d IDs)\n", ids, total)
+	fmt.Fprintf(w, "Sampled\t%d questions and %d images (seed %d)\n", questions.requests, images.requests, *seed)
+	fmt.Fprintf(w, "Questions\t~%d\n", found)
+	fmt.Fprintf(w, "Images\t~%d\n", imageCount)
+	fmt.Fprintf(w, "Requests\t~%d\n", total+imageCount)
+	fmt.Fprintf(w, "Download\t~%s\n", formatBytes(int64(bytes)))
+	fmt.Fprintf(w, "Duration\t~%s at %s\n", duration.Round(time.Second), pace)
+
+	return w.Flush()
+}
+
+// formatBytes formats a byte count with a binary unit, such as "4.2 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// exportDataset is everything an exporter has to work with.
+type exportDataset struct {
+	Questions   []Question
+	Images      map[string]string
+	GeneratedAt time.Time
+
+	// DataDir is the path to the data directory, relative to the directory
+	// the export is written to, so exports can link to images.
+	DataDir string
+
+	// SourceDir is the path to the data directory as given, for exporters
+	// that bundle images into their output.
+	SourceDir string
+}
+
+// ImagePath returns the stored path of the question's image relative to
+// the data directory, or an empty string if it has none.
+func (d exportDataset) ImagePath(q Question) string {
+	if q.ImageFile == nil {
+		return ""
+	}
+
+	if stored, ok := d.Images[*q.ImageFile]; ok {
+		return stored
+	}
+
+	return filepath.ToSlash(filepath.Join("images", *q.ImageFile))
+}
+
+type exportOptions struct {
+	Template   string
+	LaTeXClass string
+	Answers    bool
+
+	// Attribution is a notice of where the questions came from, which
+	// formats print near the top of the export. It is empty unless
+	// -attribution is given.
+	Attribution string
+}
+
+// dataFormats are the formats that hold only the questions, with nowhere to
+// put anything else.
+var dataFormats = []string{"csv", "json", "jsonl"}
+
+type exporter func(w io.Writer, data exportDataset, opts exportOptions) error
+
+var exporters = map[string]exporter{
+	"csv":      exportCSV,
+	"json":     exportJSON,
+	"jsonl":    exportJSONL,
+	"latex":    exportLaTeX,
+	"mochi":    exportMochi,
+	"org":      exportOrg,
+	"remnote":  exportRemNote,
+	"template": exportTemplate,
+}
+
+func exportFormats() []string {
+	formats := make([]string, 0, len(exporters))
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+
+	slices.Sort(formats)
+
+	return formats
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := flags.String("data", activeWorkspace.DataDir(), "Directory containing scraped data")
+	format := flags.String("format", "json", "Output format: "+strings.Join(exportFormats(), ", "))
+	out := flags.String("o", "", "Path to write the export to (default stdout)")
+	var opts exportOptions
+	flags.StringVar(&opts.Template, "template", "", "Go text/template file to render with -format template")
+	flags.StringVar(&opts.LaTeXClass, "latex-class", "article", "Document class for -format latex: article, or exam for an exam-style booklet")
+	flags.BoolVar(&opts.Answers, "answers", true, "Include answers in formats that can leave them out")
+	normalize := flags.String("normalize", "", "How to clean question text for formats that take it: "+strings.Join(normalizeProfiles, ", ")+" (default depends on -format)")
+	truncate := flags.Int("truncate", 0, "Longest question or answer text in characters for -format "+strings.Join(truncateFormats, ", ")+", with the rest written to files beside -o (0 for no limit)")
+	strip := flags.String("strip", "", "Comma separated fields to remove before exporting: "+strings.Join(stripFields, ", "))
+	localIDs := flags.String("local-ids", "", "Assign each question a stable local ID: "+strings.Join(localIDSchemes, ", "))
+	notesPath := flags.String("notes", activeWorkspace.NotesPath(), "File of personal notes to add to the export (empty for none)")
+	groupNotes := flags.Bool("group-notes", true, "Add the notes study partners shared through sync, marked with their names")
+	custom := flags.String("custom", activeWorkspace.CustomDir(), "Directory of custom questions to add to the export (empty for none)")
+	starredOnly := flags.Bool("starred-only", false, "Only export questions starred with the star command")
+	attribute := flags.Bool("attribution", false, "Add a notice of where the questions came from, for exports that will be shared")
+	flags.Parse(args)
+
+	write, ok := exporters[*format]
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.Check(ok, "-format: unknown format %q%s", *format, didYouMean(*format, exportFormats()))
+	v.CheckFile("-data", filepath.Join(*dir, "questions.json"))
+	if *format == "template" {
+		v.Check(opts.Template != "", "-template: required with -format template")
+	} else {
+		v.Check(opts.Template == "", "-template: only used with -format template")
+	}
+	if opts.Template != "" {
+		v.CheckFile("-template", opts.Template)
+	}
+	v.Check(opts.LaTeXClass == "article" || opts.LaTeXClass == "exam", "-latex-class: expected article or exam, got %q", opts.LaTeXClass)
+	if *normalize != "" {
+		_, takesProfile := defaultProfiles[*format]
+		v.Check(!ok || takesProfile, "-normalize: not supported by -format %s", *format)
+		v.Check(slices.Contains(normalizeProfiles, *normalize), "-normalize: unknown profile %q%s", *normalize, didYouMean(*normalize, normalizeProfiles))
+	} else {
+		*normalize = defaultProfiles[*format]
+	}
+	if *truncate != 0 {
+		v.Check(!ok || slices.Contains(truncateFormats, *format), "-truncate: not supported by -format %s", *format)
+		v.Check(*truncate >= minTruncateLimit, "-truncate: expected a limit of at least %d characters, got %d", minTruncateLimit, *truncate)
+		v.Check(*out != "", "-truncate: requires -o, to write the full text beside")
+	}
+	stripped, err := parseStripFields(*strip)
+	v.CheckErr("-strip", err)
+	v.Check(!*attribute || !slices.Contains(dataFormats, *format), "-attribution: not supported by -format %s", *format)
+	if *localIDs != "" {
+		v.Check(slices.Contains(localIDSchemes, *localIDs), "-local-ids: unknown scheme %q%s", *localIDs, didYouMean(*localIDs, localIDSchemes))
+	}
+	if *out != "" {
+		v.CheckParentDir("-o", *out)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	data, err := loadExportDataset(*dir)
+	if err != nil {
+		return err
+	}
+
+	if *custom != "" {
+		questions, images, err := loadCustomQuestions(*custom, *dir)
+		if err != nil {
+			return err
+		}
+
+		data.Questions = append(data.Questions, questions...)
+		maps.Copy(data.Images, images)
+	}
+
+	// Local IDs are assigned before any questions are filtered out, since
+	// the IDs of duplicate questions depend on the others like them.
+	if *localIDs != "" {
+		assignLocalIDs(data.Questions, *localIDs)
+	}
+
+	if *notesPath != "" {
+		notes, err := readNotes(*notesPath)
+		if err != nil {
+			return err
+		}
+
+		applyNotes(data.Questions, notes)
+	}
+
+	if *groupNotes {
+		group, err := readGroupNotes(activeWorkspace.GroupDir())
+		if err != nil {
+			return err
+		}
+
+		for _, partner := range slices.Sorted(maps.Keys(group)) {
+			for _, list := range group[partner] {
+				for i := range list {
+					list[i].Text = partner + ": " + list[i].Text
+				}
+			}
+
+			applyNotes(data.Questions, group[partner])
+		}
+	}
+
+	if *starredOnly {
+		stars, err := readStars(activeWorkspace.StarsPath())
+		if err != nil {
+			return err
+		}
+
+		data.Questions = slices.DeleteFunc(data.Questions, func(q Question) bool { return !stars.Contains(q.QuestionID) })
+	}
+
+	if *attribute {
+		opts.Attribution = attribution(data)
+	}
+
+	if slices.Contains(stripped, "answers") {
+		opts.Answers = false
+	}
+
+	data = normalizeDataset(data, *normalize)
+	data = stripDataset(data, stripped)
+
+	data.SourceDir = *dir
+	data.DataDir = filepath.ToSlash(*dir)
+	if *out != "" {
+		if rel, err := relativeTo(filepath.Dir(*out), *dir); err == nil {
+			data.DataDir = filepath.ToSlash(rel)
+		}
+	}
+
+	if *truncate != 0 {
+		overflow := overflowDir(*out)
+		var files []overflowFile
+		data, files = truncateDataset(data, *truncate, filepath.ToSlash(filepath.Base(overflow)))
+		if err := writeOverflowFiles(overflow, files); err != nil {
+			return err
+		}
+	}
+
+	// An export to a file replaces it only once it has been written in
+	// full, so a failed export leaves the last one in place.
+	export := func(w io.Writer) error { return write(w, data, opts) }
+	if *out == "" {
+		err = export(os.Stdout)
+	} else {
+		err = replaceFile(*out, export)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %v", *format, err)
+	}
+
+	return nil
+}
+
+// relativeTo returns the path to target from the directory base.
+func relativeTo(base string, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(absBase, absTarget)
+}
+
+func loadExportDataset(dir string) (exportDataset, error) {
+	questions, err := readQuestions(filepath.Join(dir, "questions.json"))
+	if err != nil {
+		return exportDataset{}, err
+	}
+
+	data := exportDataset{
+		Questions:   questions,
+		Images:      make(map[string]string),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	manifestPath := filepath.Join(dir, "images.json")
+	contents, err := os.ReadFile(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	} else if err != nil {
+		return exportDataset{}, fmt.Errorf("failed to read %s: %v", manifestPath, err)
+	}
+
+	if err := json.Unmarshal(contents, &data.Images); err != nil {
+		return exportDataset{}, fmt.Errorf("failed to decode %s: %v", manifestPath, err)
+	}
+
+	return data, nil
+}
+
+func exportJSON(w io.Writer, data exportDataset, opts exportOptions) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(data.Questions)
+}
+
+// exportJSONL writes each question as a JSON object on a line of its own, so
+// that the export can be processed a record at a time.
+func exportJSONL(w io.Writer, data exportDataset, opts exportOptions) error {
+	encoder := json.NewEncoder(w)
+	for _, q := range data.Questions {
+		if err := encoder.Encode(q); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns are the columns of the CSV export, in order. Provenance is
+// flattened into a column per field, and imagePath is the stored image
+// relative to the export.
+var csvColumns = []string{
+	"questionId",
+	"localId",
+	"customSource",
+	"certificate",
+	"type",
+	"createdDate",
+	"question",
+	"answer",
+	"imageFile",
+	"imagePath",
+	"firstSeenAt",
+	"firstSeenSource",
+	"lastFetchedAt",
+	"lastFetchedSource",
+	"warnings",
+	"notes",
+	"extra",
+}
+
+// csvRecord flattens a question into the values of csvColumns. Warnings are
+// joined with "; ", notes are on lines of their own, and fields the scraper doesn't model are kept as a JSON
+// object.
+func csvRecord(q Question, data exportDataset, answers bool) ([]string, error) {
+	var imageFile, imagePath string
+	if q.ImageFile != nil {
+		imageFile = *q.ImageFile
+		imagePath = path.Join(data.DataDir, data.ImagePath(q))
+	}
+
+	var firstSeen, lastFetched RunRef
+	if q.Provenance != nil {
+		firstSeen, lastFetched = q.Provenance.FirstSeen, q.Provenance.LastFetched
+	}
+
+	answer := q.Answer
+	if !answers {
+		answer = ""
+	}
+
+	extra := ""
+	if len(q.Extra) > 0 {
+		contents, err := json.Marshal(q.Extra)
+		if err != nil {
+			return nil, err
+		}
+
+		extra = string(contents)
+	}
+
+	return []string{
+		strconv.Itoa(q.QuestionID),
+		q.LocalID,
+		q.CustomSource,
+		string(q.Certificate),
+		string(q.Type),
+		strconv.Itoa(q.CreatedDate),
+		q.Question,
+		answer,
+		imageFile,
+		imagePath,
+		csvTime(firstSeen.At),
+		firstSeen.Source,
+		csvTime(lastFetched.At),
+		lastFetched.Source,
+		strings.Join(q.Warnings, "; "),
+		strings.Join(q.Notes, "\n"),
+		extra,
+	}, nil
+}
+
+// csvTime formats a time as RFC 3339, or an empty string if it's unset.
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// exportCSV writes one row per question, after a header row naming the
+// columns. Question and answer text are as -normalize left them, plain text
+// by default.
+func exportCSV(w io.Writer, data exportDataset, opts exportOptions) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, q := range data.Questions {
+		record, err := csvRecord(q, data, opts.Answers)
+		if err != nil {
+			return err
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
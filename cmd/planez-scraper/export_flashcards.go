@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`_`, `\_`,
+	"`", "\\`",
+	`[`, `\[`,
+	`]`, `\]`,
+	`|`, `\|`,
+)
+
+// markdownTags maps the HTML tags that appear in answers to the Markdown
+// that both opens and closes them.
+var markdownTags = map[string]string{
+	"b":      "**",
+	"strong": "**",
+	"i":      "*",
+	"em":     "*",
+}
+
+// markdownLines converts question text into Markdown, one line per line of
+// the original. List items become "- " lines, paragraphs are separated by
+// an empty line, and URLs are left unescaped so they still link.
+func markdownLines(s string) []string {
+	return markupLines(s, markdownEscaper.Replace, markdownTags)
+}
+
+// plainTextLines converts question text into plain text, laid out as
+// markdownLines lays it out but with nothing escaped or emphasized.
+func plainTextLines(s string) []string {
+	return markupLines(s, func(s string) string { return s }, nil)
+}
+
+// markupLines converts question text into lines of another markup, escaping
+// text with escape and converting the tags in tags to the markup that opens
+// and closes them.
+func markupLines(s string, escape func(string) string, tags map[string]string) []string {
+	var b strings.Builder
+	var open []string
+
+	last := 0
+	for _, loc := range markupTokenPattern.FindAllStringIndex(s, -1) {
+		b.WriteString(escape(html.UnescapeString(s[last:loc[0]])))
+		last = loc[1]
+
+		tag := s[loc[0]:loc[1]]
+		if !strings.HasPrefix(tag, "<") {
+			b.WriteString(html.UnescapeString(tag))
+			continue
+		}
+
+		name := strings.ToLower(strings.Trim(tag, "</> "))
+		name, _, _ = strings.Cut(name, " ")
+
+		switch {
+		case name == "br" || name == "ul" || name == "ol":
+			b.WriteString("\n")
+		case name == "p":
+			b.WriteString("\n\n")
+		case name == "li":
+			b.WriteString("\n- ")
+		case strings.HasPrefix(tag, "</"):
+			if len(open) > 0 && open[len(open)-1] == name {
+				open = open[:len(open)-1]
+				b.WriteString(tags[name])
+			}
+		case tags[name] != "":
+			open = append(open, name)
+			b.WriteString(tags[name])
+		}
+	}
+
+	b.WriteString(escape(html.UnescapeString(s[last:])))
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString(tags[open[i]])
+	}
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(b.String(), "\n") {
+		// A closing </li> opens an empty item, which is dropped without
+		// separating the items around it.
+		line = strings.TrimSpace(line)
+		if line == "-" {
+			continue
+		}
+
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// exportRemNote writes a Markdown outline in RemNote's import syntax, with
+// one parent rem per certificate. Each question is a multi-line card whose
+// children are the lines of the answer, or a plain rem without answers. Cards can't hold images, so figures
+// are left out.
+func exportRemNote(w io.Writer, data exportDataset, opts exportOptions) error {
+	groups, err := groupQuestions("certificate", data.Questions)
+	if err != nil {
+		return err
+	}
+
+	if opts.Attribution != "" {
+		fmt.Fprintf(w, "- %s\n", markdownEscaper.Replace(opts.Attribution))
+	}
+
+	for _, group := range groups {
+		fmt.Fprintf(w, "- %s\n", group.Key)
+
+		for _, q := range group.Questions {
+			question := strings.ReplaceAll(strings.Join(markdownLines(q.Question), " "), ">>", `>\>`)
+			if !opts.Answers {
+				fmt.Fprintf(w, "    - %s\n", question)
+			} else {
+				fmt.Fprintf(w, "    - %s >>>\n", question)
+
+				for _, line := range markdownLines(q.Answer) {
+					if line != "" {
+						fmt.Fprintf(w, "        - %s\n", strings.TrimPrefix(line, "- "))
+					}
+				}
+			}
+
+			for _, note := range q.Notes {
+				fmt.Fprintf(w, "        - Note: %s\n", strings.Join(markdownLines(note), " "))
+			}
+		}
+	}
+
+	return nil
+}
+
+type mochiData struct {
+	Version int         `json:"version"`
+	Decks   []mochiDeck `json:"decks"`
+}
+
+type mochiDeck struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Cards []mochiCard `json:"cards"`
+}
+
+type mochiCard struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	DeckID  string `json:"deck-id"`
+}
+
+// exportMochi writes a .mochi archive: a zip holding data.json, with one
+// deck per certificate, and the images the cards refer to. An attribution is
+// written to ATTRIBUTION.txt, since decks have nowhere to show one.
+func exportMochi(w io.Writer, data exportDataset, opts exportOptions) error {
+	groups, err := groupQuestions("certificate", data.Questions)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	contents := mochiData{Version: 2}
+	attachments := NewSet[string]()
+
+	for _, group := range groups {
+		deck := mochiDeck{
+			ID:   "planez-" + strings.ToLower(group.Key),
+			Name: "Planez " + group.Key,
+		}
+
+		for _, q := range group.Questions {
+			id := fmt.Sprintf("planez-%d", q.QuestionID)
+			if q.LocalID != "" {
+				id = q.LocalID
+			}
+
+			content := strings.Join(markdownLines(q.Question), "\n")
+			if image := data.ImagePath(q); image != "" {
+				content += "\n\n![](@media/" + path.Base(image) + ")"
+				attachments.Add(image)
+			}
+
+			if opts.Answers {
+				content += "\n\n---\n\n" + strings.Join(markdownLines(q.Answer), "\n")
+			}
+
+			for _, note := range q.Notes {
+				content += "\n\n> Note: " + strings.Join(markdownLines(note), " ")
+			}
+
+			deck.Cards = append(deck.Cards, mochiCard{
+				ID:      id,
+				Name:    fmt.Sprintf("Question %d", q.QuestionID),
+				Content: content,
+				DeckID:  deck.ID,
+			})
+		}
+
+		contents.Decks = append(contents.Decks, deck)
+	}
+
+	entry, err := archive.Create("data.json")
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(entry).Encode(contents); err != nil {
+		return err
+	}
+
+	if opts.Attribution != "" {
+		entry, err := archive.Create("ATTRIBUTION.txt")
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(entry, opts.Attribution); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range attachments.Values() {
+		if err := addMochiAttachment(archive, filepath.Join(data.SourceDir, filepath.FromSlash(image))); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func addMochiAttachment(archive *zip.Writer, p string) error {
+	src, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", p, err)
+	}
+
+	defer src.Close()
+
+	entry, err := archive.Create(filepath.Base(p))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(entry, src); err != nil {
+		return fmt.Errorf("failed to read %s: %v", p, err)
+	}
+
+	return nil
+}
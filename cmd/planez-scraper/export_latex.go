@@ -0,0 +1,112 @@
+package main
+
+import (
+	"html"
+	"io"
+	"strings"
+)
+
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`^`, `\textasciicircum{}`,
+	`_`, `\_`,
+	`%`, `\%`,
+	`~`, `\textasciitilde{}`,
+	`<`, `\textless{}`,
+	`>`, `\textgreater{}`,
+)
+
+// latexTags maps the HTML tags that appear in answers to the LaTeX that
+// opens them. Every one is closed with a brace. Bold and italics use
+// declarations, because upstream tags are sometimes left open across
+// paragraphs.
+var latexTags = map[string]string{
+	"sub":    `\textsubscript{`,
+	"sup":    `\textsuperscript{`,
+	"b":      `{\bfseries `,
+	"strong": `{\bfseries `,
+	"i":      `{\itshape `,
+	"em":     `{\itshape `,
+}
+
+// toLaTeX converts question text, which may contain a little HTML, into
+// LaTeX. Supported tags are translated, other tags are dropped, URLs are
+// typeset with \url, and blank lines separate paragraphs.
+func toLaTeX(s string) string {
+	var b strings.Builder
+	var open []string
+
+	last := 0
+	for _, loc := range markupTokenPattern.FindAllStringIndex(s, -1) {
+		b.WriteString(latexEscaper.Replace(html.UnescapeString(s[last:loc[0]])))
+		last = loc[1]
+
+		token := s[loc[0]:loc[1]]
+		if !strings.HasPrefix(token, "<") {
+			b.WriteString(`\url{` + html.UnescapeString(token) + `}`)
+			continue
+		}
+
+		name := strings.ToLower(strings.Trim(token, "</> "))
+		name, _, _ = strings.Cut(name, " ")
+
+		switch {
+		case name == "br" || name == "ul" || name == "ol":
+			b.WriteString("\n")
+		case name == "p":
+			b.WriteString("\n\n")
+		case name == "li":
+			b.WriteString("\n\\textbullet~")
+		case strings.HasPrefix(token, "</"):
+			if len(open) > 0 && open[len(open)-1] == name {
+				open = open[:len(open)-1]
+				b.WriteString("}")
+			}
+		case latexTags[name] != "":
+			open = append(open, name)
+			b.WriteString(latexTags[name])
+		}
+	}
+
+	b.WriteString(latexEscaper.Replace(html.UnescapeString(s[last:])))
+	b.WriteString(strings.Repeat("}", len(open)))
+
+	return latexParagraphs(b.String())
+}
+
+// latexParagraphs keeps single line breaks as forced breaks and collapses
+// runs of blank lines into paragraph breaks.
+func latexParagraphs(s string) string {
+	var paragraphs []string
+	var lines []string
+
+	flush := func() {
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, " \\newline\n"))
+			lines = nil
+		}
+	}
+
+	for _, line := range strings.Split(s, "\n") {
+		// Some answers use <li> to close list items as well as open them,
+		// which leaves bullets with nothing after them.
+		if line = strings.TrimSpace(line); line == "" || line == `\textbullet~` {
+			flush()
+		} else {
+			lines = append(lines, line)
+		}
+	}
+
+	flush()
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func exportLaTeX(w io.Writer, data exportDataset, opts exportOptions) error {
+	return renderBuiltinTemplate(w, "latex.tmpl", data, opts)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+)
+
+// normalizeProfiles are the ways -normalize can clean question and answer
+// text for the program an export is read by. html leaves the site's HTML as
+// it is, text converts it to plain text, and markdown converts it to
+// Markdown with the characters Markdown treats specially, pipes included,
+// escaped.
+var normalizeProfiles = []string{"html", "markdown", "text"}
+
+// defaultProfiles are the profiles applied to each format that takes one
+// when -normalize isn't given. Formats missing from it convert the site's
+// HTML into their own markup as they write it, so they take no profile.
+var defaultProfiles = map[string]string{
+	"csv":      "text",
+	"json":     "html",
+	"jsonl":    "html",
+	"template": "html",
+}
+
+// normalizeDataset returns a copy of the dataset with the text of every
+// question and answer converted for the given profile.
+func normalizeDataset(data exportDataset, profile string) exportDataset {
+	var convert func(string) []string
+	switch profile {
+	case "markdown":
+		convert = markdownLines
+	case "text":
+		convert = plainTextLines
+	default:
+		return data
+	}
+
+	questions := make([]Question, len(data.Questions))
+	for i, q := range data.Questions {
+		q.Question = strings.Join(convert(q.Question), "\n")
+		q.Answer = strings.Join(convert(q.Answer), "\n")
+		questions[i] = q
+	}
+
+	data.Questions = questions
+
+	return data
+}
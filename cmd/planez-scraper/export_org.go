@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// toOrg converts question text into Org body text. Line breaks and list
+// items are kept, other markup is dropped, and lines that Org would read as
+// headings are indented.
+func toOrg(s string) string {
+	s = htmlTagPattern.ReplaceAllStringFunc(s, func(tag string) string {
+		name := strings.ToLower(strings.Trim(tag, "</> "))
+		name, _, _ = strings.Cut(name, " ")
+
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			return ""
+		case name == "br" || name == "ul" || name == "ol":
+			return "\n"
+		case name == "p":
+			return "\n\n"
+		case name == "li":
+			return "\n- "
+		}
+
+		return ""
+	})
+
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(html.UnescapeString(s), "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if strings.TrimSpace(line) == "" || strings.TrimSpace(line) == "-" {
+			blank = len(lines) > 0
+			continue
+		}
+
+		if strings.HasPrefix(line, "*") {
+			line = " " + line
+		}
+
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// orgTags formats values as an Org tag list, such as " :drill:private:".
+func orgTags(values ...any) string {
+	var tags []string
+	for _, value := range values {
+		tag := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '@' {
+				return unicode.ToLower(r)
+			}
+
+			return '_'
+		}, fmt.Sprint(value))
+
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" :%s:", strings.Join(tags, ":"))
+}
+
+func exportOrg(w io.Writer, data exportDataset, opts exportOptions) error {
+	return renderBuiltinTemplate(w, "org.tmpl", data, opts)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// stripFields are the parts of each question that -strip can remove from an
+// export before it is shared. extra is the fields of the upstream data that
+// the scraper doesn't model, and custom-sources the files custom questions
+// were read from.
+var stripFields = []string{"answers", "custom-sources", "extra", "images", "local-ids", "notes", "provenance", "warnings"}
+
+func parseStripFields(spec string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !slices.Contains(stripFields, field) {
+			return nil, fmt.Errorf("unknown field %q%s", field, didYouMean(field, stripFields))
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// stripDataset returns a copy of the dataset with the given fields removed
+// from every question.
+func stripDataset(data exportDataset, fields []string) exportDataset {
+	questions := make([]Question, len(data.Questions))
+	for i, q := range data.Questions {
+		for _, field := range fields {
+			switch field {
+			case "answers":
+				q.Answer = ""
+			case "custom-sources":
+				q.CustomSource = ""
+			case "extra":
+				q.Extra = nil
+			case "images":
+				q.ImageFile = nil
+			case "local-ids":
+				q.LocalID = ""
+			case "notes":
+				q.Notes = nil
+			case "provenance":
+				q.Provenance = nil
+			case "warnings":
+				q.Warnings = nil
+			}
+		}
+
+		questions[i] = q
+	}
+
+	data.Questions = questions
+	if slices.Contains(fields, "images") {
+		data.Images = map[string]string{}
+	}
+
+	return data
+}
+
+// attribution describes where the questions in an export came from, based on
+// their provenance when it is available.
+func attribution(data exportDataset) string {
+	source := defaultBaseURL
+	var retrieved time.Time
+	for _, q := range data.Questions {
+		if q.Provenance == nil {
+			continue
+		}
+
+		if u, err := url.Parse(q.Provenance.LastFetched.Source); err == nil && u.Host != "" {
+			source = u.Scheme + "://" + u.Host
+		}
+
+		if q.Provenance.LastFetched.At.After(retrieved) {
+			retrieved = q.Provenance.LastFetched.At
+		}
+	}
+
+	text := "Questions and answers from " + source
+	if !retrieved.IsZero() {
+		text += ", retrieved " + retrieved.Format("January 2, 2006")
+	}
+
+	return text + ". Shared for personal study only. All content belongs to its original authors."
+}
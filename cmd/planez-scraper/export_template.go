@@ -0,0 +1,147 @@
+package main
+
+import (
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// htmlTagPattern matches opening and closing tags, but not a bare "<" used as
+// a less-than sign in the text.
+var htmlTagPattern = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9]*(\s[^<>]*)?/?>`)
+
+// markupTokenPattern matches tags and bare URLs, which converters to other
+// markup both need to treat specially. Punctuation ending a sentence is not
+// part of a URL.
+var markupTokenPattern = regexp.MustCompile(htmlTagPattern.String() + `|https?://[^\s<]*[^\s<.,;:!?)]`)
+
+// stripHTML removes tags and decodes entities, leaving plain text.
+func stripHTML(s string) string {
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+}
+
+// questionGroup is a set of questions sharing a certificate or type.
+type questionGroup struct {
+	Key       string
+	Questions []Question
+}
+
+func groupQuestions(field string, questions []Question) ([]questionGroup, error) {
+	var key func(Question) string
+	switch field {
+	case "certificate":
+		key = func(q Question) string { return string(q.Certificate) }
+	case "type":
+		key = func(q Question) string { return string(q.Type) }
+	default:
+		return nil, fmt.Errorf("cannot group by %q, expected certificate or type", field)
+	}
+
+	var groups []questionGroup
+	index := make(map[string]int)
+	for _, q := range questions {
+		k := key(q)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, questionGroup{Key: k})
+		}
+
+		groups[i].Questions = append(groups[i].Questions, q)
+	}
+
+	slices.SortFunc(groups, func(a, b questionGroup) int { return strings.Compare(a.Key, b.Key) })
+
+	return groups, nil
+}
+
+func csvField(s string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{s})
+	w.Flush()
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// textFunc lets a template pass any value to a function of a string, so that
+// fields such as .Certificate can be passed without converting them first.
+func textFunc(f func(string) string) func(any) string {
+	return func(v any) string {
+		return f(fmt.Sprint(v))
+	}
+}
+
+func templateFuncs(data exportDataset) template.FuncMap {
+	return template.FuncMap{
+		"lower":     textFunc(strings.ToLower),
+		"upper":     textFunc(strings.ToUpper),
+		"trim":      textFunc(strings.TrimSpace),
+		"replace":   strings.ReplaceAll,
+		"join":      strings.Join,
+		"split":     strings.Split,
+		"contains":  strings.Contains,
+		"stripHTML": textFunc(stripHTML),
+		"latex":     textFunc(toLaTeX),
+		"org":       textFunc(toOrg),
+		"orgTags":   orgTags,
+		"csv":       textFunc(csvField),
+		"groupBy":   groupQuestions,
+		"image":     data.ImagePath,
+		"base":      filepath.Base,
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"date": func(layout string, millis int) string {
+			return time.UnixMilli(int64(millis)).UTC().Format(layout)
+		},
+	}
+}
+
+// templateData is what templates are executed with: the dataset plus the
+// options the export was run with.
+type templateData struct {
+	exportDataset
+	Options exportOptions
+}
+
+func renderTemplate(w io.Writer, name string, text string, data exportDataset, opts exportOptions) error {
+	tmpl, err := template.New(name).Funcs(templateFuncs(data)).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, templateData{data, opts})
+}
+
+func renderBuiltinTemplate(w io.Writer, name string, data exportDataset, opts exportOptions) error {
+	text, err := builtinTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return err
+	}
+
+	return renderTemplate(w, name, string(text), data, opts)
+}
+
+func exportTemplate(w io.Writer, data exportDataset, opts exportOptions) error {
+	text, err := readFileString(opts.Template)
+	if err != nil {
+		return err
+	}
+
+	return renderTemplate(w, filepath.Base(opts.Template), text, data, opts)
+}
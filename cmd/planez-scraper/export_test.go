@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// exportTestDataset loads the questions in testdata/export/data the way the
+// export command does, with a fixed time so that the output is the same on
+// every run.
+func exportTestDataset(t *testing.T) exportDataset {
+	t.Helper()
+
+	dir := filepath.Join("testdata", "export", "data")
+	data, err := loadExportDataset(dir)
+	if err != nil {
+		t.Fatalf("loadExportDataset() error = %v", err)
+	}
+
+	data.GeneratedAt = time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+	data.SourceDir = dir
+	data.DataDir = "data"
+	data.Questions[2].Notes = []string{"Ask about <b>minimums</b>."}
+
+	return data
+}
+
+// zipListing lists the entries of a zip archive with their contents, so
+// that archives can be compared without depending on how they compress.
+func zipListing(t *testing.T, archive []byte) []byte {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	var listing bytes.Buffer
+	for _, f := range r.File {
+		contents, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+
+		fmt.Fprintf(&listing, "== %s ==\n", f.Name)
+		if _, err := io.Copy(&listing, contents); err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+
+		contents.Close()
+	}
+
+	return listing.Bytes()
+}
+
+func TestExporters(t *testing.T) {
+	attributed := exportOptions{Answers: true, Attribution: attribution(exportTestDataset(t))}
+
+	tests := []struct {
+		name   string
+		format string
+		opts   exportOptions
+
+		// profile is the -normalize profile, or empty for the format's
+		// default.
+		profile string
+	}{
+		{name: "csv", format: "csv", opts: exportOptions{Answers: true}},
+		{name: "csv-no-answers", format: "csv"},
+		{name: "csv-markdown", format: "csv", opts: exportOptions{Answers: true}, profile: "markdown"},
+		{name: "json", format: "json", opts: exportOptions{Answers: true}},
+		{name: "json-text", format: "json", opts: exportOptions{Answers: true}, profile: "text"},
+		{name: "jsonl", format: "jsonl", opts: exportOptions{Answers: true}},
+		{name: "latex", format: "latex", opts: exportOptions{LaTeXClass: "article", Answers: true}},
+		{name: "latex-exam", format: "latex", opts: exportOptions{LaTeXClass: "exam", Answers: true, Attribution: attributed.Attribution}},
+		{name: "org", format: "org", opts: attributed},
+		{name: "org-no-answers", format: "org"},
+		{name: "remnote", format: "remnote", opts: attributed},
+		{name: "mochi", format: "mochi", opts: attributed},
+		{name: "template", format: "template", opts: exportOptions{Template: filepath.Join("testdata", "export", "custom.tmpl"), Answers: true}},
+	}
+
+	tested := NewSet[string]()
+	for _, tt := range tests {
+		tested.Add(tt.format)
+
+		t.Run(tt.name, func(t *testing.T) {
+			profile := tt.profile
+			if profile == "" {
+				profile = defaultProfiles[tt.format]
+			}
+
+			var out bytes.Buffer
+			data := normalizeDataset(exportTestDataset(t), profile)
+			if err := exporters[tt.format](&out, data, tt.opts); err != nil {
+				t.Fatalf("export %s error = %v", tt.format, err)
+			}
+
+			got := out.Bytes()
+			if tt.format == "mochi" {
+				got = zipListing(t, got)
+			}
+
+			golden := filepath.Join("testdata", "export", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run the tests with -update to create it)", err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("export %s differs from %s (run the tests with -update to accept it)\ngot:\n%s\nwant:\n%s", tt.format, golden, got, want)
+			}
+		})
+	}
+
+	for _, format := range exportFormats() {
+		if !tested.Contains(format) {
+			t.Errorf("no golden file for -format %s", format)
+		}
+	}
+}
+
+func TestTruncateDataset(t *testing.T) {
+	long := strings.Repeat("Check the oil, ", 20)
+	data := exportDataset{Questions: []Question{
+		{planezQuestion: planezQuestion{QuestionID: 1000, Question: "Short?", Answer: long}},
+		{planezQuestion: planezQuestion{QuestionID: -1, Question: long + "é", Answer: "Short."}},
+	}}
+
+	got, files := truncateDataset(data, 120, "questions-overflow")
+
+	if got.Questions[0].Question != "Short?" || got.Questions[1].Answer != "Short." {
+		t.Errorf("truncateDataset() changed text within the limit: %q, %q", got.Questions[0].Question, got.Questions[1].Answer)
+	}
+
+	wantNotes := []string{
+		"… [continued in questions-overflow/1000-answer.txt]",
+		"… [continued in questions-overflow/custom-1-question.txt]",
+	}
+	for i, text := range []string{got.Questions[0].Answer, got.Questions[1].Question} {
+		if n := utf8.RuneCountInString(text); n > 120 {
+			t.Errorf("truncated text is %d characters, want at most 120: %q", n, text)
+		}
+
+		if !strings.HasPrefix(text, "Check the oil,") || !strings.HasSuffix(text, wantNotes[i]) {
+			t.Errorf("truncated text = %q, want the start of the text and %q", text, wantNotes[i])
+		}
+	}
+
+	want := []overflowFile{
+		{Name: "1000-answer.txt", Text: long},
+		{Name: "custom-1-question.txt", Text: long + "é"},
+	}
+	if !slices.Equal(files, want) {
+		t.Errorf("truncateDataset() files = %q, want %q", files, want)
+	}
+
+	// The dataset it was given is left as it was.
+	if data.Questions[0].Answer != long {
+		t.Errorf("truncateDataset() changed the original answer to %q", data.Questions[0].Answer)
+	}
+}
+
+func TestTruncateDatasetBoundary(t *testing.T) {
+	longDir := strings.Repeat("overflow", 20)
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		dir   string
+		want  string
+	}{
+		{name: "at the limit", text: strings.Repeat("a", 100), limit: 100, dir: "out", want: strings.Repeat("a", 100)},
+		{name: "over the limit", text: strings.Repeat("a", 101), limit: 100, dir: "out", want: strings.Repeat("a", 64) + "… [continued in out/1000-answer.txt]"},
+		{name: "long directory", text: strings.Repeat("a", 200), limit: 100, dir: longDir, want: strings.Repeat("a", 68) + "… [continued in 1000-answer.txt]"},
+		{name: "note alone over the limit", text: strings.Repeat("a", 40), limit: 20, dir: longDir, want: strings.Repeat("a", 19) + "…"},
+		{name: "multibyte", text: strings.Repeat("é", 101), limit: 100, dir: "out", want: strings.Repeat("é", 64) + "… [continued in out/1000-answer.txt]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := exportDataset{Questions: []Question{{planezQuestion: planezQuestion{QuestionID: 1000, Answer: tt.text}}}}
+			got, _ := truncateDataset(data, tt.limit, tt.dir)
+
+			answer := got.Questions[0].Answer
+			if answer != tt.want {
+				t.Errorf("truncateDataset() = %q, want %q", answer, tt.want)
+			}
+
+			if n := utf8.RuneCountInString(answer); n > tt.limit {
+				t.Errorf("truncated text is %d characters, want at most %d", n, tt.limit)
+			}
+		})
+	}
+}
+
+func TestStripDataset(t *testing.T) {
+	image := "chart.png"
+	q := Question{
+		planezQuestion: planezQuestion{
+			QuestionID:  1000,
+			Certificate: "private",
+			Question:    "What does the chart show?",
+			Answer:      "Class B airspace.",
+			ImageFile:   &image,
+			Provenance:  &Provenance{FirstSeen: RunRef{Source: defaultBaseURL}},
+			Extra:       map[string]json.RawMessage{"difficulty": json.RawMessage(`"hard"`)},
+		},
+		Warnings:     []string{"short answer"},
+		LocalID:      "1a2b",
+		CustomSource: "charts.md",
+		Notes:        []string{"Ask about the floor."},
+	}
+	data := exportDataset{Questions: []Question{q}, Images: map[string]string{image: "images/" + image}}
+
+	if got := stripDataset(data, nil); !reflect.DeepEqual(got.Questions[0], q) {
+		t.Errorf("stripDataset() with no fields = %+v, want the question unchanged", got.Questions[0])
+	}
+
+	got := stripDataset(data, stripFields)
+	encoded, err := json.Marshal(got.Questions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only what the question can't be exported without is left.
+	want := []string{"answer", "certificate", "createdDate", "imageFile", "question", "questionId", "type"}
+	if names := slices.Sorted(maps.Keys(fields)); !slices.Equal(names, want) {
+		t.Errorf("stripDataset() with every field = %s, want only %v", encoded, want)
+	}
+
+	if string(fields["answer"]) != `""` || string(fields["imageFile"]) != "null" || len(got.Images) != 0 {
+		t.Errorf("stripDataset() with every field = %s with images %v, want no answer or image", encoded, got.Images)
+	}
+
+	if q.Notes == nil || q.Extra == nil {
+		t.Errorf("stripDataset() changed the dataset it was given")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateFormats are the formats -truncate can shorten text in, for the
+// programs they are imported into that limit the length of a field, such as
+// spreadsheets limiting the length of a cell.
+var truncateFormats = []string{"csv"}
+
+// minTruncateLimit is the shortest -truncate limit, leaving room for some
+// text before the note of where the rest of it is.
+const minTruncateLimit = 100
+
+// overflowFile is the full text of a field that was truncated, to be written
+// beside the export.
+type overflowFile struct {
+	// Name is the file's name within the overflow directory.
+	Name string
+	Text string
+}
+
+// overflowDir returns the directory that the overflow files of an export to
+// out are written to, beside it.
+func overflowDir(out string) string {
+	return strings.TrimSuffix(out, filepath.Ext(out)) + "-overflow"
+}
+
+// truncateDataset returns a copy of the dataset with question and answer
+// text longer than limit characters cut short, and the files holding their
+// full text. Each truncated field ends with a note of the file its text
+// continues in, given by its path from the export under dir, and is cut
+// short enough to fit the note within limit.
+func truncateDataset(data exportDataset, limit int, dir string) (exportDataset, []overflowFile) {
+	var files []overflowFile
+	truncate := func(q Question, field string, text string) string {
+		if utf8.RuneCountInString(text) <= limit {
+			return text
+		}
+
+		// Custom questions are numbered from -1 down, to stay clear of the
+		// site's IDs.
+		id := strconv.Itoa(q.QuestionID)
+		if q.QuestionID < 0 {
+			id = "custom" + id
+		}
+
+		file := overflowFile{Name: id + "-" + field + ".txt", Text: text}
+		files = append(files, file)
+
+		// The note counts towards the limit, so it is shortened to the file's
+		// name, or to an ellipsis alone, when the full path leaves no room
+		// for any of the text.
+		note := "…"
+		for _, n := range []string{fmt.Sprintf("… [continued in %s]", path.Join(dir, file.Name)), fmt.Sprintf("… [continued in %s]", file.Name)} {
+			if utf8.RuneCountInString(n) < limit {
+				note = n
+				break
+			}
+		}
+
+		kept := []rune(text)[:max(0, limit-utf8.RuneCountInString(note))]
+
+		return strings.TrimRight(string(kept), " \n") + note
+	}
+
+	questions := make([]Question, len(data.Questions))
+	for i, q := range data.Questions {
+		q.Question = truncate(q, "question", q.Question)
+		q.Answer = truncate(q, "answer", q.Answer)
+		questions[i] = q
+	}
+
+	data.Questions = questions
+
+	return data, files
+}
+
+// writeOverflowFiles writes the full text of truncated fields to dir,
+// replacing the files of an earlier export.
+func writeOverflowFiles(dir string, files []overflowFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	for _, file := range files {
+		err := replaceFile(filepath.Join(dir, file.Name), func(w io.Writer) error {
+			_, err := io.WriteString(w, file.Text+"\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
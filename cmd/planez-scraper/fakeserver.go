@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cdriehuys/planez-scraper/v2/internal/fakeplanez"
+)
+
+func runFakeServer(args []string) error {
+	flags := flag.NewFlagSet("fake-server", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8089", "Address to listen on")
+	dir := flags.String("data", "", "Serve the questions and images from a previous scrape in this directory")
+	synthetic := flags.Int("synthetic", 300, "Number of synthetic questions to generate when -data is not given")
+	start := flags.Int("start", 1000, "First synthetic question ID")
+	var opts fakeplanez.Options
+	flags.DurationVar(&opts.Latency, "latency", 0, "Latency added to every response")
+	flags.DurationVar(&opts.Jitter, "jitter", 0, "Maximum random latency added on top of -latency")
+	flags.Float64Var(&opts.ErrorRate, "error-rate", 0, "Fraction of requests (0 to 1) that fail")
+	flags.IntVar(&opts.ErrorStatus, "error-status", http.StatusInternalServerError, "Status code for failed requests")
+	flags.Int64Var(&opts.Seed, "seed", 1, "Seed for latency jitter and failures")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.Check(opts.ErrorRate >= 0 && opts.ErrorRate <= 1, "-error-rate: must be between 0 and 1, got %g", opts.ErrorRate)
+	v.Check(opts.ErrorStatus >= 100 && opts.ErrorStatus <= 599, "-error-status: invalid status %d", opts.ErrorStatus)
+	v.Check(opts.Latency >= 0 && opts.Jitter >= 0, "-latency and -jitter must not be negative")
+	if *dir != "" {
+		v.CheckDir("-data", *dir)
+	} else {
+		v.Check(*synthetic > 0, "-synthetic: must be at least 1 when -data is not given, got %d", *synthetic)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	server := fakeplanez.New(opts)
+	if *dir != "" {
+		if err := server.LoadDir(*dir); err != nil {
+			return err
+		}
+	} else {
+		server.AddSynthetic(*start, *synthetic)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Serving fake questions", "questions", server.Len(), "url", "http://"+listener.Addr().String())
+
+	return http.Serve(listener, server)
+}
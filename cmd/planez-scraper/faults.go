@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// faultRule injects a failure into the given fraction of requests. Kind is
+// "timeout", "reset", or a status code to respond with.
+type faultRule struct {
+	kind string
+	rate float64
+}
+
+// parseFaults parses a comma separated list of KIND=PERCENT rules, such as
+// "timeout=5%,500=2%".
+func parseFaults(spec string) ([]faultRule, error) {
+	var rules []faultRule
+	total := 0.0
+
+	for _, rule := range strings.Split(spec, ",") {
+		kind, rawRate, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q, expected KIND=PERCENT", rule)
+		}
+
+		if kind != "timeout" && kind != "reset" {
+			if status, err := strconv.Atoi(kind); err != nil || status < 100 || status > 599 {
+				return nil, fmt.Errorf("unknown fault %q, expected timeout, reset, or a status code", kind)
+			}
+		}
+
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(rawRate, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid rate %q in fault %q", rawRate, rule)
+		}
+
+		total += percent
+		rules = append(rules, faultRule{kind: kind, rate: percent / 100})
+	}
+
+	if total > 100 {
+		return nil, fmt.Errorf("fault rates add up to %g%%, more than 100%%", total)
+	}
+
+	return rules, nil
+}
+
+type faultError struct {
+	timeout bool
+}
+
+func (e *faultError) Error() string {
+	if e.timeout {
+		return "injected fault: timeout"
+	}
+
+	return "injected fault: connection reset"
+}
+
+func (e *faultError) Timeout() bool   { return e.timeout }
+func (e *faultError) Temporary() bool { return true }
+
+// faultTransport fails a seeded, random selection of requests according to
+// its rules before they reach the next transport.
+//
+// Whether a request fails is decided from the seed, the request's method and
+// URL, and how many times that URL has been requested before, rather than
+// from a random source shared by the workers. The same requests fail
+// whatever order the workers make them in, so a seed reproduces a run at any
+// -concurrency, and the retry of a failed request is decided afresh.
+type faultTransport struct {
+	next  http.RoundTripper
+	rules []faultRule
+	seed  int64
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func newFaultTransport(next http.RoundTripper, rules []faultRule, seed int64) *faultTransport {
+	return &faultTransport{next: next, rules: rules, seed: seed, attempts: make(map[string]int)}
+}
+
+// roll returns a number in [0, 1) for the attempt'th request to key.
+func (t *faultTransport) roll(key string, attempt int) float64 {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, t.seed)
+	fmt.Fprintf(h, "%s\x00%d", key, attempt)
+	sum := h.Sum(nil)
+
+	return float64(binary.BigEndian.Uint64(sum)>>11) / (1 << 53)
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+
+	t.mu.Lock()
+	attempt := t.attempts[key]
+	t.attempts[key]++
+	t.mu.Unlock()
+
+	roll := t.roll(key, attempt)
+
+	for _, rule := range t.rules {
+		if roll >= rule.rate {
+			roll -= rule.rate
+			continue
+		}
+
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		switch rule.kind {
+		case "timeout":
+			return nil, &faultError{timeout: true}
+		case "reset":
+			return nil, &faultError{}
+		}
+
+		status, _ := strconv.Atoi(rule.kind)
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+
+	return t.next.RoundTrip(req)
+}
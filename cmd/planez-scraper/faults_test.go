@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// okTransport answers every request with an empty 200 response.
+var okTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+})
+
+// injectFaults makes attempts requests to each of urls through a fault
+// transport, with the given number of workers, and returns the outcome of
+// each attempt by URL.
+func injectFaults(t *testing.T, urls []string, attempts int, workers int, seed int64) map[string][]string {
+	t.Helper()
+
+	rules, err := parseFaults("timeout=10%,reset=10%,503=20%")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := newFaultTransport(okTransport, rules, seed)
+	outcomes := make(map[string][]string)
+	var mu sync.Mutex
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range work {
+				req, _ := http.NewRequest(http.MethodGet, url, nil)
+				outcome := "ok"
+				if res, err := transport.RoundTrip(req); err != nil {
+					outcome = err.Error()
+				} else if res.StatusCode != http.StatusOK {
+					outcome = res.Status
+				}
+
+				mu.Lock()
+				outcomes[url] = append(outcomes[url], outcome)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// With more than one worker, the attempts at a URL can be made out of
+	// order, so they are only compared with one.
+	for range attempts {
+		for _, url := range urls {
+			work <- url
+		}
+	}
+
+	close(work)
+	wg.Wait()
+
+	return outcomes
+}
+
+func TestFaultTransportIsReproducible(t *testing.T) {
+	var urls []string
+	for id := 1000; id < 1200; id++ {
+		urls = append(urls, fmt.Sprintf("https://planez.example/api/question/%d", id))
+	}
+
+	want := injectFaults(t, urls, 1, 1, 42)
+
+	reversed := slices.Clone(urls)
+	slices.Reverse(reversed)
+	if got := injectFaults(t, reversed, 1, 8, 42); !maps.EqualFunc(got, want, slices.Equal) {
+		t.Error("the same seed failed other requests with 8 workers in another order")
+	}
+
+	if got := injectFaults(t, urls, 1, 1, 43); maps.EqualFunc(got, want, slices.Equal) {
+		t.Error("another seed failed the same requests")
+	}
+
+	failed := 0
+	for _, outcome := range want {
+		if outcome[0] != "ok" {
+			failed++
+		}
+	}
+
+	// 40% of 200 requests are failed on average.
+	if failed < 50 || failed > 110 {
+		t.Errorf("%d of %d requests failed, want about %d", failed, len(urls), len(urls)*4/10)
+	}
+}
+
+func TestFaultTransportRetries(t *testing.T) {
+	url := "https://planez.example/api/question/1000"
+	outcomes := injectFaults(t, []string{url}, 50, 1, 42)[url]
+
+	// A failed request is decided afresh when it's retried, rather than
+	// failing every time.
+	failed := slices.DeleteFunc(slices.Clone(outcomes), func(outcome string) bool { return outcome == "ok" })
+	if len(failed) == 0 || len(failed) == len(outcomes) {
+		t.Errorf("50 attempts at one URL had outcomes %q, want a mix", outcomes)
+	}
+
+	if again := injectFaults(t, []string{url}, 50, 1, 42)[url]; !slices.Equal(again, outcomes) {
+		t.Errorf("attempts had outcomes %q, then %q with the same seed", outcomes, again)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// questionFields are the fields of a question that -fields can select, by
+// their JSON names.
+var questionFields = []string{"answer", "certificate", "createdDate", "imageFile", "question", "type"}
+
+// alwaysKeptFields are kept whatever -fields selects.
+var alwaysKeptFields = []string{"questionId", "provenance", "warnings"}
+
+func parseFields(spec string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !slices.Contains(questionFields, field) {
+			return nil, fmt.Errorf("unknown field %q%s", field, didYouMean(field, questionFields))
+		}
+
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+
+	return fields, nil
+}
+
+// selectFields clears every field of q that isn't in fields.
+func selectFields(q Question, fields []string) Question {
+	keep := func(field string) bool { return slices.Contains(fields, field) }
+
+	if !keep("answer") {
+		q.Answer = ""
+	}
+
+	if !keep("certificate") {
+		q.Certificate = ""
+	}
+
+	if !keep("createdDate") {
+		q.CreatedDate = 0
+	}
+
+	if !keep("imageFile") {
+		q.ImageFile = nil
+	}
+
+	if !keep("question") {
+		q.Question = ""
+	}
+
+	if !keep("type") {
+		q.Type = ""
+	}
+
+	// Fields the model doesn't know about can't be selected.
+	q.Extra = nil
+
+	return q
+}
+
+// projectQuestions encodes each question as an object holding only the given
+// fields, along with those that are always kept.
+func projectQuestions(data []Question, fields []string) ([]map[string]json.RawMessage, error) {
+	projected := make([]map[string]json.RawMessage, 0, len(data))
+	for _, q := range data {
+		encoded, err := json.Marshal(q)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &all); err != nil {
+			return nil, err
+		}
+
+		for name := range all {
+			if !slices.Contains(alwaysKeptFields, name) && !slices.Contains(fields, name) {
+				delete(all, name)
+			}
+		}
+
+		projected = append(projected, all)
+	}
+
+	return projected, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var galleryTemplate = template.Must(template.ParseFS(builtinTemplates, "templates/gallery.html"))
+
+// galleryName is the name of the gallery in the images directory.
+const galleryName = "index.html"
+
+type galleryImage struct {
+	Original  string
+	Stored    string
+	Questions []galleryQuestion
+}
+
+type galleryQuestion struct {
+	ID   int
+	Text string
+	URL  string
+}
+
+// writeGallery writes images/index.html, a grid of every image referenced
+// by a question along with the questions that reference it. Images that
+// weren't downloaded are shown as missing.
+func writeGallery(dir string, questions []Question, stored map[string]string) error {
+	byID := make(map[int]Question, len(questions))
+	for _, q := range questions {
+		byID[q.QuestionID] = q
+	}
+
+	var images []galleryImage
+	referencing, missing := 0, 0
+	for original, ids := range imageReferences(questions) {
+		image := galleryImage{Original: original, Stored: stored[original]}
+		for _, id := range ids {
+			q := byID[id]
+
+			source := questionURL(id)
+			if q.Provenance != nil {
+				source = q.Provenance.LastFetched.Source
+			}
+
+			image.Questions = append(image.Questions, galleryQuestion{
+				ID:   id,
+				Text: strings.Join(strings.Fields(stripHTML(q.Question)), " "),
+				URL:  source,
+			})
+		}
+
+		images = append(images, image)
+		referencing += len(ids)
+		if image.Stored == "" {
+			missing++
+		}
+	}
+
+	// Missing images sort first so they stand out, then by the first
+	// question that references each one.
+	slices.SortFunc(images, func(a, b galleryImage) int {
+		if (a.Stored == "") != (b.Stored == "") {
+			if a.Stored == "" {
+				return -1
+			}
+
+			return 1
+		}
+
+		return a.Questions[0].ID - b.Questions[0].ID
+	})
+
+	return replaceFile(filepath.Join(dir, "images", galleryName), func(w io.Writer) error {
+		return galleryTemplate.Execute(w, map[string]any{
+			"Images":      images,
+			"Questions":   referencing,
+			"Missing":     missing,
+			"GeneratedAt": time.Now().UTC(),
+		})
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	siteIndexTemplate    = template.Must(template.ParseFS(builtinTemplates, "templates/site_index.html"))
+	siteQuestionTemplate = template.Must(template.ParseFS(builtinTemplates, "templates/site_question.html"))
+)
+
+// siteCertificate is the questions for one certificate on the index of a
+// generated site, by type.
+type siteCertificate struct {
+	Certificate string
+	Count       int
+	Types       []siteType
+}
+
+type siteType struct {
+	Type      string
+	Questions []siteQuestion
+}
+
+// siteQuestion is a question as the pages of a generated site show it. Paths
+// are relative to the root of the site.
+type siteQuestion struct {
+	ID          int
+	Certificate string
+	Type        string
+	Text        string
+	Summary     string
+	Answer      string
+	Image       string
+	Source      string
+	Page        string
+	Prev        string
+	Next        string
+}
+
+// SearchText is what the index's search matches a question against.
+func (q siteQuestion) SearchText() string {
+	return strings.ToLower(strconv.Itoa(q.ID) + " " + q.Text + " " + q.Answer)
+}
+
+// buildSite groups the questions of data for the index, by certificate and
+// then type, and links each question to the ones before and after it in its
+// group.
+func buildSite(data exportDataset, answers bool) ([]siteCertificate, error) {
+	byCertificate, err := groupQuestions("certificate", data.Questions)
+	if err != nil {
+		return nil, err
+	}
+
+	var site []siteCertificate
+	for _, certificate := range byCertificate {
+		byType, err := groupQuestions("type", certificate.Questions)
+		if err != nil {
+			return nil, err
+		}
+
+		group := siteCertificate{Certificate: certificate.Key, Count: len(certificate.Questions)}
+		for _, t := range byType {
+			questions := make([]siteQuestion, len(t.Questions))
+			for i, q := range t.Questions {
+				text := strings.TrimSpace(stripHTML(q.Question))
+				questions[i] = siteQuestion{
+					ID:          q.QuestionID,
+					Certificate: string(q.Certificate),
+					Type:        string(q.Type),
+					Text:        text,
+					Summary:     truncate(strings.Join(strings.Fields(text), " "), 120),
+					Page:        path.Join("questions", strconv.Itoa(q.QuestionID)+".html"),
+				}
+
+				if answers {
+					questions[i].Answer = strings.TrimSpace(stripHTML(q.Answer))
+				}
+
+				questions[i].Image = data.ImagePath(q)
+
+				if q.Provenance != nil {
+					questions[i].Source = q.Provenance.LastFetched.Source
+				}
+			}
+
+			for i := range questions {
+				if i > 0 {
+					questions[i].Prev = path.Base(questions[i-1].Page)
+				}
+
+				if i < len(questions)-1 {
+					questions[i].Next = path.Base(questions[i+1].Page)
+				}
+			}
+
+			group.Types = append(group.Types, siteType{Type: t.Key, Questions: questions})
+		}
+
+		site = append(site, group)
+	}
+
+	return site, nil
+}
+
+// writeSite writes the site for data into dir: index.html, a page for each
+// question under questions, and the images they show under images. Images
+// that weren't downloaded are left off their questions' pages.
+func writeSite(dir string, data exportDataset, site []siteCertificate, title string) error {
+	for _, sub := range []string{"questions", "images"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", filepath.Join(dir, sub), err)
+		}
+	}
+
+	total := 0
+	copied := NewSet[string]()
+	for _, certificate := range site {
+		total += certificate.Count
+		for _, t := range certificate.Types {
+			for _, q := range t.Questions {
+				if q.Image != "" {
+					if err := copySiteImage(dir, data.SourceDir, q.Image, copied); err != nil {
+						slog.Warn("Leaving out an image that can't be copied", "question_id", q.ID, "image", q.Image, "error", err)
+						q.Image = ""
+					}
+				}
+
+				err := replaceFile(filepath.Join(dir, filepath.FromSlash(q.Page)), func(w io.Writer) error {
+					return siteQuestionTemplate.Execute(w, map[string]any{
+						"Title":    title,
+						"Question": q,
+					})
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return replaceFile(filepath.Join(dir, "index.html"), func(w io.Writer) error {
+		return siteIndexTemplate.Execute(w, map[string]any{
+			"Title":        title,
+			"Certificates": site,
+			"Count":        total,
+			"GeneratedAt":  data.GeneratedAt,
+		})
+	})
+}
+
+// copySiteImage copies an image from its stored path in the data directory
+// to the same path in the site, once however many questions show it.
+func copySiteImage(dir string, dataDir string, stored string, copied *Set[string]) error {
+	if copied.Contains(stored) {
+		return nil
+	}
+
+	src, err := os.Open(filepath.Join(dataDir, filepath.FromSlash(stored)))
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	err = replaceFile(filepath.Join(dir, filepath.FromSlash(stored)), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	copied.Add(stored)
+	return nil
+}
+
+func runGenerate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	dir := flags.String("data", activeWorkspace.DataDir(), "Directory containing scraped data")
+	out := flags.String("o", "site", "Directory to write the site to, created if needed")
+	title := flags.String("title", "Planez questions", "Title shown at the top of each page")
+	answers := flags.Bool("answers", true, "Include answers, hidden until each is revealed")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.CheckFile("-data", filepath.Join(*dir, "questions.json"))
+	v.CheckParentDir("-o", *out)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	data, err := loadExportDataset(*dir)
+	if err != nil {
+		return err
+	}
+
+	data.SourceDir = *dir
+
+	site, err := buildSite(data, *answers)
+	if err != nil {
+		return err
+	}
+
+	if err := writeSite(*out, data, site, *title); err != nil {
+		return fmt.Errorf("failed to generate the site: %v", err)
+	}
+
+	fmt.Printf("Wrote %d questions to %s\n", len(data.Questions), filepath.Join(*out, "index.html"))
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historyFileName = "planez-history.db"
+
+type runSummary struct {
+	StartedAt     time.Time
+	Duration      time.Duration
+	Scraped       int
+	Failed        int
+	Images        int
+	ImageFailures int
+	Latency       latencyStats
+	Errors        []string
+}
+
+// historyColumns are columns added to the runs table after it was first
+// created, which older databases need to have added.
+var historyColumns = []string{
+	"latency_p50_ms INTEGER NOT NULL DEFAULT 0",
+	"latency_p95_ms INTEGER NOT NULL DEFAULT 0",
+	"latency_max_ms INTEGER NOT NULL DEFAULT 0",
+}
+
+func openHistory(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		scraped INTEGER NOT NULL,
+		failed INTEGER NOT NULL,
+		images INTEGER NOT NULL,
+		image_failures INTEGER NOT NULL,
+		errors TEXT NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %v", path, err)
+	}
+
+	if err := migrateHistory(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s: %v", path, err)
+	}
+
+	return db, nil
+}
+
+func migrateHistory(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('runs')")
+	if err != nil {
+		return err
+	}
+
+	existing := NewSet[string]()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+
+		existing.Add(name)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range historyColumns {
+		name, _, _ := strings.Cut(column, " ")
+		if existing.Contains(name) {
+			continue
+		}
+
+		if _, err := db.Exec("ALTER TABLE runs ADD COLUMN " + column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recordRun(path string, summary runSummary) error {
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	errs := summary.Errors
+	if errs == nil {
+		errs = []string{}
+	}
+
+	encodedErrors, err := json.Marshal(errs)
+	if err != nil {
+		return fmt.Errorf("failed to encode errors: %v", err)
+	}
+
+	_, err = db.Exec(
+		`INSERT INTO runs (started_at, duration_ms, scraped, failed, images, image_failures, errors, latency_p50_ms, latency_p95_ms, latency_max_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		summary.StartedAt.Format(time.RFC3339),
+		summary.Duration.Milliseconds(),
+		summary.Scraped,
+		summary.Failed,
+		summary.Images,
+		summary.ImageFailures,
+		string(encodedErrors),
+		summary.Latency.P50.Milliseconds(),
+		summary.Latency.P95.Milliseconds(),
+		summary.Latency.Max.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record run in %s: %v", path, err)
+	}
+
+	return nil
+}
+
+func runHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	path := flags.String("db", activeWorkspace.HistoryPath(), "Run history database")
+	limit := flags.Int("n", 10, "Number of recent runs to show")
+	showErrors := flags.Bool("errors", false, "List the errors recorded for each run")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.Check(*limit > 0, "-n: must be at least 1, got %d", *limit)
+	v.CheckFile("-db", *path)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	db, err := openHistory(*path)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(
+		`SELECT started_at, duration_ms, scraped, failed, images, image_failures, errors, latency_p95_ms
+		FROM runs ORDER BY id DESC LIMIT ?`,
+		*limit,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to read run history: %v", err)
+	}
+
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tDURATION\tSCRAPED\tFAILED\tSUCCESS\tP95 LATENCY\tIMAGES\tIMAGE FAILURES")
+
+	for rows.Next() {
+		var (
+			startedAt     string
+			durationMS    int64
+			summary       runSummary
+			encodedErrors string
+			p95MS         int64
+		)
+
+		err := rows.Scan(&startedAt, &durationMS, &summary.Scraped, &summary.Failed, &summary.Images, &summary.ImageFailures, &encodedErrors, &p95MS)
+		if err != nil {
+			return fmt.Errorf("failed to read run history: %v", err)
+		}
+
+		success := "-"
+		if total := summary.Scraped + summary.Failed; total > 0 {
+			success = fmt.Sprintf("%.1f%%", 100*float64(summary.Scraped)/float64(total))
+		}
+
+		duration := (time.Duration(durationMS) * time.Millisecond).Round(time.Second)
+		p95 := time.Duration(p95MS) * time.Millisecond
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%d\t%d\n", startedAt, duration, summary.Scraped, summary.Failed, success, p95, summary.Images, summary.ImageFailures)
+
+		if *showErrors {
+			json.Unmarshal([]byte(encodedErrors), &summary.Errors)
+			for _, e := range summary.Errors {
+				fmt.Fprintf(w, "  %s\n", e)
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read run history: %v", err)
+	}
+
+	return w.Flush()
+}
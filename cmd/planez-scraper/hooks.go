@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Results reported to the post-run hook in PLANEZ_RESULT, and in the JSON
+// summary of a run.
+const (
+	resultSuccess     = "success"
+	resultError       = "error"
+	resultInterrupted = "interrupted"
+	resultAnomalies   = "anomalies"
+)
+
+// runResult sums up how a run ended for its hooks and summary.
+func runResult(fatalErr error, strictAnomalies bool) string {
+	switch {
+	case errors.Is(fatalErr, errInterrupted):
+		return resultInterrupted
+	case fatalErr != nil:
+		return resultError
+	case strictAnomalies:
+		return resultAnomalies
+	default:
+		return resultSuccess
+	}
+}
+
+// hookEnv describes a run to its hooks, as PLANEZ_* environment variables.
+// The counts are only set for the post-run hook.
+type hookEnv struct {
+	BaseURL   string
+	DataDir   string
+	Profile   string
+	StartedAt time.Time
+
+	Finished      bool
+	Result        string
+	Duration      time.Duration
+	Scraped       int
+	Failed        int
+	Images        int
+	ImageFailures int
+	Anomalies     int
+}
+
+func (e hookEnv) environ() []string {
+	env := []string{
+		"PLANEZ_BASE_URL=" + e.BaseURL,
+		"PLANEZ_DATA_DIR=" + e.DataDir,
+		"PLANEZ_PROFILE=" + e.Profile,
+		"PLANEZ_STARTED_AT=" + e.StartedAt.Format(time.RFC3339),
+	}
+
+	if !e.Finished {
+		return env
+	}
+
+	return append(env,
+		"PLANEZ_RESULT="+e.Result,
+		"PLANEZ_DURATION="+strconv.Itoa(int(e.Duration.Seconds())),
+		"PLANEZ_SCRAPED="+strconv.Itoa(e.Scraped),
+		"PLANEZ_FAILED="+strconv.Itoa(e.Failed),
+		"PLANEZ_IMAGES="+strconv.Itoa(e.Images),
+		"PLANEZ_IMAGE_FAILURES="+strconv.Itoa(e.ImageFailures),
+		"PLANEZ_ANOMALIES="+strconv.Itoa(e.Anomalies),
+	)
+}
+
+// runHook runs a hook command with the system shell, passing through its
+// output and adding the run's details to its environment.
+func runHook(name string, command string, env hookEnv) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env.environ()...)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry describes a cached response body: the validators the site sent
+// with it, which are sent back to ask whether it has changed.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+	StoredAt     time.Time `json:"storedAt"`
+}
+
+// responseCache is a directory of response bodies, each stored with the
+// entry that describes it.
+type responseCache struct {
+	dir string
+
+	// reused counts the responses answered from the cache.
+	reused atomic.Int64
+}
+
+func openResponseCache(dir string) (*responseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %v", dir, err)
+	}
+
+	return &responseCache{dir: dir}, nil
+}
+
+// cachingTransport keeps the body of every successful GET response that has
+// an ETag or Last-Modified header in its cache. When a URL is requested
+// again, the request is made conditional with If-None-Match and
+// If-Modified-Since, and a 304 Not Modified response is answered from the
+// cache as if the site had sent the body again. Requests still reach the
+// site, so rate limits and failures apply as usual, but unchanged bodies
+// aren't downloaded.
+type cachingTransport struct {
+	next  http.RoundTripper
+	cache *responseCache
+}
+
+// defaultCacheDir returns the directory responses are cached in unless
+// -cache-dir says otherwise: under the XDG cache directory, or in .cache in
+// the current directory with -local.
+func defaultCacheDir() string {
+	return filepath.Join(roots.Cache, "http")
+}
+
+// Reused returns how many responses were answered from the cache. A nil
+// cache, when caching is disabled, has reused none.
+func (c *responseCache) Reused() int {
+	if c == nil {
+		return 0
+	}
+
+	return int(c.reused.Load())
+}
+
+// paths returns the files holding the entry and body cached for url.
+func (c *responseCache) paths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	key := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+
+	return key + ".json", key + ".body"
+}
+
+// load returns the entry cached for url, if there is one with a body.
+func (c *responseCache) load(url string) (cacheEntry, bool) {
+	entryPath, bodyPath := c.paths(url)
+
+	contents, err := os.ReadFile(entryPath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Ignoring cached response", "url", url, "error", err)
+		}
+
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(contents, &entry); err != nil || entry.URL != url {
+		return cacheEntry{}, false
+	}
+
+	if _, err := os.Stat(bodyPath); err != nil {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	entry, cached := t.cache.load(url)
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached:
+		return t.reuse(res, entry)
+	case res.StatusCode == http.StatusOK && (res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""):
+		return t.store(res, url)
+	}
+
+	return res, nil
+}
+
+// reuse turns a 304 Not Modified response into a 200 OK with the cached
+// body.
+func (t *cachingTransport) reuse(res *http.Response, entry cacheEntry) (*http.Response, error) {
+	res.Body.Close()
+
+	_, bodyPath := t.cache.paths(entry.URL)
+	body, err := os.Open(bodyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached response for %s: %v", entry.URL, err)
+	}
+
+	info, err := body.Stat()
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("failed to open cached response for %s: %v", entry.URL, err)
+	}
+
+	t.cache.reused.Add(1)
+
+	header := res.Header.Clone()
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+
+	reused := *res
+	reused.StatusCode = http.StatusOK
+	reused.Status = "200 OK"
+	reused.Header = header
+	reused.Body = body
+	reused.ContentLength = info.Size()
+
+	return &reused, nil
+}
+
+// store arranges for the body of res to be cached as it is read. Only a body
+// read to the end is cached, so one cut off by a failure or an interrupted
+// run leaves the cache as it was.
+func (t *cachingTransport) store(res *http.Response, url string) (*http.Response, error) {
+	temp, err := os.CreateTemp(t.cache.dir, "*.tmp")
+	if err != nil {
+		slog.Warn("Not caching response", "url", url, "error", err)
+		return res, nil
+	}
+
+	res.Body = &cachingBody{
+		body: res.Body,
+		temp: temp,
+		entry: cacheEntry{
+			URL:          url,
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			ContentType:  res.Header.Get("Content-Type"),
+			StoredAt:     time.Now().UTC(),
+		},
+		cache: t.cache,
+	}
+
+	return res, nil
+}
+
+// maxDrain is how much of a body that wasn't read to the end is read on
+// closing it, to see whether only a little was left.
+const maxDrain = 4 << 10
+
+// cachingBody copies a response body to a temporary file as it is read, and
+// moves it into the cache once it has been read to the end.
+type cachingBody struct {
+	body  io.ReadCloser
+	temp  *os.File
+	entry cacheEntry
+	cache *responseCache
+
+	complete bool
+	failed   bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.failed {
+		if _, err := b.temp.Write(p[:n]); err != nil {
+			slog.Warn("Not caching response", "url", b.entry.URL, "error", err)
+			b.failed = true
+		}
+	}
+
+	if err == io.EOF {
+		b.complete = true
+	}
+
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	// Decoders stop at the end of the value, which can leave a trailing
+	// newline unread.
+	if !b.complete && !b.failed {
+		io.Copy(io.Discard, io.LimitReader(b, maxDrain))
+	}
+
+	err := b.body.Close()
+
+	b.temp.Close()
+	if b.complete && !b.failed {
+		if cerr := b.commit(); cerr != nil {
+			slog.Warn("Not caching response", "url", b.entry.URL, "error", cerr)
+		}
+	}
+
+	os.Remove(b.temp.Name())
+
+	return err
+}
+
+// commit moves the body into the cache and writes its entry. The old entry
+// is removed first, so that a run stopped part way through never leaves an
+// entry beside a body it doesn't describe.
+func (b *cachingBody) commit() error {
+	entryPath, bodyPath := b.cache.paths(b.entry.URL)
+
+	if err := os.Remove(entryPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(b.temp.Name(), bodyPath); err != nil {
+		return err
+	}
+
+	contents, err := json.Marshal(b.entry)
+	if err != nil {
+		return err
+	}
+
+	return replaceFileContents(entryPath, contents)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultIDs is the range of question IDs the site is known to serve.
+const defaultIDs = "1000-1305"
+
+// maxIDs keeps a typo in a range from queueing an endless scrape.
+const maxIDs = 1_000_000
+
+// maxQuestionID is the largest ID a range may name, so that stepping past
+// the end of a range can't overflow.
+const maxQuestionID = math.MaxInt32
+
+type idRange struct {
+	Start int
+	End   int
+}
+
+// idRanges is a set of question IDs, as sorted ranges that don't overlap.
+type idRanges []idRange
+
+// parseIDRanges parses a comma separated list of IDs and inclusive ranges,
+// such as "1000-1305,2000-2100,2500". Overlapping ranges are merged.
+func parseIDRanges(spec string) (idRanges, error) {
+	var ranges idRanges
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		startText, endText, isRange := strings.Cut(part, "-")
+		if !isRange {
+			endText = startText
+		}
+
+		start, err := strconv.Atoi(strings.TrimSpace(startText))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q in %q", startText, part)
+		}
+
+		end, err := strconv.Atoi(strings.TrimSpace(endText))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q in %q", endText, part)
+		}
+
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+
+		if end > maxQuestionID {
+			return nil, fmt.Errorf("invalid ID %d in %q, IDs go up to %d", end, part, maxQuestionID)
+		}
+
+		// Checking each range before they're merged keeps the total from
+		// overflowing, however many are given.
+		if end-start >= maxIDs {
+			return nil, fmt.Errorf("%q covers more than %d IDs", part, maxIDs)
+		}
+
+		ranges = append(ranges, idRange{Start: start, End: end})
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no IDs given")
+	}
+
+	merged := mergeIDRanges(ranges)
+	if merged.Len() > maxIDs {
+		return nil, fmt.Errorf("%s covers more than %d IDs", merged, maxIDs)
+	}
+
+	return merged, nil
+}
+
+// mergeIDRanges sorts ranges and merges those that overlap or touch.
+func mergeIDRanges(ranges []idRange) idRanges {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b idRange) int { return a.Start - b.Start })
+
+	merged := idRanges{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End+1 {
+			last.End = max(last.End, r.End)
+		} else {
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
+
+// Len returns the number of IDs in the ranges.
+func (r idRanges) Len() int {
+	n := 0
+	for _, rng := range r {
+		n += rng.End - rng.Start + 1
+	}
+
+	return n
+}
+
+// IDs returns every ID in the ranges in ascending order.
+func (r idRanges) IDs() []int {
+	ids := make([]int, 0, r.Len())
+	for _, rng := range r {
+		for id := rng.Start; id <= rng.End; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func (r idRanges) String() string {
+	parts := make([]string, len(r))
+	for i, rng := range r {
+		if rng.Start == rng.End {
+			parts[i] = strconv.Itoa(rng.Start)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", rng.Start, rng.End)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// sampleIDs picks n of the IDs at random using seed, returning them in
+// ascending order.
+func sampleIDs(ids []int, n int, seed int64) []int {
+	rng := rand.New(rand.NewSource(seed))
+	sample := slices.Clone(ids)
+	rng.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	sample = sample[:min(n, len(sample))]
+	slices.Sort(sample)
+
+	return sample
+}
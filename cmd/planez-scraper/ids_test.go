@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIDRanges(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantLen int
+		wantErr bool
+	}{
+		{spec: "1000-1305", want: "1000-1305", wantLen: 306},
+		{spec: "2500, 1000-1002,1001-1010,1011", want: "1000-1011,2500", wantLen: 13},
+		{spec: "7", want: "7", wantLen: 1},
+		{spec: "0-999999", want: "0-999999", wantLen: 1_000_000},
+		{spec: "0-1000000", wantErr: true},
+		{spec: "0-600000,600001-1200000", wantErr: true},
+		{spec: "0-9223372036854775807", wantErr: true},
+		{spec: "9223372036854775807", wantErr: true},
+		{spec: "2147483647", want: "2147483647", wantLen: 1},
+		{spec: "0-99999999999999999999", wantErr: true},
+		{spec: "1305-1000", wantErr: true},
+		{spec: "-5", wantErr: true},
+		{spec: "abc", wantErr: true},
+		{spec: " , ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseIDRanges(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseIDRanges(%q) = %s, want an error", tt.spec, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseIDRanges(%q) error = %v", tt.spec, err)
+			continue
+		}
+
+		if got.String() != tt.want || got.Len() != tt.wantLen || len(got.IDs()) != tt.wantLen {
+			t.Errorf("parseIDRanges(%q) = %s with %d IDs, want %s with %d", tt.spec, got, got.Len(), tt.want, tt.wantLen)
+		}
+	}
+}
+
+func TestIDRangesIDs(t *testing.T) {
+	ranges, err := parseIDRanges("5-7,2147483646-2147483647")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := ranges.IDs(), []int{5, 6, 7, 2147483646, 2147483647}; !slices.Equal(got, want) {
+		t.Errorf("IDs() = %v, want %v", got, want)
+	}
+}
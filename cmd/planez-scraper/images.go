@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// writeImageManifest records the stored path of each downloaded image,
+// keyed by the original image name referenced from the question data.
+func writeImageManifest(dir string, stored map[string]string) error {
+	path := filepath.Join(dir, "images.json")
+
+	manifest := make(map[string]string, len(stored))
+	for original, name := range stored {
+		manifest[original] = filepath.ToSlash(filepath.Join("images", name))
+	}
+
+	return writeJSONFile(path, manifest)
+}
+
+// imageReferences maps each image name referenced from the question data to
+// the IDs of the questions referencing it, in the order of the questions.
+func imageReferences(questions []Question) map[string][]int {
+	refs := make(map[string][]int)
+	for _, q := range questions {
+		if q.ImageFile != nil {
+			refs[*q.ImageFile] = append(refs[*q.ImageFile], q.QuestionID)
+		}
+	}
+
+	return refs
+}
+
+// writeImageIndex records the questions referencing each downloaded image,
+// keyed by the image's stored path. Images stored once for several names
+// list the questions referencing any of them, in order.
+func writeImageIndex(dir string, questions []Question, stored map[string]string) error {
+	path := filepath.Join(dir, "image_index.json")
+
+	index := make(map[string][]int, len(stored))
+	for original, ids := range imageReferences(questions) {
+		if name, ok := stored[original]; ok {
+			key := filepath.ToSlash(filepath.Join("images", name))
+			index[key] = append(index[key], ids...)
+		}
+	}
+
+	for _, ids := range index {
+		slices.Sort(ids)
+	}
+
+	return writeJSONFile(path, index)
+}
+
+func writeJSONFile(path string, v any) error {
+	return replaceFile(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(v)
+	})
+}
+
+// replaceFile writes the file at path with write, only replacing the file
+// already there once the new one has been written in full. Until then it is
+// written to a temporary file beside path, so a failure part way through
+// leaves the old file as it was.
+func replaceFile(path string, write func(w io.Writer) error) error {
+	file, err := createPendingFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Discard()
+		return fmt.Errorf("failed to write to %s: %v", path, err)
+	}
+
+	return file.Commit()
+}
+
+// replaceFileContents replaces the file at path with contents the same way
+// as replaceFile.
+func replaceFileContents(path string, contents []byte) error {
+	return replaceFile(path, func(w io.Writer) error {
+		_, err := w.Write(contents)
+		return err
+	})
+}
+
+// pendingFile is a temporary file beside path that replaces the file at path
+// once Commit is called, for replacing a file written over a longer time
+// than replaceFile's callback allows.
+type pendingFile struct {
+	*os.File
+	path string
+}
+
+func createPendingFile(path string) (*pendingFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+
+	return &pendingFile{File: file, path: path}, nil
+}
+
+// Commit replaces the file at path with what has been written.
+func (f *pendingFile) Commit() error {
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to %s: %v", f.path, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", f.path, err)
+	}
+
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", f.path, err)
+	}
+
+	return nil
+}
+
+// Discard removes what has been written, leaving the file at path as it was.
+func (f *pendingFile) Discard() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// pruneImages removes the files in the images directory under dir other than
+// the stored images and the gallery, once a full run has replaced them.
+func pruneImages(dir string, stored map[string]string) error {
+	keep := NewSet[string]()
+	keep.Add(galleryName)
+	for _, name := range stored {
+		keep.Add(name)
+	}
+
+	imagesDir := filepath.Join(dir, "images")
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %v", imagesDir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || keep.Contains(entry.Name()) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(imagesDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// missingIDs returns the IDs without a question in existing, keeping their
+// order.
+func missingIDs(ids []int, existing []Question) []int {
+	present := NewSet[int]()
+	for _, q := range existing {
+		present.Add(q.QuestionID)
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if !present.Contains(id) {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing
+}
+
+// mergeQuestions adds the scraped questions to the existing ones and returns
+// them all sorted by ID, along with the IDs of the questions whose text
+// differs between the two. Which copy of those is kept is settled by policy,
+// one of preferPolicies, comparing the questions' creation dates. Any other
+// question with the same ID is replaced with the scraped copy.
+func mergeQuestions(existing []Question, scraped []Question, policy string) ([]Question, []int) {
+	byID := make(map[int]Question, len(existing)+len(scraped))
+	for _, q := range existing {
+		byID[q.QuestionID] = q
+	}
+
+	var conflicts []int
+	for _, q := range scraped {
+		if old, ok := byID[q.QuestionID]; ok && (old.Question != q.Question || old.Answer != q.Answer) {
+			conflicts = append(conflicts, q.QuestionID)
+			q = prefer(policy, "remote", old, time.UnixMilli(int64(old.CreatedDate)), q, time.UnixMilli(int64(q.CreatedDate)))
+		}
+
+		byID[q.QuestionID] = q
+	}
+
+	merged := make([]Question, 0, len(byID))
+	for _, q := range byID {
+		merged = append(merged, q)
+	}
+
+	slices.SortFunc(merged, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+	slices.Sort(conflicts)
+
+	return merged, conflicts
+}
+
+// existingImages returns the stored name of each image already downloaded to
+// dir, keyed by its original name. The previous manifest is used when there
+// is one, since images may have been renamed to match their contents, and
+// otherwise any file with the original name counts.
+func existingImages(dir string, images []string) map[string]string {
+	var manifest map[string]string
+	if contents, err := os.ReadFile(filepath.Join(dir, "images.json")); err == nil {
+		json.Unmarshal(contents, &manifest)
+	}
+
+	stored := make(map[string]string)
+	for _, image := range images {
+		name := image
+		if path, ok := manifest[image]; ok {
+			name = strings.TrimPrefix(path, "images/")
+		}
+
+		if info, err := os.Stat(filepath.Join(dir, "images", name)); err == nil && info.Mode().IsRegular() {
+			stored[image] = name
+		}
+	}
+
+	return stored
+}
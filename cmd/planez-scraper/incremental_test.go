@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeQuestions(t *testing.T) {
+	question := func(id int, text string, created time.Time) Question {
+		return Question{planezQuestion: planezQuestion{QuestionID: id, Question: text, CreatedDate: int(created.UnixMilli())}}
+	}
+
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// Question 1000 was edited upstream, 1001 is the same, and 1002 is new.
+	// Question 1003 was edited without its creation date changing.
+	existing := []Question{question(1000, "Old?", march), question(1001, "Same?", march), question(1003, "Before?", march)}
+	scraped := []Question{question(1002, "New?", april), question(1000, "Edited?", april), question(1001, "Same?", april), question(1003, "After?", march)}
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: "remote", want: []string{"Edited?", "Same?", "New?", "After?"}},
+		{policy: "local", want: []string{"Old?", "Same?", "New?", "Before?"}},
+		{policy: "newest", want: []string{"Edited?", "Same?", "New?", "After?"}},
+		{policy: "oldest", want: []string{"Old?", "Same?", "New?", "After?"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			merged, conflicts := mergeQuestions(existing, scraped, tt.policy)
+
+			var got []string
+			for _, q := range merged {
+				got = append(got, q.Question)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mergeQuestions() = %q, want %q", got, tt.want)
+			}
+
+			if !slices.Equal(conflicts, []int{1000, 1003}) {
+				t.Errorf("mergeQuestions() conflicts = %v, want [1000 1003]", conflicts)
+			}
+
+			// Questions without a conflict are always the scraped copy.
+			if merged[1].CreatedDate != scraped[2].CreatedDate {
+				t.Errorf("mergeQuestions() kept the existing copy of unchanged question 1001")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+type latencyStats struct {
+	P50 time.Duration
+	P95 time.Duration
+	Max time.Duration
+}
+
+func (s latencyStats) String() string {
+	return fmt.Sprintf("p50 %s, p95 %s, max %s", s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+}
+
+func summarizeLatencies(latencies []time.Duration) latencyStats {
+	if len(latencies) == 0 {
+		return latencyStats{}
+	}
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	return latencyStats{
+		P50: percentile(sorted, 0.50),
+		P95: percentile(sorted, 0.95),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p (0 to 1) of an already
+// sorted, non-empty slice: the smallest value that at least p of the values
+// are no greater than.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		n    int
+		p    float64
+		want time.Duration
+	}{
+		{n: 1, p: 0.50, want: 1},
+		{n: 1, p: 0.95, want: 1},
+		{n: 2, p: 0.50, want: 1},
+		{n: 3, p: 0.50, want: 2},
+		{n: 4, p: 0.50, want: 2},
+		{n: 10, p: 0.95, want: 10},
+		// 95% of 12 is 11.4, so the 11th value is below the 95th
+		// percentile.
+		{n: 12, p: 0.95, want: 12},
+		{n: 20, p: 0.95, want: 19},
+		{n: 21, p: 0.95, want: 20},
+		{n: 32, p: 0.95, want: 31},
+		{n: 100, p: 0.95, want: 95},
+		{n: 101, p: 0.95, want: 96},
+	}
+
+	for _, tt := range tests {
+		// The values are 1 to n, so each is its own rank.
+		sorted := make([]time.Duration, tt.n)
+		for i := range sorted {
+			sorted[i] = time.Duration(i + 1)
+		}
+
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(1..%d, %v) = %d, want %d", tt.n, tt.p, got, tt.want)
+		}
+	}
+}
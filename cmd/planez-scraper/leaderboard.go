@@ -0,0 +1,263 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var leaderboardTemplate = template.Must(template.ParseFS(builtinTemplates, "templates/leaderboard.html"))
+
+// leaderboardFormats are the formats progress leaderboard can write.
+var leaderboardFormats = []string{"html", "markdown"}
+
+// masteryCount is how many of a set of questions a member has mastered.
+type masteryCount struct {
+	Mastered int
+	Total    int
+}
+
+func (c masteryCount) Percent() int {
+	if c.Total == 0 {
+		return 0
+	}
+
+	return c.Mastered * 100 / c.Total
+}
+
+func (c masteryCount) String() string {
+	return fmt.Sprintf("%d/%d (%d%%)", c.Mastered, c.Total, c.Percent())
+}
+
+// leaderboardRow is one member's standing in the group.
+type leaderboardRow struct {
+	Rank         int
+	Member       string
+	Certificates []masteryCount
+	Overall      masteryCount
+	Reviewed     int
+	LastReviewed time.Time
+}
+
+// leaderboard is the standing of every member of a study group, best first.
+type leaderboard struct {
+	Certificates []string
+	Rows         []leaderboardRow
+	GeneratedAt  time.Time
+}
+
+// memberProgress is the progress file of one member of a study group.
+type memberProgress struct {
+	Member string
+	Store  progressStore
+}
+
+// buildLeaderboard ranks members by how many questions they have mastered,
+// with the questions grouped by certificate. Members who have mastered as
+// many share a rank.
+func buildLeaderboard(questions []Question, members []memberProgress) (leaderboard, error) {
+	groups, err := groupQuestions("certificate", questions)
+	if err != nil {
+		return leaderboard{}, err
+	}
+
+	board := leaderboard{GeneratedAt: time.Now().UTC()}
+	for _, group := range groups {
+		board.Certificates = append(board.Certificates, group.Key)
+	}
+
+	for _, member := range members {
+		schedules := member.Store.Schedules()
+		row := leaderboardRow{Member: member.Member}
+		for _, group := range groups {
+			count := masteryCount{Total: len(group.Questions)}
+			for _, q := range group.Questions {
+				sched, ok := schedules[q.QuestionID]
+				if !ok {
+					continue
+				}
+
+				row.Reviewed++
+				if sched.Mastered() {
+					count.Mastered++
+				}
+
+				if sched.LastReviewed.After(row.LastReviewed) {
+					row.LastReviewed = sched.LastReviewed
+				}
+			}
+
+			row.Certificates = append(row.Certificates, count)
+			row.Overall.Mastered += count.Mastered
+			row.Overall.Total += count.Total
+		}
+
+		board.Rows = append(board.Rows, row)
+	}
+
+	slices.SortStableFunc(board.Rows, func(a, b leaderboardRow) int {
+		return cmp.Or(b.Overall.Mastered-a.Overall.Mastered, b.Reviewed-a.Reviewed, strings.Compare(a.Member, b.Member))
+	})
+
+	for i := range board.Rows {
+		board.Rows[i].Rank = i + 1
+		if i > 0 && board.Rows[i].Overall.Mastered == board.Rows[i-1].Overall.Mastered {
+			board.Rows[i].Rank = board.Rows[i-1].Rank
+		}
+	}
+
+	return board, nil
+}
+
+func writeLeaderboardMarkdown(w io.Writer, board leaderboard) error {
+	fmt.Fprintf(w, "# Study Group Progress\n\n")
+	fmt.Fprintf(w, "Questions mastered by each member, generated %s.\n\n", board.GeneratedAt.Format(time.DateOnly))
+
+	header := append([]string{"Rank", "Member"}, board.Certificates...)
+	header = append(header, "Overall", "Reviewed", "Last reviewed")
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+
+	for _, row := range board.Rows {
+		cells := []string{fmt.Sprint(row.Rank), strings.ReplaceAll(row.Member, "|", `\|`)}
+		for _, count := range row.Certificates {
+			cells = append(cells, count.String())
+		}
+
+		last := "never"
+		if !row.LastReviewed.IsZero() {
+			last = row.LastReviewed.Format(time.DateOnly)
+		}
+
+		cells = append(cells, row.Overall.String(), fmt.Sprint(row.Reviewed), last)
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	return nil
+}
+
+// progressMember names the member a progress file belongs to: the directory
+// it is in for a file named progress.json, as sync copies them down, or
+// otherwise the file's name without its extension.
+func progressMember(path string) string {
+	if filepath.Base(path) == "progress.json" {
+		return filepath.Base(filepath.Dir(path))
+	}
+
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// groupProgressFiles returns the progress files of the workspace's study
+// group, as copied down by sync, keyed by member.
+func groupProgressFiles(dir string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "progress.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	for _, path := range matches {
+		files[progressMember(path)] = path
+	}
+
+	return files, nil
+}
+
+func runProgressLeaderboard(args []string) error {
+	flags := flag.NewFlagSet("progress leaderboard", flag.ExitOnError)
+	format := flags.String("format", "markdown", "Format to write: "+strings.Join(leaderboardFormats, ", "))
+	out := flags.String("o", "", "Path to write the leaderboard to (default stdout)")
+	member := flags.String("member", defaultMember(), "Name to show your own progress under, when no files are given")
+	questionsPath := flags.String("questions", filepath.Join(activeWorkspace.DataDir(), "questions.json"), "Questions file, for grouping progress by certificate")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper progress leaderboard [flags] [[NAME=]FILE...]")
+		fmt.Fprintln(flags.Output(), "Without files, ranks your progress and the study group's from sync.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	files := make(map[string]string)
+	var v validator
+	v.Check(slices.Contains(leaderboardFormats, *format), "-format: unknown format %q%s", *format, didYouMean(*format, leaderboardFormats))
+	v.CheckFile("-questions", *questionsPath)
+	for _, arg := range flags.Args() {
+		name, path, named := strings.Cut(arg, "=")
+		if !named {
+			name, path = progressMember(arg), arg
+		}
+
+		v.CheckFile("FILE", path)
+		_, duplicate := files[name]
+		v.Check(!duplicate, "FILE: more than one progress file for %s, name them with NAME=FILE", name)
+		files[name] = path
+	}
+	if *out != "" {
+		v.CheckParentDir("-o", *out)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		group, err := groupProgressFiles(activeWorkspace.GroupDir())
+		if err != nil {
+			return err
+		}
+
+		files = group
+		if _, err := os.Stat(activeWorkspace.ProgressPath()); err == nil {
+			files[cmp.Or(*member, "me")] = activeWorkspace.ProgressPath()
+		}
+
+		if len(files) == 0 {
+			return fmt.Errorf("no progress to rank, import some with progress import or share it with sync")
+		}
+	}
+
+	questions, err := readQuestions(*questionsPath)
+	if err != nil {
+		return err
+	}
+
+	var members []memberProgress
+	for name, path := range files {
+		store, err := readProgress(path)
+		if err != nil {
+			return err
+		}
+
+		members = append(members, memberProgress{Member: name, Store: store})
+	}
+
+	board, err := buildLeaderboard(questions, members)
+	if err != nil {
+		return err
+	}
+
+	render := func(w io.Writer) error {
+		if *format == "html" {
+			return leaderboardTemplate.Execute(w, board)
+		}
+
+		return writeLeaderboardMarkdown(w, board)
+	}
+
+	if *out == "" {
+		err = render(os.Stdout)
+	} else {
+		err = replaceFile(*out, render)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write leaderboard: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// localIDSchemes are the ways -local-ids can identify questions. Both are
+// derived from a question's certificate and text rather than its upstream
+// ID, so they stay the same if upstream renumbers its questions.
+var localIDSchemes = []string{"hash", "uuid"}
+
+// localIDNamespace is the UUID namespace for the uuid scheme.
+var localIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte(defaultBaseURL))
+
+// questionFingerprint reduces a question to the parts that identify it,
+// ignoring markup, case, and spacing.
+func questionFingerprint(q Question) string {
+	text := strings.Join(strings.Fields(strings.ToLower(stripHTML(q.Question))), " ")
+	return string(q.Certificate) + "\x00" + text
+}
+
+// assignLocalIDs sets the local ID of every question using the given
+// scheme. Questions with the same fingerprint are told apart by the order of
+// their upstream IDs, so that each keeps its local ID in whatever order the
+// questions come, as long as they are assigned over the whole dataset.
+func assignLocalIDs(questions []Question, scheme string) {
+	duplicates := make(map[string][]int)
+	for _, q := range questions {
+		fingerprint := questionFingerprint(q)
+		duplicates[fingerprint] = append(duplicates[fingerprint], q.QuestionID)
+	}
+
+	for _, ids := range duplicates {
+		slices.Sort(ids)
+	}
+
+	for i, q := range questions {
+		fingerprint := questionFingerprint(q)
+		if n, _ := slices.BinarySearch(duplicates[fingerprint], q.QuestionID); n > 0 {
+			fingerprint += fmt.Sprintf("\x00%d", n)
+		}
+
+		switch scheme {
+		case "hash":
+			sum := sha256.Sum256([]byte(fingerprint))
+			questions[i].LocalID = hex.EncodeToString(sum[:8])
+		case "uuid":
+			questions[i].LocalID = uuid.NewSHA1(localIDNamespace, []byte(fingerprint)).String()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// exportLocalIDs runs the export command over questions with local IDs,
+// returning the local ID given to each question exported.
+func exportLocalIDs(t *testing.T, dir string, args ...string) map[int]string {
+	t.Helper()
+
+	out := filepath.Join(t.TempDir(), "export.json")
+	args = append([]string{"-data", dir, "-o", out, "-local-ids", "hash", "-notes", "", "-custom", "", "-group-notes=false"}, args...)
+	if err := runExport(args); err != nil {
+		t.Fatalf("export %v error = %v", args, err)
+	}
+
+	contents, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var questions []Question
+	if err := json.Unmarshal(contents, &questions); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[int]string)
+	for _, q := range questions {
+		ids[q.QuestionID] = q.LocalID
+	}
+
+	return ids
+}
+
+func TestLocalIDsFiltered(t *testing.T) {
+	// Questions 1001 through 1003 are the same question, listed out of
+	// order, so they are told apart by their upstream IDs.
+	duplicate := "What is the <b>maximum</b> speed below 10,000 feet?"
+	questions := []Question{
+		{planezQuestion: planezQuestion{QuestionID: 1003, Certificate: "private", Question: duplicate}},
+		{planezQuestion: planezQuestion{QuestionID: 1000, Certificate: "private", Question: "What does a steady red light gun signal mean?"}},
+		{planezQuestion: planezQuestion{QuestionID: 1001, Certificate: "private", Question: duplicate}},
+		{planezQuestion: planezQuestion{QuestionID: 1002, Certificate: "private", Question: duplicate}},
+	}
+
+	dir := t.TempDir()
+	if err := writeJSONFile(filepath.Join(dir, "questions.json"), questions); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := t.TempDir()
+	if err := writeJSONFile(filepath.Join(ws, "stars.json"), []int{1002, 1003}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(w workspace) { activeWorkspace = w }(activeWorkspace)
+	activeWorkspace = workspace{Dir: ws, ConfigDir: ws}
+
+	all := exportLocalIDs(t, dir)
+	starred := exportLocalIDs(t, dir, "-starred-only")
+
+	if len(all) != 4 || len(starred) != 2 {
+		t.Fatalf("exported %d and %d questions, want 4 and 2", len(all), len(starred))
+	}
+
+	seen := make(map[string]int)
+	for id, localID := range all {
+		if other, ok := seen[localID]; ok {
+			t.Errorf("questions %d and %d have the same local ID %s", id, other, localID)
+		}
+		seen[localID] = id
+	}
+
+	for id, localID := range starred {
+		if localID != all[id] {
+			t.Errorf("question %d has local ID %s when exported with -starred-only, want %s as in the full export", id, localID, all[id])
+		}
+	}
+
+	// The IDs don't depend on the order the questions are in either.
+	reversed := []Question{questions[3], questions[2], questions[1], questions[0]}
+	assignLocalIDs(reversed, "hash")
+	for _, q := range reversed {
+		if q.LocalID != all[q.QuestionID] {
+			t.Errorf("question %d has local ID %s in reverse order, want %s", q.QuestionID, q.LocalID, all[q.QuestionID])
+		}
+	}
+}
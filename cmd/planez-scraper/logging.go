@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// logLevels are the levels -log-level accepts.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// logFormats are the formats -log-format accepts.
+var logFormats = []string{"json", "text"}
+
+// structuredLogs is set when logs are written as JSON, for a log collector
+// rather than a person. The progress lines and summary written for people
+// are left out then, since they would be mixed in with the records.
+var structuredLogs bool
+
+// logOutput is where records are written: stderr, or the console while it
+// shows a live summary there.
+var logOutput = &switchWriter{w: os.Stderr}
+
+// switchWriter writes to a writer that can be swapped while it's in use.
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+// Set makes w the writer written to.
+func (s *switchWriter) Set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w = w
+}
+
+// setupLogging sends records at level and above to stderr in format.
+func setupLogging(level string, format string) error {
+	l, ok := logLevels[strings.ToLower(level)]
+	if !ok {
+		names := slices.Sorted(maps.Keys(logLevels))
+		return fmt.Errorf("-log-level: unknown level %q%s", level, didYouMean(level, names))
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(logOutput, opts)))
+		structuredLogs = true
+	default:
+		return fmt.Errorf("-log-format: unknown format %q%s", format, didYouMean(format, logFormats))
+	}
+
+	return nil
+}
+
+type loggerKey struct{}
+
+// withLogger returns a context carrying logger, for the transports to log
+// the requests made with it.
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger carried by ctx, or the default logger if it
+// carries none.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// fatal logs msg as an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// statusAttr returns the HTTP status a fetch failed with, or an empty
+// attribute, which handlers leave out, if it failed without one.
+func statusAttr(err error) slog.Attr {
+	var statusErr *planez.StatusError
+	if errors.As(err, &statusErr) {
+		return slog.Int("status", statusErr.Status)
+	}
+
+	return slog.Attr{}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// questionStream writes questions to a file as they're scraped, for
+// -low-memory, so that a run doesn't have to hold them all until the end.
+// The file is the same indented JSON array write produces, and only replaces
+// the one at path once Commit is called.
+//
+// Questions from a resumed run are merged in by ID as the scraped ones are
+// written, since the scraped ones come in the order of -ids.
+type questionStream struct {
+	file    *pendingFile
+	fields  []string
+	resumed []Question
+	written int
+}
+
+// createQuestionStream starts writing the questions for path, merging in
+// resumed, which must be sorted by ID. If fields is set, only those fields of
+// each question are written.
+func createQuestionStream(path string, fields []string, resumed []Question) (*questionStream, error) {
+	file, err := createPendingFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &questionStream{file: file, fields: fields, resumed: resumed}, nil
+}
+
+// Write adds q to the file, after any resumed questions that come before it.
+func (s *questionStream) Write(q Question) error {
+	for len(s.resumed) > 0 && s.resumed[0].QuestionID < q.QuestionID {
+		if err := s.encode(s.resumed[0]); err != nil {
+			return err
+		}
+
+		s.resumed = s.resumed[1:]
+	}
+
+	return s.encode(q)
+}
+
+func (s *questionStream) encode(q Question) error {
+	var value any = q
+	if s.fields != nil {
+		projected, err := projectQuestions([]Question{q}, s.fields)
+		if err != nil {
+			return fmt.Errorf("failed to encode question %d: %v", q.QuestionID, err)
+		}
+
+		value = projected[0]
+	}
+
+	// Indenting each question by one level inside the array matches what
+	// json.Encoder writes for the array as a whole.
+	encoded, err := json.MarshalIndent(value, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode question %d: %v", q.QuestionID, err)
+	}
+
+	separator := ",\n  "
+	if s.written == 0 {
+		separator = "[\n  "
+	}
+
+	if _, err := s.file.WriteString(separator); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", s.file.path, err)
+	}
+
+	if _, err := s.file.Write(encoded); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", s.file.path, err)
+	}
+
+	s.written++
+	return nil
+}
+
+// Commit writes the rest of the resumed questions, ends the array, and
+// replaces the file at path with it.
+func (s *questionStream) Commit() error {
+	for _, q := range s.resumed {
+		if err := s.encode(q); err != nil {
+			s.file.Discard()
+			return err
+		}
+	}
+
+	s.resumed = nil
+
+	end := "\n]\n"
+	if s.written == 0 {
+		end = "[]\n"
+	}
+
+	if _, err := s.file.WriteString(end); err != nil {
+		s.file.Discard()
+		return fmt.Errorf("failed to write to %s: %v", s.file.path, err)
+	}
+
+	return s.file.Commit()
+}
+
+// Discard removes what has been written, leaving the file at path as it was.
+func (s *questionStream) Discard() {
+	s.file.Discard()
+}
+
+// slimQuestion returns what the end of a run needs of a question it has
+// already written with -low-memory: enough to count it in the report and,
+// if it has an image, to list it in the image index and gallery.
+func slimQuestion(q Question) Question {
+	slim := Question{planezQuestion: planezQuestion{QuestionID: q.QuestionID, Certificate: q.Certificate, Type: q.Type}}
+	if q.ImageFile != nil {
+		slim.ImageFile = q.ImageFile
+		slim.Question = q.Question
+		slim.Provenance = q.Provenance
+	}
+
+	return slim
+}
+
+// loadPreviousProvenance reads the provenance of the questions written by a
+// previous run one question at a time, as provenanceByID does from the
+// questions loadPreviousQuestions reads, without holding the questions
+// themselves. The file is checked as loadPreviousQuestions checks it.
+func loadPreviousProvenance(path string) (map[int]Provenance, error) {
+	provenance := make(map[int]Provenance)
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return provenance, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	if token, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	} else if token != json.Delim('[') {
+		return nil, fmt.Errorf("failed to decode %s: expected an array of questions", path)
+	}
+
+	seen := NewSet[int]()
+	for decoder.More() {
+		var q struct {
+			QuestionID int         `json:"questionId"`
+			Provenance *Provenance `json:"provenance"`
+		}
+
+		if err := decoder.Decode(&q); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+		}
+
+		if err := checkPreviousID(seen, q.QuestionID); err != nil {
+			return nil, fmt.Errorf("invalid questions in %s: %v", path, err)
+		}
+
+		if q.Provenance != nil {
+			provenance[q.QuestionID] = *q.Provenance
+		}
+	}
+
+	// The end of the array is only read once every question has been, so a
+	// file cut short part way through fails here.
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	return provenance, nil
+}
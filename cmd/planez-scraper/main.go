@@ -0,0 +1,1069 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+const defaultBaseURL = planez.DefaultBaseURL
+
+var baseURL = defaultBaseURL
+
+// The question data model comes from the planez package, which the scraper
+// is built on.
+type (
+	Provenance = planez.Provenance
+	RunRef     = planez.RunRef
+)
+
+// apiClient returns a client for the site being scraped that makes its
+// requests with client.
+func apiClient(client *http.Client) *planez.Client {
+	return planez.NewClient(baseURL, client)
+}
+
+func questionURL(questionID int) string {
+	return apiClient(nil).QuestionURL(questionID)
+}
+
+func scrape(ctx context.Context, api *planez.Client, imgCache *Set[string], questionID int) (Question, error) {
+	data, err := api.Question(ctx, questionID)
+	if err != nil {
+		return Question{}, err
+	}
+
+	if data.ImageFile != nil {
+		imgCache.Add(*data.ImageFile)
+	}
+
+	return Question{planezQuestion: data}, nil
+}
+
+// write saves the scraped questions. If fields is set, only those fields of
+// each question are written.
+func write(path string, data []Question, fields []string) error {
+	if fields != nil {
+		projected, err := projectQuestions(data, fields)
+		if err != nil {
+			return fmt.Errorf("failed to encode questions: %v", err)
+		}
+
+		return writeJSONFile(path, projected)
+	}
+
+	questions := make([]planez.Question, len(data))
+	for i, q := range data {
+		questions[i] = q.Planez()
+	}
+
+	return replaceFile(path, func(w io.Writer) error {
+		return planez.NewQuestionWriter(w).Write(questions)
+	})
+}
+
+func readQuestions(path string) ([]Question, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	var data []Question
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	return data, nil
+}
+
+func readFileString(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return string(contents), nil
+}
+
+// readImages downloads every image in the cache with d, with up to
+// concurrency downloads at once. Downloading stops early if an image fails
+// with an error classified as fatal, or with the reason ctx was canceled.
+func readImages(ctx context.Context, d *imageDownloader, cache *Set[string], concurrency int) error {
+	images := cache.Values()
+	slices.Sort(images)
+	d.progress.Expect("images", len(images))
+
+	fetch := func(image string) imageFetch {
+		return d.fetch(ctx, image)
+	}
+
+	for image, result := range fetchInOrder(images, concurrency, fetch) {
+		if err := d.record(ctx, image, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readImage downloads an image into store, returning the name it was stored
+// as.
+func readImage(ctx context.Context, api *planez.Client, image string, store planez.ImageStore) (string, error) {
+	img, err := api.Image(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	defer img.Close()
+
+	if img.Name != image {
+		slog.Info("Image has the wrong extension", "image", image, "stored_as", img.Name)
+	}
+
+	return store.Save(img)
+}
+
+// errInterrupted stops a run when the scraper is asked to shut down.
+var errInterrupted = errors.New("interrupted")
+
+// errMaxDuration stops a run that has taken as long as -max-duration
+// allows. It is an interruption, so a later run resumes it.
+var errMaxDuration = fmt.Errorf("%w after reaching -max-duration", errInterrupted)
+
+// stopReason returns the error a run stopped by canceling ctx stops with.
+func stopReason(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), errMaxDuration) {
+		return errMaxDuration
+	}
+
+	return errInterrupted
+}
+
+// questionFetch is the outcome of fetching one question.
+type questionFetch struct {
+	question Question
+	source   string
+	class    errorClass
+	err      error
+	latency  time.Duration
+}
+
+// imageFetch is the outcome of downloading one image.
+type imageFetch struct {
+	name  string
+	class errorClass
+	err   error
+}
+
+var commands = map[string]func(args []string) error{
+	"assignment":    runAssignment,
+	"backup":        runBackup,
+	"diff":          runDiff,
+	"doctor":        runDoctor,
+	"discover":      runDiscover,
+	"estimate":      runEstimate,
+	"export":        runExport,
+	"fake-server":   runFakeServer,
+	"generate":      runGenerate,
+	"history":       runHistory,
+	"note":          runNote,
+	"overlap":       runOverlap,
+	"progress":      runProgressCommand,
+	"restore":       runRestore,
+	"serve":         runServe,
+	"star":          runStar,
+	"sync":          runSync,
+	"unstar":        runUnstar,
+	"verify-remote": runVerifyRemote,
+	"workspaces":    runWorkspaces,
+}
+
+func main() {
+	// Global options from the environment come before the command line's,
+	// so that the command line's win.
+	envGlobals := envGlobalArgs(os.Environ())
+	global, args, err := parseGlobalArgs(slices.Concat(envGlobals, os.Args[1:]))
+	if err != nil {
+		fatal("Invalid options", "error", err)
+	}
+
+	cmdGlobals := os.Args[1 : len(os.Args)-len(args)]
+
+	if err := setupLogging(global.LogLevel, global.LogFormat); err != nil {
+		fatal("Invalid options", "error", err)
+	}
+
+	if !global.Local {
+		if roots, err = xdgRoots(); err != nil {
+			fatal("Failed to find the data and config directories", "error", err)
+		}
+
+		localPaths = false
+	}
+
+	activeWorkspace = defaultWorkspace()
+	if global.Profile != "" {
+		if activeWorkspace, err = profileWorkspace(global.Profile); err != nil {
+			fatal("Failed to open profile", "profile", global.Profile, "error", err)
+		}
+	}
+
+	if !global.Local && global.Profile == "" {
+		checkLegacyData()
+	}
+
+	name := "scrape"
+	var command func(args []string) error
+	if len(args) > 0 {
+		if c, ok := commands[args[0]]; ok {
+			name, command, args = args[0], c, args[1:]
+		}
+	}
+
+	// Settings from the config file come first, then the environment, then
+	// the flags files, then the command line, so that later ones win.
+	names := commandNames()
+	configPath := cmp.Or(global.Config, activeWorkspace.ConfigPath())
+	config := configFile{}
+	if global.Config != "" || !localPaths || global.Profile != "" {
+		if config, err = readConfig(configPath, global.Config != "", names); err != nil {
+			fatal("Failed to read config file", "error", err)
+		}
+	}
+
+	// Global settings in the config file can only be read once the config
+	// file has been found, so they're applied now, below the environment
+	// and the command line.
+	if settings := config.GlobalArgs(); len(settings) > 0 {
+		if global, _, err = parseGlobalArgs(slices.Concat(settings, envGlobals, cmdGlobals)); err != nil {
+			fatal("Invalid options", "error", err)
+		}
+
+		if err := setupLogging(global.LogLevel, global.LogFormat); err != nil {
+			fatal("Invalid options", "error", err)
+		}
+	}
+
+	configured, err := activeWorkspace.configuredArgs(name)
+	if err != nil {
+		fatal("Failed to read default flags", "command", name, "error", err)
+	}
+
+	args = layeredArgs(name, config, os.Environ(), names, configured, args)
+
+	if command == nil {
+		runScrape(args)
+		return
+	}
+
+	if err := command(args); err != nil {
+		fatal("Failed to run command", "command", name, "error", err)
+	}
+}
+
+func runScrape(args []string) {
+	flag.StringVar(&baseURL, "base-url", defaultBaseURL, "Base URL of the site to scrape")
+	mirrorSpec := flag.String("mirrors", "", "Comma separated base URLs of mirrors to fall back on, in order, once the site fails 3 fetches in a row")
+	idSpec := flag.String("ids", defaultIDs, "Comma separated question IDs and ranges to scrape, e.g. 1000-1305,2000-2100")
+	sample := flag.Int("sample", 0, "Scrape only this many questions chosen at random from -ids, for a quick test run")
+	sampleSeed := flag.Int64("sample-seed", 0, "Seed for choosing the -sample questions (default random)")
+	ascii := flag.Bool("ascii", false, "Normalize curly quotes, dashes, and non-breaking spaces to ASCII")
+	statusRulesSpec := flag.String("status-rules", "", "Comma separated STATUS=CLASS rules for failed requests, e.g. 403=fatal,410=skip,5xx=retry (classes: error, retry, fatal, skip)")
+	concurrency := flag.Int("concurrency", 1, "Number of questions to fetch at once")
+	adaptive := flag.Bool("adaptive-concurrency", false, "Start with one request in flight to each host and raise it while requests succeed, up to -concurrency questions, backing off sharply on 429s, 5xx responses, and timeouts")
+	imageConcurrency := flag.Int("image-concurrency", 4, "Number of images to download at once")
+	lowMemory := flag.Bool("low-memory", false, "Keep memory use low for small devices such as a Raspberry Pi Zero, by fetching with one worker and writing questions out as they're scraped instead of holding them until the end")
+	hashImageNames := flag.Bool("hash-image-names", false, "Store images under the SHA-256 hash of their contents instead of the names questions reference them by, so that an image referenced under several names is stored once (images.json maps the names to the files)")
+	maxAttempts := flag.Int("max-attempts", 3, "Maximum attempts for requests that fail with a network error or a status classified as retry")
+	retryDelay := flag.Duration("retry-delay", time.Second, "Delay before the first retry of a failed request, doubling with each attempt")
+	connectTimeout := flag.Duration("connect-timeout", defaultConnectTimeout, "Give up on connecting to the site after this long")
+	requestTimeout := flag.Duration("request-timeout", defaultRequestTimeout, "Give up on each attempt at a request that hasn't been answered and read after this long")
+	tor := flag.Bool("tor", false, fmt.Sprintf("Make requests to the site through Tor's SOCKS proxy, so that it doesn't see your IP address (raises the default -connect-timeout to %s and -request-timeout to %s)", torConnectTimeout, torRequestTimeout))
+	torProxy := flag.String("tor-proxy", defaultTorProxy, "Address of Tor's SOCKS proxy for -tor, e.g. 127.0.0.1:9150 for Tor Browser")
+	maxDuration := flag.Duration("max-duration", 0, "Stop the run after this long, writing the questions scraped so far so that the next run resumes (0 for no limit)")
+	debugDir := flag.String("debug-dir", activeWorkspace.DebugDir(), "Directory to save responses that come back in the wrong shape to, such as an error page served in place of a question (empty to disable)")
+	verbose := flag.Bool("debug", false, "Include stack traces for items that panic in the failure report")
+	pprofAddr := flag.String("pprof", "", "Serve net/http/pprof profiles on this address while running, e.g. :6060")
+	faultSpec := flag.String("inject-faults", "", "Fail a fraction of requests for testing, e.g. timeout=5%,reset=1%,500=2%")
+	faultSeed := flag.Int64("inject-faults-seed", 1, "Seed for choosing which requests -inject-faults fails")
+	var notifySpecs stringsFlag
+	flag.Var(&notifySpecs, "notify", "Send a notification after the run, as [FILTER:]KIND=DESTINATION (repeatable; filters: always, change, new)")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "Directory to cache responses in, to only download what changed since the last run (empty to disable)")
+	historyPath := flag.String("history", activeWorkspace.HistoryPath(), "SQLite database to record run history in (empty to disable)")
+	rate := flag.Float64("rate", defaultRate, "Maximum requests per second to each host, shared by questions and images across all workers (0 for no limit)")
+	burst := flag.Int("burst", 1, "Number of requests that can be made back to back before -rate applies")
+	hostConcurrency := flag.Int("host-concurrency", 0, "Maximum requests in flight to each host at once (0 for no limit beyond -concurrency and -image-concurrency)")
+	certificateSpec := flag.String("certificate", "", "Comma separated certificates to keep questions for, e.g. PRIVATE or PPL,CPL (default all)")
+	fieldsSpec := flag.String("fields", "", "Comma separated fields to keep for each question, e.g. question,answer,certificate (images are only downloaded with imageFile)")
+	strict := flag.Bool("strict", false, "Treat anomalies in the data, such as missing answers or images, as errors and exit non-zero")
+	lenient := flag.Bool("lenient", false, "Annotate questions with anomalies in the data with a warnings array")
+	assumeYes := flag.Bool("yes", false, "Skip the confirmation of the site's terms before a large scrape")
+	incremental := flag.Bool("incremental", false, "Keep the existing data, scraping only questions and downloading only images that are missing from it")
+	force := flag.Bool("force", false, "With -incremental, scrape questions again even if they are already in the data")
+	preferPolicy := flag.String("prefer", "remote", "With -incremental, which copy to keep of a question whose text changed: "+strings.Join(preferPolicies, ", ")+" (newest and oldest go by its creation date)")
+	fresh := flag.Bool("fresh", false, "Start over instead of resuming a run that didn't finish")
+	preRun := flag.String("pre-run", "", "Shell command to run before scraping, which stops the run if it fails")
+	postRun := flag.String("post-run", "", "Shell command to run after scraping, with the outcome in PLANEZ_* environment variables")
+	summaryFile := flag.String("summary-file", "", "Write a JSON summary of the run to this file")
+	summaryFD := flag.Int("summary-fd", 0, "Write a JSON summary of the run to this open file descriptor, e.g. 3 (0 for none)")
+	outDir := flag.String("out", activeWorkspace.DataDir(), "Directory to write the questions and images to, created if needed")
+	questionsFile := flag.String("questions-file", "questions.json", "File to write the questions to, relative to -out unless absolute")
+	noColor := flag.Bool("no-color", false, "Don't color the progress and summary output (also disabled by setting NO_COLOR)")
+	writeReport := flag.Bool("report", false, "Also write the report printed at the end of the run to report.json in the data directory")
+	quiet := flag.Bool("quiet", false, "Only write the warnings and counts at the end of the run, leaving out the progress")
+	flag.String("profile", "", "Use the named workspace for data, history, and default flags (must come before any other flags)")
+	flag.Bool("local", false, "Keep data and state in the current directory, as older versions did (must come before any other flags)")
+	flag.String("log-level", "info", "Log records at this level and above: debug, info, warn, error (must come before any other flags)")
+	flag.String("config", "", "Read settings from this TOML file instead of config.toml in the config directory (must come before any other flags)")
+	flag.String("log-format", "text", "Format to log in: text, or json for a log collector, which leaves out the progress lines (must come before any other flags)")
+	hideFlags(flag.CommandLine, "inject-faults", "inject-faults-seed")
+	flag.CommandLine.Parse(args)
+
+	var v validator
+	explicit := NewSet[string]()
+	flag.Visit(func(f *flag.Flag) {
+		v.Check(!slices.Contains(globalFlags, f.Name), "-%s: must come before any other flags", f.Name)
+		explicit.Add(f.Name)
+	})
+	if *tor && !explicit.Contains("connect-timeout") {
+		*connectTimeout = torConnectTimeout
+	}
+	if *tor && !explicit.Contains("request-timeout") {
+		*requestTimeout = torRequestTimeout
+	}
+	v.Check(flag.NArg() == 0, "unknown command %q%s", flag.Arg(0), didYouMean(flag.Arg(0), commandNames()))
+	ids, err := parseIDRanges(*idSpec)
+	v.CheckErr("-ids", err)
+	v.Check(*sample >= 0, "-sample: must not be negative, got %d", *sample)
+	rules, err := parseStatusRules(*statusRulesSpec)
+	v.CheckErr("-status-rules", err)
+	v.Check(!*strict || !*lenient, "-strict and -lenient can't be used together")
+	v.Check(!*force || *incremental, "-force: only applies with -incremental")
+	v.Check(!explicit.Contains("prefer") || *incremental, "-prefer: only applies with -incremental")
+	v.Check(slices.Contains(preferPolicies, *preferPolicy), "-prefer: unknown policy %q%s", *preferPolicy, didYouMean(*preferPolicy, preferPolicies))
+	v.Check(*concurrency > 0, "-concurrency: must be at least 1, got %d", *concurrency)
+	v.Check(*imageConcurrency > 0, "-image-concurrency: must be at least 1, got %d", *imageConcurrency)
+	if *lowMemory {
+		v.Check(!explicit.Contains("concurrency") || *concurrency == 1, "-concurrency: must be 1 with -low-memory, got %d", *concurrency)
+		v.Check(!explicit.Contains("image-concurrency") || *imageConcurrency == 1, "-image-concurrency: must be 1 with -low-memory, got %d", *imageConcurrency)
+		v.Check(!*incremental, "-low-memory and -incremental can't be used together")
+		v.Check(len(notifySpecs) == 0, "-low-memory and -notify can't be used together")
+		*concurrency, *imageConcurrency = 1, 1
+	}
+	v.Check(!*adaptive || *concurrency > 1, "-adaptive-concurrency: needs a -concurrency above 1 to adapt up to")
+	v.Check(*maxAttempts > 0, "-max-attempts: must be at least 1, got %d", *maxAttempts)
+	v.Check(*retryDelay >= 0, "-retry-delay: must not be negative, got %s", *retryDelay)
+	v.Check(*connectTimeout > 0, "-connect-timeout: must be positive, got %s", *connectTimeout)
+	v.Check(*requestTimeout > 0, "-request-timeout: must be positive, got %s", *requestTimeout)
+	v.Check(*tor || !explicit.Contains("tor-proxy"), "-tor-proxy: only applies with -tor")
+	_, _, err = net.SplitHostPort(*torProxy)
+	v.CheckErr("-tor-proxy", err)
+	v.Check(*maxDuration >= 0, "-max-duration: must not be negative, got %s", *maxDuration)
+	v.CheckErr("-base-url", checkBaseURL(baseURL))
+	mirrorURLs, err := parseMirrors(*mirrorSpec)
+	v.CheckErr("-mirrors", err)
+	v.Check(*rate >= 0, "-rate: must not be negative, got %g", *rate)
+	v.Check(*burst > 0, "-burst: must be at least 1, got %d", *burst)
+	v.Check(*hostConcurrency >= 0, "-host-concurrency: must not be negative, got %d", *hostConcurrency)
+	v.Check(*summaryFD >= 0, "-summary-fd: must not be negative, got %d", *summaryFD)
+	v.Check(*outDir != "", "-out: must not be empty")
+	v.Check(*questionsFile != "", "-questions-file: must not be empty")
+	var faults []faultRule
+	if *faultSpec != "" {
+		faults, err = parseFaults(*faultSpec)
+		v.CheckErr("-inject-faults", err)
+	}
+	var certificates *Set[planez.Certificate]
+	if *certificateSpec != "" {
+		certificates, err = parseCertificates(*certificateSpec)
+		v.CheckErr("-certificate", err)
+	}
+	var fields []string
+	if *fieldsSpec != "" {
+		fields, err = parseFields(*fieldsSpec)
+		v.CheckErr("-fields", err)
+	}
+	var notifyTargets []notifyTarget
+	for _, spec := range notifySpecs {
+		target, err := parseNotifyTarget(spec)
+		v.CheckErr("-notify", err)
+		notifyTargets = append(notifyTargets, target)
+	}
+	if err := v.Err(); err != nil {
+		fatal("Invalid options", "error", err)
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	mirrors := newMirrorList(baseURL, mirrorURLs)
+
+	dataDir := *outDir
+	questionsPath := *questionsFile
+	if !filepath.IsAbs(questionsPath) {
+		questionsPath = filepath.Join(dataDir, questionsPath)
+	}
+
+	// With -low-memory, only the provenance of the previous questions is
+	// needed, since -incremental and -notify, which compare against the
+	// questions themselves, can't be used.
+	var previousQuestions []Question
+	var previous map[int]Provenance
+	if *lowMemory {
+		previous, err = loadPreviousProvenance(questionsPath)
+	} else if previousQuestions, err = loadPreviousQuestions(questionsPath); err == nil {
+		previous = provenanceByID(previousQuestions)
+	}
+
+	// Existing questions that can't be loaded are moved aside rather than
+	// replaced, and aren't added to, since the questions they no longer hold
+	// would be lost.
+	if err != nil {
+		aside, moveErr := setAside(questionsPath, time.Now())
+		if moveErr != nil {
+			fatal("Failed to load the existing questions", "error", err, "move_error", moveErr)
+		} else if *incremental {
+			fatal("Failed to load the existing questions to add to, moved them aside untouched", "error", err, "path", aside)
+		}
+
+		slog.Warn("Failed to load the existing questions, moved them aside untouched", "error", err, "path", aside)
+		previous = make(map[int]Provenance)
+	}
+
+	manifestPath := filepath.Join(dataDir, manifestFileName)
+	resumed := resumedWork{Images: make(map[string]string)}
+	if !*fresh {
+		var err error
+		if resumed, err = loadManifest(manifestPath); err != nil {
+			// A manifest that is corrupt part way through can't be trusted
+			// to resume from, so the run starts over with it moved aside.
+			var corruptErr *corruptManifestError
+			if !errors.As(err, &corruptErr) {
+				fatal("Failed to load the unfinished run", "error", err)
+			}
+
+			aside, moveErr := setAside(manifestPath, time.Now())
+			if moveErr != nil {
+				fatal("Failed to load the unfinished run", "error", err, "move_error", moveErr)
+			}
+
+			slog.Warn("The unfinished run's manifest is corrupt, moved it aside and starting the run over", "error", err, "path", aside)
+			resumed = resumedWork{Images: make(map[string]string)}
+		}
+	}
+
+	// The seed is one of the options a run has to share to resume it, so
+	// without -sample-seed the unfinished run's seed is reused.
+	if *sample > 0 {
+		*sampleSeed = chooseSampleSeed(*sampleSeed, resumed)
+	}
+
+	options := runOptions{
+		BaseURL:        baseURL,
+		IDs:            ids.String(),
+		Sample:         *sample,
+		SampleSeed:     *sampleSeed,
+		Fields:         fields,
+		ASCII:          *ascii,
+		Lenient:        *lenient,
+		Incremental:    *incremental,
+		Force:          *force,
+		HashImageNames: *hashImageNames,
+	}
+	if certificates != nil {
+		for _, certificate := range certificates.Values() {
+			options.Certificates = append(options.Certificates, string(certificate))
+		}
+	}
+
+	optionsHash := options.Hash()
+
+	if resumed.Any() && resumed.Options != "" && resumed.Options != optionsHash {
+		fatal("An unfinished run in the data directory was started with other options, run it again with the same options to resume it, or pass -fresh to start over", "manifest", manifestPath)
+	}
+
+	resuming := resumed.Any()
+
+	questionIDs := ids.IDs()
+	if *incremental && !*force {
+		questionIDs = missingIDs(questionIDs, previousQuestions)
+		slog.Info("Skipping questions already in the data", "skipped", ids.Len()-len(questionIDs), "data_dir", dataDir)
+	}
+
+	if *sample > 0 {
+		candidates := len(questionIDs)
+		questionIDs = sampleIDs(questionIDs, *sample, *sampleSeed)
+		slog.Info("Sampling questions", "sampled", len(questionIDs), "candidates", candidates, "seed", *sampleSeed)
+	}
+
+	if resuming {
+		done := NewSet[int]()
+		for _, q := range resumed.Questions {
+			done.Add(q.QuestionID)
+		}
+
+		questionIDs = slices.DeleteFunc(questionIDs, done.Contains)
+		slog.Info("Resuming a run that didn't finish", "questions", len(resumed.Questions), "images", len(resumed.Images))
+	}
+
+	if err := confirmTerms(baseURL, len(questionIDs), *rate, *assumeYes); err != nil {
+		fatal("Terms not accepted", "error", err)
+	}
+
+	// The layers are in the order documented on chain.
+	gate := &pauseGate{}
+	metrics := &requestMetrics{}
+	layers := []middleware{withRetries(rules, retryPolicy{maxAttempts: *maxAttempts, delay: *retryDelay}), withPause(gate)}
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		layers = append(layers, withLogging())
+	}
+
+	layers = append(layers, withMetrics(metrics))
+	if *adaptive {
+		layers = append(layers, withAdaptiveConcurrency(max(*concurrency, *imageConcurrency)))
+	}
+
+	if len(faults) > 0 {
+		slog.Info("Injecting faults into requests", "faults", *faultSpec)
+		layers = append(layers, withFaults(faults, *faultSeed))
+	}
+
+	if *hostConcurrency > 0 {
+		layers = append(layers, withHostSlots(*hostConcurrency))
+	}
+
+	if *rate > 0 {
+		layers = append(layers, withRateLimit(*rate, *burst))
+	}
+
+	layers = append(layers, withTimeout(*requestTimeout))
+
+	var cache *responseCache
+	if *cacheDir != "" {
+		if cache, err = openResponseCache(*cacheDir); err != nil {
+			fatal("Failed to open the response cache", "error", err)
+		}
+
+		layers = append(layers, withCache(cache))
+	}
+
+	base := newBaseTransport(*connectTimeout)
+	if *tor {
+		if err := checkTorProxy(*torProxy); err != nil {
+			fatal("Failed to route requests through Tor", "error", err)
+		}
+
+		slog.Info("Making requests through Tor", "proxy", *torProxy)
+		base.Proxy = http.ProxyURL(torProxyURL(*torProxy))
+	}
+
+	client := &http.Client{Transport: chain(base, layers...)}
+
+	if *pprofAddr != "" {
+		if err := startPprof(*pprofAddr); err != nil {
+			fatal("Failed to start pprof server", "error", err)
+		}
+	}
+
+	runStart := time.Now().UTC()
+	hook := hookEnv{BaseURL: baseURL, DataDir: dataDir, Profile: activeWorkspace.Profile, StartedAt: runStart}
+
+	if *preRun != "" {
+		if err := runHook("pre-run", *preRun, hook); err != nil {
+			fatal("Failed to run hook", "hook", "pre-run", "error", err)
+		}
+	}
+
+	// The questions a run replaces are kept for diff. A resumed run replaces
+	// the same ones as the run it resumes, which kept them already.
+	if !resuming {
+		if err := keepPreviousQuestions(questionsPath); err != nil {
+			fatal("Failed to keep the previous questions", "error", err)
+		}
+	}
+
+	for _, dir := range []string{filepath.Join(dataDir, "images"), filepath.Dir(questionsPath)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fatal("Failed to create directory", "dir", dir, "error", err)
+		}
+	}
+
+	if !resuming {
+		if err := os.Remove(manifestPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fatal("Failed to remove the manifest of an earlier run", "path", manifestPath, "error", err)
+		}
+	}
+
+	manifest, err := openManifest(manifestPath)
+	if err != nil {
+		fatal("Failed to open the run manifest", "error", err)
+	}
+
+	if !resuming {
+		if err := manifest.RecordOptions(options); err != nil {
+			slog.Warn("Failed to record progress", "error", err)
+		}
+	}
+
+	out := newConsole(os.Stderr, *noColor, *quiet || structuredLogs)
+	if structuredLogs {
+		out.w = io.Discard
+	}
+	imgCache := NewSet[string]()
+	seen := NewSet[int]()
+	failed := NewSet[int]()
+	skipped := 0
+	filtered := 0
+	mirrored := 0
+	extraFields := NewSet[string]()
+	newValues := NewSet[string]()
+	var runErrors []string
+	var anomalies []string
+	var latencies []time.Duration
+	var fatalErr error
+
+	// The first interrupt stops new requests from being made, so that the
+	// questions scraped so far can be written out. Once it has been handled,
+	// a second one quits straight away.
+	interrupted, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	go func() {
+		<-interrupted.Done()
+		stopSignals()
+		slog.Warn("Interrupted, writing the questions scraped so far (interrupt again to quit now)")
+	}()
+
+	// Reaching -max-duration stops the run like an interrupt. The context is
+	// canceled rather than given a deadline, so that the requests it cuts
+	// short aren't counted as failures.
+	ctx := interrupted
+	if *maxDuration > 0 {
+		var stopRun context.CancelCauseFunc
+		ctx, stopRun = context.WithCancelCause(ctx)
+		deadline := time.AfterFunc(*maxDuration, func() {
+			slog.Warn("Reached -max-duration, writing the questions scraped so far", "max_duration", *maxDuration)
+			stopRun(errMaxDuration)
+		})
+		defer deadline.Stop()
+	}
+
+	progress := newRunProgress()
+	progress.Expect("questions", len(questionIDs))
+	stopControls := watchControls(progress, gate, out)
+	defer stopControls()
+
+	stopProgress := out.ShowProgress(progress)
+	defer stopProgress()
+
+	fetch := func(id int) questionFetch {
+		start := time.Now()
+		item := fmt.Sprintf("question %d", id)
+		progress.Begin(item)
+		q, source, class, err := fetchFromMirrors(ctx, mirrors, func(source string) (Question, errorClass, error) {
+			return fetchWithRules(ctx, slog.With("question_id", id, "source", source), rules, func(ctx context.Context) (Question, error) {
+				return scrape(ctx, planez.NewClient(source, client), NewSet[string](), id)
+			})
+		})
+		progress.Finish("questions", item, class, err)
+
+		return questionFetch{q, source, class, err, time.Since(start)}
+	}
+
+	pages := &pageCapture{dir: *debugDir}
+
+	// Images referenced by questions are downloaded while the rest of the
+	// questions are scraped, except for those an earlier run already
+	// downloaded.
+	downloader := newImageDownloader(client, mirrors, dataDir, *hashImageNames, pages, rules, *verbose, out, progress, manifest)
+	prefetch := prefetchImages(ctx, downloader, *imageConcurrency, len(questionIDs))
+	downloaded := maps.Clone(resumed.Images)
+	if *incremental {
+		var previousImages []string
+		for _, q := range previousQuestions {
+			if q.ImageFile != nil {
+				previousImages = append(previousImages, *q.ImageFile)
+			}
+		}
+
+		maps.Copy(downloaded, existingImages(dataDir, previousImages))
+	}
+
+	// With -low-memory, questions are written out as they're scraped, and
+	// data only holds what the end of the run needs of them.
+	var stream *questionStream
+	if *lowMemory {
+		sorted := slices.Clone(resumed.Questions)
+		slices.SortFunc(sorted, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+		if stream, err = createQuestionStream(questionsPath, fields, sorted); err != nil {
+			fatal("Failed to write question data", "error", err)
+		}
+	}
+
+	var data []Question
+	questionShapes := &shapeWatch{kind: "questions"}
+	for i, result := range fetchInOrder(questionIDs, *concurrency, fetch) {
+		q, class, err := result.question, result.class, result.err
+		if err != nil && ctx.Err() != nil {
+			fatalErr = stopReason(ctx)
+			runErrors = append(runErrors, fatalErr.Error())
+			break
+		}
+
+		changed := questionShapes.Observe(err)
+
+		latencies = append(latencies, result.latency)
+		if class == classSkip {
+			slog.Info("Skipped question", "question_id", i, statusAttr(err), "error", err)
+			out.Status(statusSkip, "question %d: %v", i, err)
+			skipped++
+			continue
+		} else if err != nil {
+			slog.Warn("Failed to fetch question", "question_id", i, statusAttr(err), "duration", result.latency, "error", err)
+			pages.Keep(err, "question_id", i)
+			out.Warn(statusFail, "Failed questions", "question %d: %s", i, describeFailure(err, *verbose))
+			failed.Add(i)
+			runErrors = append(runErrors, describeFailure(err, *verbose))
+			if class == classFatal {
+				fatalErr = err
+				break
+			}
+
+			if changed != nil {
+				fatalErr = changed
+				runErrors = append(runErrors, fatalErr.Error())
+				break
+			}
+
+			continue
+		}
+
+		if certificates != nil && !certificates.Contains(q.Certificate) {
+			slog.Debug("Filtered out question", "question_id", i, "certificate", q.Certificate)
+			out.Status(statusSkip, "question %d: certificate %s not selected", i, q.Certificate)
+			filtered++
+			continue
+		}
+
+		if *ascii {
+			q = normalizeASCII(q)
+		}
+
+		for _, anomaly := range questionAnomalies(q, runStart) {
+			slog.Warn("Found anomaly", "question_id", i, "anomaly", anomaly)
+			out.Warn(statusWarn, "Anomalies", "question %d: %s", i, anomaly)
+			anomalies = append(anomalies, fmt.Sprintf("question %d: %s", i, anomaly))
+			if *lenient {
+				q.Warnings = append(q.Warnings, anomaly)
+			}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(q.Extra)) {
+			if !extraFields.Contains(name) {
+				extraFields.Add(name)
+				slog.Warn("Found unknown field", "question_id", i, "field", name)
+				out.Warn(statusWarn, "Unknown fields", "question %d: %s (kept as is)", i, name)
+			}
+		}
+
+		for _, value := range newTaxonomyValues(q) {
+			if !newValues.Contains(value) {
+				newValues.Add(value)
+				slog.Warn("Found new value", "question_id", i, "value", value)
+				out.Warn(statusWarn, "New values", "question %d: %s", i, value)
+			}
+		}
+
+		if fields != nil {
+			q = selectFields(q, fields)
+		}
+
+		q.Provenance = recordFetch(previous, i, RunRef{At: runStart, Source: planez.NewClient(result.source, nil).QuestionURL(i)})
+		if result.source != baseURL {
+			mirrored++
+		}
+
+		if q.ImageFile != nil {
+			imgCache.Add(*q.ImageFile)
+			if _, ok := downloaded[*q.ImageFile]; !ok {
+				prefetch.Queue(*q.ImageFile)
+			}
+		}
+
+		seen.Add(i)
+		out.Status(statusOK, "question %d", i)
+
+		if err := manifest.RecordQuestion(q); err != nil {
+			slog.Warn("Failed to record progress", "error", err)
+		}
+
+		if stream != nil {
+			if err := stream.Write(q); err != nil {
+				stream.Discard()
+				fatal("Failed to write question data", "error", err)
+			}
+
+			q = slimQuestion(q)
+		}
+
+		data = append(data, q)
+	}
+
+	if err := prefetch.Wait(); err != nil && fatalErr == nil {
+		fatalErr = err
+		runErrors = append(runErrors, fatalErr.Error())
+	}
+
+	if resuming {
+		data = append(resumed.Questions, data...)
+		slices.SortFunc(data, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+		for i, q := range data {
+			if q.ImageFile != nil {
+				imgCache.Add(*q.ImageFile)
+			}
+
+			if stream != nil {
+				data[i] = slimQuestion(q)
+			}
+		}
+	}
+
+	latency := summarizeLatencies(latencies)
+
+	kept := 0
+	if *incremental {
+		var conflicts []int
+		data, conflicts = mergeQuestions(previousQuestions, data, *preferPolicy)
+		if len(conflicts) > 0 {
+			slog.Info("Settled questions whose text changed since the last scrape", "questions", len(conflicts), "prefer", *preferPolicy)
+			slog.Debug("Questions whose text changed", "ids", conflicts)
+		}
+		kept = len(data) - seen.Len() - len(resumed.Questions)
+		for _, q := range data {
+			if q.ImageFile != nil {
+				imgCache.Add(*q.ImageFile)
+			}
+		}
+	}
+
+	if stream != nil {
+		err = stream.Commit()
+	} else {
+		err = write(questionsPath, data, fields)
+	}
+	if err != nil {
+		fatal("Failed to write question data", "error", err)
+	}
+
+	images := make(map[string]string)
+	if fatalErr == nil && *incremental {
+		images = existingImages(dataDir, imgCache.Values())
+	}
+
+	maps.Copy(images, resumed.Images)
+
+	// What's left are the images of questions kept from earlier runs that
+	// haven't been downloaded yet.
+	if fatalErr == nil {
+		toFetch := NewSet[string]()
+		for _, image := range imgCache.Values() {
+			if _, ok := images[image]; !ok && !prefetch.Queued(image) {
+				toFetch.Add(image)
+			}
+		}
+
+		if err := readImages(ctx, downloader, toFetch, *imageConcurrency); err != nil {
+			fatalErr = err
+			runErrors = append(runErrors, fatalErr.Error())
+		}
+	}
+
+	maps.Copy(images, downloader.Stored())
+	runErrors = append(runErrors, downloader.Failures()...)
+
+	if fatalErr == nil {
+		missing := NewSet[string]()
+		for _, image := range imgCache.Values() {
+			if _, ok := images[image]; !ok {
+				out.Warn(statusWarn, "Anomalies", "image %s: not downloaded", image)
+				anomalies = append(anomalies, fmt.Sprintf("image %s: not downloaded", image))
+				missing.Add(image)
+			}
+		}
+
+		// The questions were written before their images were downloaded,
+		// so they have to be written again to carry the image warnings. With
+		// -low-memory, they're no longer held to write again.
+		if *lenient && missing.Len() > 0 && stream != nil {
+			slog.Warn("Leaving out the warnings for images that weren't downloaded, since -low-memory doesn't hold the questions to write them again", "images", missing.Len())
+		} else if *lenient && missing.Len() > 0 {
+			for i, q := range data {
+				if q.ImageFile != nil && missing.Contains(*q.ImageFile) {
+					data[i].Warnings = append(data[i].Warnings, "image not downloaded")
+				}
+			}
+
+			if err := write(questionsPath, data, fields); err != nil {
+				fatal("Failed to write question data", "error", err)
+			}
+		}
+	}
+
+	if *strict {
+		runErrors = append(runErrors, anomalies...)
+	}
+
+	if err := writeImageManifest(dataDir, images); err != nil {
+		fatal("Failed to write image manifest", "error", err)
+	}
+
+	// The output of the previous run is replaced file by file as the new one
+	// is written, and the images it had that this run didn't download are
+	// only removed once the run has finished.
+	if fatalErr == nil && !*incremental {
+		if err := pruneImages(dataDir, images); err != nil {
+			slog.Warn("Failed to remove old images", "error", err)
+		}
+	}
+
+	if err := writeImageIndex(dataDir, data, images); err != nil {
+		fatal("Failed to write image index", "error", err)
+	}
+
+	if err := writeGallery(dataDir, data, images); err != nil {
+		fatal("Failed to write image gallery", "error", err)
+	}
+
+	// The manifest is kept if the run stopped early, so that running the
+	// same command again picks up where it left off.
+	if fatalErr == nil {
+		if err := manifest.Remove(); err != nil {
+			slog.Warn("Failed to clean up after the run", "error", err)
+		}
+	} else {
+		manifest.Close()
+	}
+
+	failedImages := imgCache.Len() - len(images)
+	if *historyPath != "" {
+		summary := runSummary{
+			StartedAt:     runStart,
+			Duration:      time.Since(runStart),
+			Scraped:       seen.Len(),
+			Failed:        failed.Len(),
+			Images:        len(images),
+			ImageFailures: failedImages,
+			Latency:       latency,
+			Errors:        runErrors,
+		}
+
+		if err := recordRun(*historyPath, summary); err != nil {
+			slog.Warn("Failed to record run history", "error", err)
+		}
+	}
+
+	if len(notifyTargets) > 0 {
+		notifyAll(notifyTargets, summarizeChanges(previousQuestions, data))
+	}
+
+	stopProgress()
+	out.Summary([]summaryRow{
+		{label: "Questions scraped", count: seen.Len(), detail: latency.String(), style: styleGreen},
+		{label: "Questions failed", count: failed.Len(), style: styleRed},
+		{label: "Questions skipped", count: skipped, optional: true},
+		{label: "Questions filtered", count: filtered, optional: true},
+		{label: "Questions kept", count: kept, optional: true},
+		{label: "Questions resumed", count: len(resumed.Questions), optional: true},
+		{label: "Questions from mirrors", count: mirrored, optional: true},
+		{label: "Images downloaded", count: len(images), style: styleGreen},
+		{label: "Images failed", count: failedImages, style: styleRed},
+		{label: "Responses reused", count: cache.Reused(), optional: true},
+		{label: "Anomalies", count: len(anomalies), style: styleYellow},
+		{label: "New values", count: newValues.Len(), style: styleYellow, optional: true},
+	})
+
+	report := newRunReport(data, progress, metrics, time.Since(runStart))
+	if err := writeRunReport(os.Stdout, report); err != nil {
+		slog.Warn("Failed to print run report", "error", err)
+	}
+
+	if *writeReport {
+		if err := writeJSONFile(filepath.Join(dataDir, "report.json"), report); err != nil {
+			slog.Warn("Failed to write run report", "error", err)
+		}
+	}
+
+	if structuredLogs {
+		slog.Info("Finished run",
+			"duration", time.Since(runStart),
+			"scraped", seen.Len(),
+			"failed", failed.Len(),
+			"skipped", skipped,
+			"filtered", filtered,
+			"kept", kept,
+			"resumed", len(resumed.Questions),
+			"mirrored", mirrored,
+			"images", len(images),
+			"image_failures", failedImages,
+			"reused", cache.Reused(),
+			"anomalies", len(anomalies),
+			"new_values", newValues.Len(),
+		)
+	}
+
+	hook.Finished = true
+	hook.Result = runResult(fatalErr, *strict && len(anomalies) > 0)
+	hook.Duration = time.Since(runStart)
+	hook.Scraped, hook.Failed = seen.Len(), failed.Len()
+	hook.Images, hook.ImageFailures = len(images), failedImages
+	hook.Anomalies = len(anomalies)
+
+	if *summaryFile != "" || *summaryFD != 0 {
+		values := newValues.Values()
+		slices.Sort(values)
+		summary := newMachineSummary(hook, skipped, kept, latency, runErrors, anomalies, values)
+		if err := writeMachineSummary(*summaryFile, *summaryFD, summary); err != nil {
+			slog.Warn("Failed to write run summary", "error", err)
+		}
+	}
+
+	if *postRun != "" {
+		if err := runHook("post-run", *postRun, hook); err != nil {
+			fatal("Failed to run hook", "hook", "post-run", "error", err)
+		}
+	}
+
+	var apiChanged *apiChangedError
+	if errors.Is(fatalErr, errMaxDuration) {
+		fatal("Reached -max-duration, wrote the questions scraped so far, run the same command to resume", "questions", len(data))
+	} else if errors.Is(fatalErr, errInterrupted) {
+		fatal("Interrupted, wrote the questions scraped so far, run the same command to resume", "questions", len(data))
+	} else if errors.As(fatalErr, &apiChanged) && apiChanged.Page() != "" {
+		fatal("Stopped because "+pageAdvice[apiChanged.Page()], "kind", apiChanged.kind, "reasons", apiChanged.Reasons(), "debug_dir", *debugDir)
+	} else if errors.As(fatalErr, &apiChanged) {
+		fatal("Stopped because the upstream API appears to have changed, the scraper needs updating to match it", "kind", apiChanged.kind, "reasons", apiChanged.Reasons(), "debug_dir", *debugDir)
+	} else if fatalErr != nil {
+		fatal("Stopped after a fatal error, run the same command to resume", "error", fatalErr)
+	}
+
+	if *strict && len(anomalies) > 0 {
+		fatal("Found anomalies in strict mode", "anomalies", len(anomalies))
+	}
+}
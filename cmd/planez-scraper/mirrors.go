@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// mirrorFailureThreshold is how many fetches in a row have to fail on a site
+// for a run to fall back to the next of -mirrors.
+const mirrorFailureThreshold = 3
+
+// parseMirrors parses a comma separated list of base URLs to fall back on.
+func parseMirrors(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var mirrors []string
+	for _, mirror := range strings.Split(spec, ",") {
+		mirror = strings.TrimSuffix(strings.TrimSpace(mirror), "/")
+		if err := checkBaseURL(mirror); err != nil {
+			return nil, fmt.Errorf("invalid mirror %q: %v", mirror, err)
+		}
+
+		mirrors = append(mirrors, mirror)
+	}
+
+	return mirrors, nil
+}
+
+// mirrorList holds the site being scraped followed by the mirrors to fall
+// back on, in order. Fetches start from the current source, which is the
+// site until it has failed mirrorFailureThreshold fetches in a row, and then
+// the next mirror, for the rest of the run. It is safe for concurrent use.
+type mirrorList struct {
+	sources []string
+
+	mu       sync.Mutex
+	current  int
+	failures int
+}
+
+func newMirrorList(primary string, mirrors []string) *mirrorList {
+	return &mirrorList{sources: append([]string{primary}, mirrors...)}
+}
+
+// Remaining returns the sources to try a fetch from, starting with the
+// current one.
+func (m *mirrorList) Remaining() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sources[m.current:]
+}
+
+// Observe records the outcome of a fetch from source. Only fetches from the
+// current source count towards falling back from it.
+func (m *mirrorList) Observe(source string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if source != m.sources[m.current] {
+		return
+	}
+
+	if err == nil {
+		m.failures = 0
+		return
+	}
+
+	m.failures++
+	if m.failures < mirrorFailureThreshold || m.current == len(m.sources)-1 {
+		return
+	}
+
+	m.current++
+	m.failures = 0
+	slog.Warn("Falling back to the next mirror", "failed", source, "failures", mirrorFailureThreshold, "mirror", m.sources[m.current])
+}
+
+// sourceFailed reports whether a fetch failed because of the source it was
+// made from: it never got a usable response, even after any retries, or the
+// response was of the wrong shape. Other failures, such as a question that
+// doesn't exist, are answers from a source that is working.
+func sourceFailed(class errorClass, err error) bool {
+	var shapeErr *planez.ShapeError
+	return class == classRetry || errors.As(err, &shapeErr)
+}
+
+// fetchFromMirrors fetches with fetch from the current source of mirrors,
+// and if the source fails, from each of the mirrors after it in turn until
+// one works. It returns the outcome of the last fetch and the source it came
+// from.
+func fetchFromMirrors[T any](ctx context.Context, mirrors *mirrorList, fetch func(source string) (T, errorClass, error)) (T, string, errorClass, error) {
+	sources := mirrors.Remaining()
+	for i, source := range sources {
+		value, class, err := fetch(source)
+		if ctx.Err() != nil || (err != nil && !sourceFailed(class, err)) {
+			return value, source, class, err
+		}
+
+		mirrors.Observe(source, err)
+		if err == nil || i == len(sources)-1 {
+			return value, source, class, err
+		}
+
+		slog.Info("Fetching again from the next mirror", "failed", source, "mirror", sources[i+1], "error", err)
+	}
+
+	panic("no sources to fetch from")
+}
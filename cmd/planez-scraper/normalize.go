@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+var asciiReplacer = strings.NewReplacer(
+	"\u2018", "'", // left single quote
+	"\u2019", "'", // right single quote
+	"\u201A", "'", // single low-9 quote
+	"\u201B", "'", // single high-reversed-9 quote
+	"\u2032", "'", // prime
+	"\u201C", `"`, // left double quote
+	"\u201D", `"`, // right double quote
+	"\u201E", `"`, // double low-9 quote
+	"\u201F", `"`, // double high-reversed-9 quote
+	"\u2033", `"`, // double prime
+	"\u2010", "-", // hyphen
+	"\u2011", "-", // non-breaking hyphen
+	"\u2012", "-", // figure dash
+	"\u2013", "-", // en dash
+	"\u2014", "--", // em dash
+	"\u2015", "--", // horizontal bar
+	"\u2212", "-", // minus sign
+	"\u2026", "...", // ellipsis
+	"\u00A0", " ", // non-breaking space
+	"\u2007", " ", // figure space
+	"\u2009", " ", // thin space
+	"\u202F", " ", // narrow non-breaking space
+	"\u200B", "", // zero-width space
+	"\uFEFF", "", // byte order mark
+)
+
+func normalizeASCII(q Question) Question {
+	q.Question = asciiReplacer.Replace(q.Question)
+	q.Answer = asciiReplacer.Replace(q.Answer)
+
+	return q
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func BenchmarkNormalizeASCII(b *testing.B) {
+	q := Question{planezQuestion: planezQuestion{
+		Question: strings.Repeat("What’s the “standard” rate turn — in degrees per second…? ", 8),
+		Answer:   strings.Repeat("3 degrees per second, a two‑minute turn. ", 8),
+	}}
+	b.SetBytes(int64(len(q.Question) + len(q.Answer)))
+
+	for b.Loop() {
+		normalizeASCII(q)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// questionNote is a personal note on a question, added with note add.
+type questionNote struct {
+	Text  string    `json:"text"`
+	Added time.Time `json:"added"`
+}
+
+// questionNotes are the notes on each question, keyed by question ID. They
+// are kept in their own file so that the scraped data is never modified.
+type questionNotes map[int][]questionNote
+
+func readNotes(path string) (questionNotes, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return questionNotes{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	notes := questionNotes{}
+	if err := json.Unmarshal(contents, &notes); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	return notes, nil
+}
+
+// applyNotes sets the notes of each question that has any.
+func applyNotes(questions []Question, notes questionNotes) {
+	for i, q := range questions {
+		for _, note := range notes[q.QuestionID] {
+			questions[i].Notes = append(questions[i].Notes, note.Text)
+		}
+	}
+}
+
+var noteCommands = map[string]func(args []string) error{
+	"add":  runNoteAdd,
+	"list": runNoteList,
+	"rm":   runNoteRemove,
+}
+
+func runNote(args []string) error {
+	names := slices.Sorted(maps.Keys(noteCommands))
+
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: %s", strings.Join(names, ", "))
+	}
+
+	command, ok := noteCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q%s", args[0], didYouMean(args[0], names))
+	}
+
+	return command(args[1:])
+}
+
+func parseQuestionID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid question ID %q", s)
+	}
+
+	return id, nil
+}
+
+func runNoteAdd(args []string) error {
+	flags := flag.NewFlagSet("note add", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper note add ID TEXT")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 2, "expected a question ID and the text of the note, got %d arguments", flags.NArg())
+	id, err := parseQuestionID(flags.Arg(0))
+	v.CheckErr("ID", err)
+	v.Check(strings.TrimSpace(flags.Arg(1)) != "", "TEXT: must not be empty")
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	path := activeWorkspace.NotesPath()
+	notes, err := readNotes(path)
+	if err != nil {
+		return err
+	}
+
+	notes[id] = append(notes[id], questionNote{Text: strings.TrimSpace(flags.Arg(1)), Added: time.Now().UTC()})
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	return writeJSONFile(path, notes)
+}
+
+func runNoteList(args []string) error {
+	flags := flag.NewFlagSet("note list", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper note list [ID]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() <= 1, "expected at most one question ID, got %d", flags.NArg())
+	only := 0
+	if flags.NArg() == 1 {
+		var err error
+		only, err = parseQuestionID(flags.Arg(0))
+		v.CheckErr("ID", err)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	notes, err := readNotes(activeWorkspace.NotesPath())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUESTION\tNOTE\tADDED\tTEXT")
+	for _, id := range slices.Sorted(maps.Keys(notes)) {
+		if flags.NArg() == 1 && id != only {
+			continue
+		}
+
+		for i, note := range notes[id] {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", id, i+1, note.Added.Format(time.DateOnly), note.Text)
+		}
+	}
+
+	return w.Flush()
+}
+
+func runNoteRemove(args []string) error {
+	flags := flag.NewFlagSet("note rm", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper note rm ID [NOTE]")
+		fmt.Fprintln(flags.Output(), "Removes every note on the question, or only the numbered one from note list.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 1 || flags.NArg() == 2, "expected a question ID and optionally a note number, got %d arguments", flags.NArg())
+	id, err := parseQuestionID(flags.Arg(0))
+	v.CheckErr("ID", err)
+	number := 0
+	if flags.NArg() == 2 {
+		number, err = strconv.Atoi(flags.Arg(1))
+		v.Check(err == nil && number > 0, "NOTE: expected a note number from note list, got %q", flags.Arg(1))
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	path := activeWorkspace.NotesPath()
+	notes, err := readNotes(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(notes[id]) == 0:
+		return fmt.Errorf("question %d has no notes", id)
+	case number > len(notes[id]):
+		return fmt.Errorf("question %d has %d notes, not %d", id, len(notes[id]), number)
+	case number == 0:
+		delete(notes, id)
+	default:
+		notes[id] = slices.Delete(notes[id], number-1, number)
+		if len(notes[id]) == 0 {
+			delete(notes, id)
+		}
+	}
+
+	return writeJSONFile(path, notes)
+}
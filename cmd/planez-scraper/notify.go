@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Notifier delivers a summary of what changed in a run to some destination.
+type Notifier interface {
+	Notify(changes changeSummary) error
+}
+
+// notifiers constructs a Notifier of each supported kind from the
+// destination given in its -notify spec.
+var notifiers = map[string]func(dest string) (Notifier, error){
+	"email":    newEmailNotifier,
+	"matrix":   newMatrixNotifier,
+	"ntfy":     newNtfyNotifier,
+	"pushover": newPushoverNotifier,
+	"slack":    newSlackNotifier,
+	"telegram": newTelegramNotifier,
+	"webhook":  newWebhookNotifier,
+}
+
+type notifyFilter string
+
+const (
+	notifyAlways notifyFilter = "always"
+	notifyChange notifyFilter = "change"
+	notifyNew    notifyFilter = "new"
+)
+
+// notifyTarget is a parsed -notify spec. The spec itself isn't kept, since
+// the destinations of some notifiers hold their tokens.
+type notifyTarget struct {
+	kind     string
+	filter   notifyFilter
+	notifier Notifier
+}
+
+// parseNotifyTarget parses a spec of the form [FILTER:]KIND=DESTINATION. The
+// filter defaults to "change". Errors leave out the destination.
+func parseNotifyTarget(spec string) (notifyTarget, error) {
+	target := notifyTarget{filter: notifyChange}
+
+	prefix, dest, ok := strings.Cut(spec, "=")
+	if !ok || dest == "" {
+		return notifyTarget{}, errors.New("invalid target, expected [FILTER:]KIND=DESTINATION")
+	}
+
+	kind := prefix
+	if filter, k, ok := strings.Cut(prefix, ":"); ok {
+		target.filter = notifyFilter(filter)
+		kind = k
+	}
+
+	if target.filter != notifyAlways && target.filter != notifyChange && target.filter != notifyNew {
+		return notifyTarget{}, fmt.Errorf("unknown filter %q, expected always, change, or new", target.filter)
+	}
+
+	newNotifier, ok := notifiers[kind]
+	if !ok {
+		return notifyTarget{}, fmt.Errorf("unknown notifier %q%s", kind, didYouMean(kind, notifierKinds()))
+	}
+
+	notifier, err := newNotifier(dest)
+	if err != nil {
+		return notifyTarget{}, fmt.Errorf("invalid %s destination: %v", kind, err)
+	}
+
+	target.kind, target.notifier = kind, notifier
+
+	return target, nil
+}
+
+func notifierKinds() []string {
+	kinds := make([]string, 0, len(notifiers))
+	for kind := range notifiers {
+		kinds = append(kinds, kind)
+	}
+
+	slices.Sort(kinds)
+
+	return kinds
+}
+
+func (t notifyTarget) wants(changes changeSummary) bool {
+	switch t.filter {
+	case notifyAlways:
+		return true
+	case notifyNew:
+		return len(changes.Added) > 0
+	default:
+		return changes.Any()
+	}
+}
+
+// notifyAll sends the summary to every target whose filter matches it. A
+// target that fails doesn't stop the others from being notified. Failures
+// are logged with the kind of notifier and the host it sent to, but not the
+// URL, which for some notifiers holds the token.
+func notifyAll(targets []notifyTarget, changes changeSummary) {
+	for _, target := range targets {
+		if !target.wants(changes) {
+			continue
+		}
+
+		if err := target.notifier.Notify(changes); err != nil {
+			args := []any{"kind", target.kind}
+			var notifyErr *notifyError
+			if errors.As(err, &notifyErr) {
+				args = append(args, "host", notifyErr.host)
+				err = notifyErr.err
+			}
+
+			slog.Warn("Failed to notify", append(args, "error", withoutURL(err))...)
+		}
+	}
+}
+
+// notifyError is a notification that failed to send, naming only the host it
+// was sent to.
+type notifyError struct {
+	host string
+	err  error
+}
+
+func (e *notifyError) Error() string {
+	return fmt.Sprintf("failed to notify %s: %v", e.host, e.err)
+}
+
+// withoutURL returns the error a *url.Error wraps, leaving out the URL.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
+
+type changeSummary struct {
+	Total   int   `json:"total"`
+	Added   []int `json:"added"`
+	Changed []int `json:"changed"`
+	Removed []int `json:"removed"`
+}
+
+func summarizeChanges(previous []Question, current []Question) changeSummary {
+	changes := changeSummary{Total: len(current), Added: []int{}, Changed: []int{}, Removed: []int{}}
+
+	before := make(map[int]Question, len(previous))
+	for _, q := range previous {
+		before[q.QuestionID] = q
+	}
+
+	after := NewSet[int]()
+	for _, q := range current {
+		after.Add(q.QuestionID)
+
+		if old, ok := before[q.QuestionID]; !ok {
+			changes.Added = append(changes.Added, q.QuestionID)
+		} else if len(changedFields(old, q)) > 0 {
+			changes.Changed = append(changes.Changed, q.QuestionID)
+		}
+	}
+
+	for _, q := range previous {
+		if !after.Contains(q.QuestionID) {
+			changes.Removed = append(changes.Removed, q.QuestionID)
+		}
+	}
+
+	slices.Sort(changes.Removed)
+
+	return changes
+}
+
+func (c changeSummary) Any() bool {
+	return len(c.Added) > 0 || len(c.Changed) > 0 || len(c.Removed) > 0
+}
+
+func (c changeSummary) Title() string {
+	if len(c.Added) > 0 {
+		return fmt.Sprintf("%d new planez questions", len(c.Added))
+	}
+
+	return "planez scrape finished"
+}
+
+func (c changeSummary) Text() string {
+	return fmt.Sprintf(
+		"Scraped %d questions: %d new, %d changed, %d removed.",
+		c.Total, len(c.Added), len(c.Changed), len(c.Removed),
+	)
+}
+
+var notifyClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON sends payload to url and treats any non-2xx response as an error.
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	return doNotifyRequest(req)
+}
+
+func doNotifyRequest(req *http.Request) error {
+	res, err := notifyClient.Do(req)
+	if err != nil {
+		return &notifyError{host: req.URL.Host, err: withoutURL(err)}
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &notifyError{host: req.URL.Host, err: fmt.Errorf("received status %d", res.StatusCode)}
+	}
+
+	return nil
+}
+
+type webhookNotifier struct {
+	url string
+}
+
+func newWebhookNotifier(dest string) (Notifier, error) {
+	if err := checkBaseURL(dest); err != nil {
+		return nil, withoutURL(err)
+	}
+
+	return &webhookNotifier{url: dest}, nil
+}
+
+// Notify posts the summary as JSON. The message is sent in a "text" field so
+// the payload is also accepted by Slack-style incoming webhooks.
+func (n *webhookNotifier) Notify(changes changeSummary) error {
+	return postJSON(n.url, struct {
+		Text string `json:"text"`
+		changeSummary
+	}{changes.Text(), changes})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const telegramAPIURL = "https://api.telegram.org"
+
+type matrixNotifier struct {
+	homeserver string
+	room       string
+	token      string
+}
+
+// newMatrixNotifier accepts an access token and the URL of a room on its
+// homeserver, as TOKEN@https://matrix.example.org/!room:example.org.
+func newMatrixNotifier(dest string) (Notifier, error) {
+	token, roomURL, ok := strings.Cut(dest, "@")
+	if !ok || token == "" {
+		return nil, errors.New("expected TOKEN@HOMESERVER_URL/ROOM_ID")
+	}
+
+	u, err := url.Parse(roomURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("expected TOKEN@HOMESERVER_URL/ROOM_ID")
+	}
+
+	room := strings.TrimPrefix(u.Path, "/")
+	if room == "" {
+		return nil, errors.New("missing room ID")
+	}
+
+	return &matrixNotifier{
+		homeserver: u.Scheme + "://" + u.Host,
+		room:       room,
+		token:      token,
+	}, nil
+}
+
+func (n *matrixNotifier) Notify(changes changeSummary) error {
+	body, err := json.Marshal(map[string]string{
+		"msgtype": "m.text",
+		"body":    changes.Title() + "\n" + changes.Text(),
+	})
+	if err != nil {
+		return err
+	}
+
+	txnID := fmt.Sprintf("planez-%d", time.Now().UnixNano())
+	endpoint := fmt.Sprintf(
+		"%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		n.homeserver, url.PathEscape(n.room), txnID,
+	)
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doNotifyRequest(req)
+}
+
+type telegramNotifier struct {
+	token string
+	chat  string
+}
+
+// newTelegramNotifier accepts a bot token and chat ID, as BOT_TOKEN@CHAT_ID.
+func newTelegramNotifier(dest string) (Notifier, error) {
+	token, chat, ok := strings.Cut(dest, "@")
+	if !ok || token == "" || chat == "" {
+		return nil, errors.New("expected BOT_TOKEN@CHAT_ID")
+	}
+
+	return &telegramNotifier{token: token, chat: chat}, nil
+}
+
+func (n *telegramNotifier) Notify(changes changeSummary) error {
+	return postJSON(telegramAPIURL+"/bot"+n.token+"/sendMessage", map[string]string{
+		"chat_id": n.chat,
+		"text":    changes.Title() + "\n" + changes.Text(),
+	})
+}
+
+type slackNotifier struct {
+	url string
+}
+
+// newSlackNotifier accepts the URL of a Slack incoming webhook.
+func newSlackNotifier(dest string) (Notifier, error) {
+	if err := checkBaseURL(dest); err != nil {
+		return nil, withoutURL(err)
+	}
+
+	return &slackNotifier{url: dest}, nil
+}
+
+func (n *slackNotifier) Notify(changes changeSummary) error {
+	return postJSON(n.url, map[string]string{
+		"text": "*" + changes.Title() + "*\n" + changes.Text(),
+	})
+}
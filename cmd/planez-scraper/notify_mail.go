@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const emailDestination = "smtp[s]://[USER:PASSWORD@]HOST[:PORT]/TO[,TO...][?from=FROM]"
+
+type emailNotifier struct {
+	host        string
+	addr        string
+	implicitTLS bool
+	auth        smtp.Auth
+	from        string
+	to          []string
+}
+
+// newEmailNotifier accepts the URL of an SMTP server with the recipients as
+// its path. smtp URLs use STARTTLS when the server offers it, on port 587 by
+// default, and smtps URLs connect with TLS, on port 465 by default. The
+// sender defaults to the user name, when it is an address.
+func newEmailNotifier(dest string) (Notifier, error) {
+	u, err := url.Parse(dest)
+	if err != nil || (u.Scheme != "smtp" && u.Scheme != "smtps") || u.Hostname() == "" {
+		return nil, errors.New("expected " + emailDestination)
+	}
+
+	n := &emailNotifier{host: u.Hostname(), implicitTLS: u.Scheme == "smtps"}
+
+	port := u.Port()
+	if port == "" {
+		port = "587"
+		if n.implicitTLS {
+			port = "465"
+		}
+	}
+
+	n.addr = net.JoinHostPort(n.host, port)
+
+	for _, to := range strings.Split(strings.TrimPrefix(u.Path, "/"), ",") {
+		if to == "" {
+			continue
+		}
+
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q", to)
+		}
+
+		n.to = append(n.to, addr.Address)
+	}
+
+	if len(n.to) == 0 {
+		return nil, errors.New("missing recipient, expected " + emailDestination)
+	}
+
+	from := u.Query().Get("from")
+	if from == "" && u.User != nil {
+		from = u.User.Username()
+	}
+
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, errors.New("missing sender, add ?from=ADDRESS")
+	}
+
+	n.from = addr.Address
+
+	// PlainAuth refuses to send the password over a connection that isn't
+	// encrypted, other than to localhost.
+	if u.User != nil {
+		password, _ := u.User.Password()
+		n.auth = smtp.PlainAuth("", u.User.Username(), password, n.host)
+	}
+
+	return n, nil
+}
+
+func (n *emailNotifier) Notify(changes changeSummary) error {
+	if err := n.send(n.message(changes, time.Now())); err != nil {
+		return &notifyError{host: n.addr, err: err}
+	}
+
+	return nil
+}
+
+// message formats the summary as an email, with CRLF line endings.
+func (n *emailNotifier) message(changes changeSummary, now time.Time) []byte {
+	headers := []string{
+		"From: " + n.from,
+		"To: " + strings.Join(n.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", changes.Title()),
+		"Date: " + now.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + changes.Text() + "\r\n")
+}
+
+func (n *emailNotifier) send(message []byte) error {
+	dialer := &net.Dialer{Timeout: notifyClient.Timeout}
+	conn, err := dialer.Dial("tcp", n.addr)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(notifyClient.Timeout))
+	if n.implicitTLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: n.host})
+	}
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && !n.implicitTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+
+	if n.auth != nil {
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
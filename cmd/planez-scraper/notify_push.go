@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	ntfyBaseURL = "https://ntfy.sh"
+	pushoverURL = "https://api.pushover.net/1/messages.json"
+)
+
+type ntfyNotifier struct {
+	url string
+}
+
+// newNtfyNotifier accepts either a bare topic on ntfy.sh or the full URL of a
+// topic on a self-hosted server.
+func newNtfyNotifier(dest string) (Notifier, error) {
+	if strings.Contains(dest, "://") {
+		if err := checkBaseURL(dest); err != nil {
+			return nil, withoutURL(err)
+		}
+
+		return &ntfyNotifier{url: dest}, nil
+	}
+
+	if strings.Contains(dest, "/") {
+		return nil, errors.New("invalid topic, expected a topic or the URL of one")
+	}
+
+	return &ntfyNotifier{url: ntfyBaseURL + "/" + dest}, nil
+}
+
+func (n *ntfyNotifier) Notify(changes changeSummary) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(changes.Text()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Title", changes.Title())
+	req.Header.Set("Tags", "airplane")
+
+	return doNotifyRequest(req)
+}
+
+type pushoverNotifier struct {
+	token string
+	user  string
+}
+
+// newPushoverNotifier accepts an application token and user key separated by
+// a colon.
+func newPushoverNotifier(dest string) (Notifier, error) {
+	token, user, ok := strings.Cut(dest, ":")
+	if !ok || token == "" || user == "" {
+		return nil, errors.New("expected APP_TOKEN:USER_KEY")
+	}
+
+	return &pushoverNotifier{token: token, user: user}, nil
+}
+
+func (n *pushoverNotifier) Notify(changes changeSummary) error {
+	form := url.Values{
+		"token":   {n.token},
+		"user":    {n.user},
+		"title":   {changes.Title()},
+		"message": {changes.Text()},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doNotifyRequest(req)
+}
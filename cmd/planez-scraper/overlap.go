@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// questionWords is the set of words in a question's text, ignoring markup,
+// case, and punctuation.
+func questionWords(q Question) *Set[string] {
+	words := NewSet[string]()
+	isSeparator := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	for _, word := range strings.FieldsFunc(strings.ToLower(stripHTML(q.Question)), isSeparator) {
+		words.Add(word)
+	}
+
+	return words
+}
+
+// similarity is the Jaccard index of two sets of words: the number of words
+// they share, divided by the number of distinct words in either. It is 1 for
+// questions with the same words and 0 for questions with none in common.
+func similarity(a *Set[string], b *Set[string]) float64 {
+	if a.Len() == 0 && b.Len() == 0 {
+		return 0
+	}
+
+	shared := 0
+	for _, word := range a.Values() {
+		if b.Contains(word) {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(a.Len()+b.Len()-shared)
+}
+
+// overlap is a pair of questions under different certificates whose text is
+// similar. A is the question with the lower ID.
+type overlap struct {
+	A, B  Question
+	Score float64
+}
+
+// findOverlaps pairs each question with its most similar question under every
+// other certificate, keeping the pairs that score at least minScore. Pairs
+// are sorted by score, most similar first.
+func findOverlaps(questions []Question, minScore float64) []overlap {
+	words := make([]*Set[string], len(questions))
+	for i, q := range questions {
+		words[i] = questionWords(q)
+	}
+
+	type pairKey struct{ a, b int }
+	found := make(map[pairKey]overlap)
+	for i, q := range questions {
+		best := make(map[planez.Certificate]int)
+		scores := make(map[planez.Certificate]float64)
+		for j, other := range questions {
+			if other.Certificate == q.Certificate {
+				continue
+			}
+
+			score := similarity(words[i], words[j])
+			if _, ok := best[other.Certificate]; !ok || score > scores[other.Certificate] {
+				best[other.Certificate], scores[other.Certificate] = j, score
+			}
+		}
+
+		for certificate, j := range best {
+			if scores[certificate] < minScore {
+				continue
+			}
+
+			a, b := q, questions[j]
+			if a.QuestionID > b.QuestionID {
+				a, b = b, a
+			}
+
+			found[pairKey{a.QuestionID, b.QuestionID}] = overlap{A: a, B: b, Score: scores[certificate]}
+		}
+	}
+
+	overlaps := make([]overlap, 0, len(found))
+	for _, o := range found {
+		overlaps = append(overlaps, o)
+	}
+
+	slices.SortFunc(overlaps, func(x, y overlap) int {
+		if x.Score != y.Score {
+			if x.Score > y.Score {
+				return -1
+			}
+
+			return 1
+		}
+
+		if x.A.QuestionID != y.A.QuestionID {
+			return x.A.QuestionID - y.A.QuestionID
+		}
+
+		return x.B.QuestionID - y.B.QuestionID
+	})
+
+	return overlaps
+}
+
+// overlapCounts returns, for each certificate, how many of its questions
+// overlap with each other certificate.
+func overlapCounts(overlaps []overlap) map[planez.Certificate]map[planez.Certificate]int {
+	seen := make(map[planez.Certificate]map[planez.Certificate]*Set[int])
+	add := func(q Question, other planez.Certificate) {
+		if seen[q.Certificate] == nil {
+			seen[q.Certificate] = make(map[planez.Certificate]*Set[int])
+		}
+
+		if seen[q.Certificate][other] == nil {
+			seen[q.Certificate][other] = NewSet[int]()
+		}
+
+		seen[q.Certificate][other].Add(q.QuestionID)
+	}
+
+	for _, o := range overlaps {
+		add(o.A, o.B.Certificate)
+		add(o.B, o.A.Certificate)
+	}
+
+	counts := make(map[planez.Certificate]map[planez.Certificate]int)
+	for certificate, others := range seen {
+		counts[certificate] = make(map[planez.Certificate]int)
+		for other, ids := range others {
+			counts[certificate][other] = ids.Len()
+		}
+	}
+
+	return counts
+}
+
+// truncate shortens s to at most n runes, marking where it was cut.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-1]) + "…"
+}
+
+func runOverlap(args []string) error {
+	flags := flag.NewFlagSet("overlap", flag.ExitOnError)
+	path := flags.String("questions", filepath.Join(activeWorkspace.DataDir(), "questions.json"), "Local questions file to compare")
+	minScore := flags.Float64("min-score", 0.8, "Minimum similarity, from 0 to 1, for two questions to be reported")
+	known := flags.String("known", "", "Only report overlaps with this certificate, such as one already held")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.CheckFile("-questions", *path)
+	v.Check(*minScore > 0 && *minScore <= 1, "-min-score: must be greater than 0 and at most 1, got %g", *minScore)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	questions, err := readQuestions(*path)
+	if err != nil {
+		return err
+	}
+
+	certificates := NewSet[planez.Certificate]()
+	totals := make(map[planez.Certificate]int)
+	for _, q := range questions {
+		certificates.Add(q.Certificate)
+		totals[q.Certificate]++
+	}
+
+	names := make([]string, 0, certificates.Len())
+	for _, certificate := range certificates.Values() {
+		names = append(names, string(certificate))
+	}
+
+	slices.Sort(names)
+
+	if *known != "" && !certificates.Contains(planez.Certificate(*known)) {
+		return fmt.Errorf("-known: no questions have certificate %q%s", *known, didYouMean(*known, names))
+	}
+
+	overlaps := findOverlaps(questions, *minScore)
+	if *known != "" {
+		overlaps = slices.DeleteFunc(overlaps, func(o overlap) bool {
+			return o.A.Certificate != planez.Certificate(*known) && o.B.Certificate != planez.Certificate(*known)
+		})
+
+		// The known certificate's question is listed first, so the other
+		// one reads as the question that can be skipped.
+		for i, o := range overlaps {
+			if o.B.Certificate == planez.Certificate(*known) {
+				overlaps[i].A, overlaps[i].B = o.B, o.A
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCORE\tQUESTION\tCERTIFICATE\tQUESTION\tCERTIFICATE\tTEXT")
+	for _, o := range overlaps {
+		text := strings.Join(strings.Fields(stripHTML(o.A.Question)), " ")
+		fmt.Fprintf(w, "%.2f\t%d\t%s\t%d\t%s\t%s\n", o.Score, o.A.QuestionID, o.A.Certificate, o.B.QuestionID, o.B.Certificate, truncate(text, 60))
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	counts := overlapCounts(overlaps)
+	fmt.Println()
+	for _, name := range names {
+		certificate := planez.Certificate(name)
+		if certificate == planez.Certificate(*known) {
+			continue
+		}
+
+		for _, other := range names {
+			if *known != "" && other != *known {
+				continue
+			}
+
+			if n := counts[certificate][planez.Certificate(other)]; n > 0 {
+				fmt.Printf("%d of %d %s questions also appear under %s\n", n, totals[certificate], name, other)
+			}
+		}
+	}
+
+	return nil
+}
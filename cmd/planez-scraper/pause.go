@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// pauseGate holds back work while a run is paused.
+type pauseGate struct {
+	mu sync.Mutex
+	// resumed is closed when the run is resumed, and is nil while it isn't
+	// paused.
+	resumed chan struct{}
+}
+
+// Toggle pauses the gate if it's open and resumes it otherwise, returning
+// whether it is now paused.
+func (g *pauseGate) Toggle() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+		return false
+	}
+
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Wait blocks while the gate is paused, or until ctx is canceled.
+func (g *pauseGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pausedTransport holds requests back while its gate is paused. Requests
+// that have already started aren't affected.
+type pausedTransport struct {
+	next http.RoundTripper
+	gate *pauseGate
+}
+
+func (t *pausedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.gate.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
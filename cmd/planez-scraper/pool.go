@@ -0,0 +1,53 @@
+package main
+
+import (
+	"iter"
+	"sync"
+)
+
+// fetchInOrder calls fetch for each key, such as a question ID, with up to
+// concurrency calls running at once, yielding the results in the order of
+// keys as they become available. Once the loop over the results stops, no
+// more calls are started, and the ones already running are left to finish.
+func fetchInOrder[K any, T any](keys []K, concurrency int, fetch func(key K) T) iter.Seq2[K, T] {
+	return func(yield func(K, T) bool) {
+		results := make([]chan T, len(keys))
+		for i := range results {
+			results[i] = make(chan T, 1)
+		}
+
+		jobs := make(chan int)
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go func() {
+			defer close(jobs)
+			for i := range keys {
+				select {
+				case jobs <- i:
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range min(concurrency, len(keys)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results[i] <- fetch(keys[i])
+				}
+			}()
+		}
+
+		for i, key := range keys {
+			if !yield(key, <-results[i]) {
+				return
+			}
+		}
+
+		wg.Wait()
+	}
+}
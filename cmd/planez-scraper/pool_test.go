@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/internal/fakeplanez"
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// BenchmarkFetchInOrder measures fetching questions the way a run does, with
+// fetchInOrder over scrape, from a fake site on a local port.
+func BenchmarkFetchInOrder(b *testing.B) {
+	const questions = 100
+
+	for _, latency := range []time.Duration{0, time.Millisecond} {
+		fake := fakeplanez.New(fakeplanez.Options{Latency: latency})
+		fake.AddSynthetic(1000, questions)
+		server := fake.Start()
+		b.Cleanup(server.Close)
+
+		ids := make([]int, questions)
+		for i := range ids {
+			ids[i] = 1000 + i
+		}
+
+		api := planez.NewClient(server.URL, server.Client())
+		logger := slog.New(slog.DiscardHandler)
+		fetch := func(id int) error {
+			_, _, err := fetchWithRules(context.Background(), logger, nil, func(ctx context.Context) (Question, error) {
+				return scrape(ctx, api, NewSet[string](), id)
+			})
+
+			return err
+		}
+
+		for _, concurrency := range []int{1, 4, 16} {
+			b.Run(fmt.Sprintf("latency=%s/concurrency=%d", latency, concurrency), func(b *testing.B) {
+				for b.Loop() {
+					for id, err := range fetchInOrder(ids, concurrency, fetch) {
+						if err != nil {
+							b.Fatalf("question %d: %v", id, err)
+						}
+					}
+				}
+
+				b.ReportMetric(float64(questions*b.N)/b.Elapsed().Seconds(), "questions/s")
+			})
+		}
+	}
+}
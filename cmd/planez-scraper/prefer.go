@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// preferPolicies are the ways -prefer settles a conflict between two copies
+// of the same thing. newest and oldest keep the copy with the later or
+// earlier time, and local and remote keep the copy already there or the one
+// coming in.
+var preferPolicies = []string{"newest", "oldest", "local", "remote"}
+
+// prefer returns the copy kept under policy, given the time of each copy.
+// Copies with the same time are settled with fallback, local or remote, the
+// default policy of the command settling them.
+func prefer[T any](policy string, fallback string, local T, localTime time.Time, remote T, remoteTime time.Time) T {
+	if policy == "newest" || policy == "oldest" {
+		if localTime.Equal(remoteTime) {
+			policy = fallback
+		} else if remoteTime.After(localTime) == (policy == "newest") {
+			policy = "remote"
+		} else {
+			policy = "local"
+		}
+	}
+
+	if policy == "remote" {
+		return remote
+	}
+
+	return local
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// imageDownloader downloads images into the images directory of dir,
+// recording each one in the manifest. It is safe for concurrent use.
+type imageDownloader struct {
+	client   *http.Client
+	mirrors  *mirrorList
+	dir      string
+	store    planez.ImageStore
+	rules    statusRules
+	verbose  bool
+	out      *console
+	progress *runProgress
+	manifest *runManifest
+
+	shapes *shapeWatch
+	pages  *pageCapture
+
+	mu       sync.Mutex
+	stored   map[string]string
+	failures []string
+}
+
+// newImageDownloader returns a downloader for the images of the data in dir.
+// If hashNames is set, images are stored under the hash of their contents,
+// so that an image referenced under several names is stored once.
+// Images that come back in the wrong shape are saved with pages.
+func newImageDownloader(client *http.Client, mirrors *mirrorList, dir string, hashNames bool, pages *pageCapture, rules statusRules, verbose bool, out *console, progress *runProgress, manifest *runManifest) *imageDownloader {
+	return &imageDownloader{
+		client:   client,
+		mirrors:  mirrors,
+		dir:      dir,
+		store:    planez.ImageStore{Dir: filepath.Join(dir, "images"), ContentAddressed: hashNames},
+		rules:    rules,
+		verbose:  verbose,
+		out:      out,
+		progress: progress,
+		manifest: manifest,
+		shapes:   &shapeWatch{kind: "images"},
+		pages:    pages,
+		stored:   make(map[string]string),
+	}
+}
+
+// Stored returns the name each image downloaded so far was stored as.
+func (d *imageDownloader) Stored() map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return maps.Clone(d.stored)
+}
+
+// Failures returns a description of each image that failed to download.
+func (d *imageDownloader) Failures() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string{}, d.failures...)
+}
+
+// fetch downloads an image.
+func (d *imageDownloader) fetch(ctx context.Context, image string) imageFetch {
+	if ctx.Err() != nil {
+		return imageFetch{err: ctx.Err()}
+	}
+
+	d.progress.Begin("image " + image)
+	// Images wait behind questions under the rate limit.
+	ctx = withPriority(ctx, priorityImage)
+	name, _, class, err := fetchFromMirrors(ctx, d.mirrors, func(source string) (string, errorClass, error) {
+		return fetchWithRules(ctx, slog.With("image", image, "source", source), d.rules, func(ctx context.Context) (string, error) {
+			return readImage(ctx, planez.NewClient(source, d.client), image, d.store)
+		})
+	})
+	d.progress.Finish("images", "image "+image, class, err)
+
+	return imageFetch{name, class, err}
+}
+
+// record reports the outcome of downloading an image. It returns the error
+// to stop downloading with: a failure classified as fatal, an
+// *apiChangedError once images keep failing with the wrong shape, or the
+// reason ctx was canceled, as given by stopReason.
+func (d *imageDownloader) record(ctx context.Context, image string, result imageFetch) error {
+	name, class, err := result.name, result.class, result.err
+	if err != nil && ctx.Err() != nil {
+		return stopReason(ctx)
+	}
+
+	changed := d.shapes.Observe(err)
+	switch {
+	case err == nil:
+		d.mu.Lock()
+		d.stored[image] = name
+		d.mu.Unlock()
+
+		d.out.Status(statusOK, "image %s", filepath.Join(d.dir, "images", name))
+		if err := d.manifest.RecordImage(image, name); err != nil {
+			slog.Warn("Failed to record progress", "error", err)
+		}
+	case class == classSkip:
+		slog.Info("Skipped image", "image", image, statusAttr(err), "error", err)
+		d.out.Status(statusSkip, "image %s: %v", image, err)
+	case class == classFatal:
+		return err
+	default:
+		slog.Warn("Failed to fetch image", "image", image, statusAttr(err), "error", err)
+		d.pages.Keep(err, "image", image)
+		d.out.Warn(statusFail, "Failed images", "image %s: %s", image, describeFailure(err, d.verbose))
+
+		d.mu.Lock()
+		d.failures = append(d.failures, describeFailure(err, d.verbose))
+		d.mu.Unlock()
+	}
+
+	return changed
+}
+
+// imagePrefetch downloads images while the questions that reference them
+// are still being scraped, rather than waiting for every question first, so
+// that the two overlap on a slow link.
+type imagePrefetch struct {
+	d      *imageDownloader
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// queued is only used by the goroutine queueing images.
+	queued *Set[string]
+	jobs   chan string
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// prefetchImages starts up to concurrency downloads at once with d as
+// images are queued. Up to capacity images can be waiting without holding
+// up the goroutine queueing them.
+func prefetchImages(ctx context.Context, d *imageDownloader, concurrency int, capacity int) *imagePrefetch {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &imagePrefetch{d: d, ctx: ctx, cancel: cancel, queued: NewSet[string](), jobs: make(chan string, max(capacity, 1))}
+
+	for range max(concurrency, 1) {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for image := range p.jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if err := d.record(ctx, image, d.fetch(ctx, image)); err != nil {
+					p.stop(err)
+				}
+			}
+		}()
+	}
+
+	return p
+}
+
+// stop cancels the downloads that haven't finished, keeping the first error
+// that stopped them.
+func (p *imagePrefetch) stop(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err == nil {
+		p.err = err
+	}
+
+	p.cancel()
+}
+
+// Queue downloads an image, unless it has already been queued.
+func (p *imagePrefetch) Queue(image string) {
+	if p.queued.Contains(image) {
+		return
+	}
+
+	p.queued.Add(image)
+	p.d.progress.Expect("images", 1)
+	p.jobs <- image
+}
+
+// Queued reports whether an image was queued, whether or not it has been
+// downloaded.
+func (p *imagePrefetch) Queued(image string) bool {
+	return p.queued.Contains(image)
+}
+
+// Wait waits for the queued images to be downloaded, returning the error
+// that stopped them, if any. No more images can be queued.
+func (p *imagePrefetch) Wait() error {
+	close(p.jobs)
+	p.wg.Wait()
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// review is one attempt at answering a question.
+type review struct {
+	QuestionID int       `json:"questionId"`
+	Correct    bool      `json:"correct"`
+	At         time.Time `json:"at"`
+
+	// Source says where the review came from, such as "paper" for results
+	// imported from a practice session away from the computer.
+	Source string `json:"source,omitempty"`
+}
+
+// reviewKey identifies a review, comparing its time by instant.
+type reviewKey struct {
+	QuestionID int
+	Correct    bool
+	At         int64
+	Source     string
+}
+
+func (r review) key() reviewKey {
+	return reviewKey{r.QuestionID, r.Correct, r.At.UnixNano(), r.Source}
+}
+
+// progressStore is every review recorded, in the order they were added.
+// Scheduling is worked out from the reviews rather than stored, so that
+// stores can be combined by adding their reviews together.
+type progressStore struct {
+	Reviews []review `json:"reviews"`
+}
+
+func readProgress(path string) (progressStore, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return progressStore{}, nil
+	} else if err != nil {
+		return progressStore{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var store progressStore
+	if err := json.Unmarshal(contents, &store); err != nil {
+		return progressStore{}, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	return store, nil
+}
+
+func writeProgress(path string, store progressStore) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	if store.Reviews == nil {
+		store.Reviews = []review{}
+	}
+
+	return writeJSONFile(path, store)
+}
+
+// Add records reviews that aren't already in the store, returning how many
+// were new. Importing the same results twice leaves the store unchanged.
+func (s *progressStore) Add(reviews []review) int {
+	existing := NewSet[reviewKey]()
+	for _, r := range s.Reviews {
+		existing.Add(r.key())
+	}
+
+	added := 0
+	for _, r := range reviews {
+		if !existing.Contains(r.key()) {
+			s.Reviews = append(s.Reviews, r)
+			added++
+		}
+	}
+
+	return added
+}
+
+// leitnerIntervals are how long a question waits before it is due again in
+// each box of the Leitner system. A correct answer moves a question up a
+// box, and a wrong one sends it back to the first.
+var leitnerIntervals = []time.Duration{
+	24 * time.Hour,
+	2 * 24 * time.Hour,
+	4 * 24 * time.Hour,
+	8 * 24 * time.Hour,
+	16 * 24 * time.Hour,
+}
+
+// masteredBox is the first box a question counts as mastered in.
+const masteredBox = 4
+
+// schedule is where a question stands in the Leitner system.
+type schedule struct {
+	Box          int
+	Right, Wrong int
+	LastReviewed time.Time
+	Due          time.Time
+}
+
+func (s schedule) Mastered() bool {
+	return s.Box >= masteredBox
+}
+
+// Schedules replays the reviews in time order to work out the schedule of
+// every reviewed question.
+func (s progressStore) Schedules() map[int]schedule {
+	reviews := slices.Clone(s.Reviews)
+	slices.SortStableFunc(reviews, func(a, b review) int { return a.At.Compare(b.At) })
+
+	schedules := make(map[int]schedule)
+	for _, r := range reviews {
+		sched := schedules[r.QuestionID]
+		if r.Correct {
+			sched.Right++
+			sched.Box = min(sched.Box+1, len(leitnerIntervals))
+		} else {
+			sched.Wrong++
+			sched.Box = 1
+		}
+
+		sched.LastReviewed = r.At
+		sched.Due = r.At.Add(leitnerIntervals[sched.Box-1])
+		schedules[r.QuestionID] = sched
+	}
+
+	return schedules
+}
+
+// parseResult reads whether a result column marks an answer as right.
+func parseResult(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "right", "correct", "pass", "yes", "y", "1", "true":
+		return true, nil
+	case "wrong", "incorrect", "fail", "no", "n", "0", "false":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("expected right or wrong, got %q", s)
+}
+
+// parseReviewDate reads the date a result was recorded on, as a date or an
+// RFC 3339 time.
+func parseReviewDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("expected a date such as 2024-06-01, got %q", s)
+}
+
+// readResults reads reviews from a CSV file of question IDs and results, with
+// an optional third column for the date. A first row that doesn't start with
+// a question ID is taken as a header. Rows without a date are given at.
+func readResults(r io.Reader, name string, at time.Time, source string) ([]review, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var reviews []review
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+
+		if len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "") {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil && n == 1 {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid question ID %q", name, n, record[0])
+		}
+
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected a question ID, a result, and optionally a date", name, n)
+		}
+
+		correct, err := parseResult(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+
+		reviewedAt := at
+		if len(record) == 3 && strings.TrimSpace(record[2]) != "" {
+			if reviewedAt, err = parseReviewDate(record[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+			}
+		}
+
+		reviews = append(reviews, review{QuestionID: id, Correct: correct, At: reviewedAt, Source: source})
+	}
+
+	return reviews, nil
+}
+
+var progressCommands = map[string]func(args []string) error{
+	"import":      runProgressImport,
+	"leaderboard": runProgressLeaderboard,
+	"show":        runProgressShow,
+}
+
+func runProgressCommand(args []string) error {
+	names := slices.Sorted(maps.Keys(progressCommands))
+
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: %s", strings.Join(names, ", "))
+	}
+
+	command, ok := progressCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q%s", args[0], didYouMean(args[0], names))
+	}
+
+	return command(args[1:])
+}
+
+func runProgressImport(args []string) error {
+	flags := flag.NewFlagSet("progress import", flag.ExitOnError)
+	path := flags.String("progress", activeWorkspace.ProgressPath(), "Progress file to import into")
+	source := flags.String("source", "paper", "Where the results came from, recorded with each review")
+	date := flags.String("date", "", "Date of the session, for rows without one (default today)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper progress import [flags] FILE.csv...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() > 0, "expected at least one CSV file of results")
+	for _, name := range flags.Args() {
+		v.CheckFile("FILE", name)
+	}
+	at := time.Now().UTC().Truncate(24 * time.Hour)
+	if *date != "" {
+		var err error
+		at, err = parseReviewDate(*date)
+		v.CheckErr("-date", err)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	var reviews []review
+	for _, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", name, err)
+		}
+
+		results, err := readResults(file, name, at, *source)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		reviews = append(reviews, results...)
+	}
+
+	store, err := readProgress(*path)
+	if err != nil {
+		return err
+	}
+
+	added := store.Add(reviews)
+	if err := writeProgress(*path, store); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d results, %d of them already recorded\n", len(reviews), len(reviews)-added)
+
+	return nil
+}
+
+func runProgressShow(args []string) error {
+	flags := flag.NewFlagSet("progress show", flag.ExitOnError)
+	path := flags.String("progress", activeWorkspace.ProgressPath(), "Progress file to show")
+	questionsPath := flags.String("questions", filepath.Join(activeWorkspace.DataDir(), "questions.json"), "Questions file, for grouping progress by certificate")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.CheckFile("-questions", *questionsPath)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	questions, err := readQuestions(*questionsPath)
+	if err != nil {
+		return err
+	}
+
+	store, err := readProgress(*path)
+	if err != nil {
+		return err
+	}
+
+	groups, err := groupQuestions("certificate", questions)
+	if err != nil {
+		return err
+	}
+
+	schedules := store.Schedules()
+	now := time.Now()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CERTIFICATE\tQUESTIONS\tREVIEWED\tMASTERED\tDUE")
+	for _, group := range groups {
+		reviewed, mastered, due := 0, 0, 0
+		for _, q := range group.Questions {
+			sched, ok := schedules[q.QuestionID]
+			if !ok {
+				continue
+			}
+
+			reviewed++
+			if sched.Mastered() {
+				mastered++
+			}
+
+			if !sched.Due.After(now) {
+				due++
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", group.Key, len(group.Questions), reviewed, mastered, due)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// loadPreviousQuestions reads the questions written by a previous run so that
+// their provenance can be carried forward and changes can be detected. There
+// are no questions on the first run. A file that can't be decoded, or holds
+// a question without an ID or the same question twice, is an error, since
+// building on it would lose the questions it no longer holds.
+func loadPreviousQuestions(path string) ([]Question, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var previous []Question
+	if err := json.Unmarshal(contents, &previous); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	seen := NewSet[int]()
+	for _, q := range previous {
+		if err := checkPreviousID(seen, q.QuestionID); err != nil {
+			return nil, fmt.Errorf("invalid questions in %s: %v", path, err)
+		}
+	}
+
+	return previous, nil
+}
+
+// checkPreviousID checks the ID of a question read from a previous run, which
+// must be set and not have been seen already.
+func checkPreviousID(seen *Set[int], id int) error {
+	if id <= 0 {
+		return fmt.Errorf("question without an ID")
+	} else if seen.Contains(id) {
+		return fmt.Errorf("question %d appears more than once", id)
+	}
+
+	seen.Add(id)
+	return nil
+}
+
+// maxSetAside is how many files set aside by setAside are kept for each file,
+// so that a file that keeps failing to load doesn't fill the disk with
+// copies of itself.
+const maxSetAside = 5
+
+// setAside moves a file that failed to load out of the way, to
+// <name>.corrupt-<time><ext> beside it, so that it can be looked at or
+// recovered by hand. For a questions file, this keeps the run from replacing
+// the last good copy kept by keepPreviousQuestions with it. Only the newest
+// maxSetAside copies of the file are kept.
+func setAside(path string, now time.Time) (string, error) {
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + ".corrupt-"
+	aside := filepath.Join(filepath.Dir(path), prefix+now.UTC().Format("20060102T150405Z")+ext)
+	if err := os.Rename(path, aside); err != nil {
+		return "", fmt.Errorf("failed to move %s aside: %v", path, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return aside, nil
+	}
+
+	// The times in the names sort in the order the copies were set aside,
+	// as do the entries.
+	var copies []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext) {
+			copies = append(copies, name)
+		}
+	}
+
+	for _, name := range copies[:max(0, len(copies)-maxSetAside)] {
+		os.Remove(filepath.Join(filepath.Dir(path), name))
+	}
+
+	return aside, nil
+}
+
+func provenanceByID(previous []Question) map[int]Provenance {
+	provenance := make(map[int]Provenance)
+	for _, q := range previous {
+		if q.Provenance != nil {
+			provenance[q.QuestionID] = *q.Provenance
+		}
+	}
+
+	return provenance
+}
+
+// recordFetch returns the provenance of a question fetched in the given run,
+// keeping the first-seen run from any earlier provenance.
+func recordFetch(previous map[int]Provenance, questionID int, run RunRef) *Provenance {
+	p := Provenance{FirstSeen: run, LastFetched: run}
+	if prev, ok := previous[questionID]; ok {
+		p.FirstSeen = prev.FirstSeen
+	}
+
+	return &p
+}
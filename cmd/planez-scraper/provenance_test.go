@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetAsideRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "questions.json")
+	start := time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	// Files beside it that aren't copies of it are left alone.
+	writeTestFiles(t, dir, map[string]string{"questions.previous.json": "[]", "manifest.corrupt-20260101T000000Z.jsonl": ""})
+
+	for i := range maxSetAside + 3 {
+		writeTestFiles(t, dir, map[string]string{"questions.json": "{"})
+		if _, err := setAside(path, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copies, err := filepath.Glob(filepath.Join(dir, "questions.corrupt-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(copies) != maxSetAside || filepath.Base(copies[0]) != "questions.corrupt-20260305T150000Z.json" {
+		t.Errorf("set aside copies = %v, want the newest %d", copies, maxSetAside)
+	}
+
+	for _, name := range []string{"questions.previous.json", "manifest.corrupt-20260101T000000Z.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("setAside removed %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// planezQuestion names the planez package's question for embedding in
+// Question, where embedding it by its own name would hide its Question field.
+type planezQuestion = planez.Question
+
+// Question is a question as the scraper stores and exports it: the site's
+// question, along with what the scraper records about it. The scraper's
+// fields are stored as fields the planez package doesn't model, named by
+// fields, so they are kept by programs that read the files with it.
+type Question struct {
+	planezQuestion
+
+	// Warnings lists anomalies found in the question when it was scraped,
+	// for scrapes that keep anomalous questions rather than failing.
+	Warnings []string
+
+	// LocalID is a stable identifier assigned when exporting. It is never set
+	// on scraped data.
+	LocalID string
+
+	// CustomSource is the file a user-authored question was read from,
+	// relative to the directory of custom questions. It is never set on
+	// scraped data.
+	CustomSource string
+
+	// Notes are personal notes on the question, added when exporting. They
+	// are never set on scraped data.
+	Notes []string
+}
+
+// fields returns the scraper's fields of the question by their JSON names.
+func (q *Question) fields() map[string]any {
+	return map[string]any{
+		"warnings":     &q.Warnings,
+		"localId":      &q.LocalID,
+		"customSource": &q.CustomSource,
+		"notes":        &q.Notes,
+	}
+}
+
+// Planez returns the question as the planez package models it, with the
+// scraper's fields set in Extra.
+func (q Question) Planez() planez.Question {
+	p := q.planezQuestion
+	p.Extra = maps.Clone(p.Extra)
+
+	for name, value := range q.fields() {
+		// The fields are strings and lists of strings, which always encode.
+		encoded, _ := json.Marshal(value)
+		switch string(encoded) {
+		case "null", `""`, "[]":
+			continue
+		}
+
+		if p.Extra == nil {
+			p.Extra = make(map[string]json.RawMessage)
+		}
+
+		p.Extra[name] = encoded
+	}
+
+	return p
+}
+
+func (q *Question) UnmarshalJSON(data []byte) error {
+	var p planez.Question
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	*q = Question{planezQuestion: p}
+
+	// The scraper's fields are matched without regard to case, the same as
+	// the modeled ones.
+	fields := q.fields()
+	for name, value := range p.Extra {
+		for field, dest := range fields {
+			if strings.EqualFold(name, field) {
+				if err := json.Unmarshal(value, dest); err != nil {
+					return fmt.Errorf("invalid value for field %s: %v", name, err)
+				}
+
+				delete(q.Extra, name)
+			}
+		}
+	}
+
+	if len(q.Extra) == 0 {
+		q.Extra = nil
+	}
+
+	return nil
+}
+
+func (q Question) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.Planez())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+func TestQuestionJSON(t *testing.T) {
+	stored := `{"answer":"","certificate":"private","createdDate":0,"imageFile":null,"question":"Why?","questionId":1000,"type":"all","customSource":"weather.md","localId":"1a2b","notes":["Ask about fog."],"upstreamField":1,"warnings":["empty answer"]}`
+
+	var q Question
+	if err := json.Unmarshal([]byte(stored), &q); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if q.QuestionID != 1000 || q.LocalID != "1a2b" || q.CustomSource != "weather.md" || !slices.Equal(q.Notes, []string{"Ask about fog."}) || !slices.Equal(q.Warnings, []string{"empty answer"}) {
+		t.Errorf("Unmarshal() = %+v", q)
+	}
+
+	if len(q.Extra) != 1 || string(q.Extra["upstreamField"]) != "1" {
+		t.Errorf("Unmarshal() Extra = %v, want only upstreamField", q.Extra)
+	}
+
+	encoded, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if string(encoded) != stored {
+		t.Errorf("Marshal() = %s, want %s", encoded, stored)
+	}
+
+	// Programs reading the file with the planez package keep the scraper's
+	// fields as fields it doesn't model.
+	var p planez.Question
+	if err := json.Unmarshal([]byte(stored), &p); err != nil {
+		t.Fatalf("planez Unmarshal() error = %v", err)
+	}
+
+	if len(p.Extra) != 5 {
+		t.Errorf("planez Unmarshal() Extra = %v, want the scraper's fields and upstreamField", p.Extra)
+	}
+
+	// Empty fields are left out.
+	encoded, err = json.Marshal(Question{planezQuestion: planezQuestion{QuestionID: 1000}, Notes: []string{}})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var fields map[string]any
+	json.Unmarshal(encoded, &fields)
+	for _, name := range []string{"warnings", "localId", "customSource", "notes"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("Marshal() = %s, want no %s", encoded, name)
+		}
+	}
+}
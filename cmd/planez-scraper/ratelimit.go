@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultRate keeps an unattended run to a pace the site is unlikely to
+// notice.
+const defaultRate = 2
+
+// requestPriority orders the requests waiting for their turn under the rate
+// limit. Lower values go first.
+type requestPriority int
+
+const (
+	// priorityQuestion is for the first attempt at fetching a question, which
+	// moves the run forward.
+	priorityQuestion requestPriority = iota
+	// priorityRetry is for attempts after the first, so that a burst of
+	// failures doesn't hold back questions that haven't been tried yet.
+	priorityRetry
+	// priorityImage is for images, including their retries, which can be
+	// downloaded whenever the questions leave room.
+	priorityImage
+)
+
+type priorityKey struct{}
+
+// withPriority returns a copy of ctx whose requests wait their turn with
+// priority p.
+func withPriority(ctx context.Context, p requestPriority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// priorityFrom returns the priority carried by ctx, or priorityQuestion if
+// it carries none.
+func priorityFrom(ctx context.Context) requestPriority {
+	if p, ok := ctx.Value(priorityKey{}).(requestPriority); ok {
+		return p
+	}
+
+	return priorityQuestion
+}
+
+// limitedTransport paces the requests made through it, however many workers
+// are making them, with a token bucket for each host, so that a slow or
+// strict host doesn't hold back requests to the others.
+type limitedTransport struct {
+	next  http.RoundTripper
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newLimitedTransport(next http.RoundTripper, rate float64, burst int) *limitedTransport {
+	return &limitedTransport{next: next, rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// bucket returns the token bucket for host, starting it full.
+func (t *limitedTransport) bucket(host string) *tokenBucket {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.buckets[host]
+	if !ok {
+		b = &tokenBucket{rate: t.rate, burst: t.burst, tokens: t.burst, last: time.Now()}
+		t.buckets[host] = b
+	}
+
+	return b
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.bucket(req.URL.Host).acquire(req.Context(), priorityFrom(req.Context())); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// tokenBucket holds up to burst tokens and refills at rate tokens per
+// second, and each request waits for a token before it starts. Waiting
+// requests are given tokens by priority, as carried by their contexts, and
+// in the order they arrived within a priority.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	waiting []*tokenWaiter
+	seq     int
+	timer   *time.Timer
+}
+
+// tokenWaiter is a request waiting for a token. ready is closed once it has
+// been given one.
+type tokenWaiter struct {
+	priority requestPriority
+	seq      int
+	ready    chan struct{}
+	granted  bool
+}
+
+// refill adds the tokens that have accumulated since the last refill. The
+// caller must hold b.mu.
+func (b *tokenBucket) refill() {
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// grant gives the tokens in the bucket to the waiting requests that go
+// first, and arranges to be called again when the next token has refilled if
+// any are left waiting. The caller must hold b.mu.
+func (b *tokenBucket) grant() {
+	b.refill()
+	for len(b.waiting) > 0 && b.tokens >= 1 {
+		i := 0
+		for j, w := range b.waiting {
+			if w.priority < b.waiting[i].priority || (w.priority == b.waiting[i].priority && w.seq < b.waiting[i].seq) {
+				i = j
+			}
+		}
+
+		w := b.waiting[i]
+		b.waiting = slices.Delete(b.waiting, i, i+1)
+		b.tokens--
+		w.granted = true
+		close(w.ready)
+	}
+
+	if len(b.waiting) > 0 && b.timer == nil {
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.timer = time.AfterFunc(wait, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			b.timer = nil
+			b.grant()
+		})
+	}
+}
+
+// acquire waits for a token for a request with priority p, or until ctx is
+// canceled.
+func (b *tokenBucket) acquire(ctx context.Context, p requestPriority) error {
+	b.mu.Lock()
+	b.refill()
+	if len(b.waiting) == 0 && b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.seq++
+	w := &tokenWaiter{priority: p, seq: b.seq, ready: make(chan struct{})}
+	b.waiting = append(b.waiting, w)
+	b.grant()
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// A token given to the request as it was canceled goes to the next one
+	// instead.
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if w.granted {
+		b.tokens = min(b.burst, b.tokens+1)
+	} else {
+		b.waiting = slices.DeleteFunc(b.waiting, func(other *tokenWaiter) bool { return other == w })
+	}
+
+	b.grant()
+
+	return ctx.Err()
+}
+
+// hostSlotsTransport lets at most n requests to each host be in flight at
+// once, from when they start until their bodies are closed, so that a host
+// that is slow to answer ties up no more than n of the workers.
+type hostSlotsTransport struct {
+	next http.RoundTripper
+	n    int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// hostSlots returns the slots for requests to host.
+func (t *hostSlotsTransport) hostSlots(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.slots == nil {
+		t.slots = make(map[string]chan struct{})
+	}
+
+	slots, ok := t.slots[host]
+	if !ok {
+		slots = make(chan struct{}, t.n)
+		t.slots[host] = slots
+	}
+
+	return slots
+}
+
+func (t *hostSlotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	slots := t.hostSlots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+
+	res.Body = &slotBody{ReadCloser: res.Body, slots: slots}
+	return res, nil
+}
+
+// slotBody gives back the slot of the request it answers once it's closed.
+type slotBody struct {
+	io.ReadCloser
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { <-b.slots })
+	return err
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// runReport counts what a run left in the data, so that a person can check
+// at a glance whether the scrape is complete.
+type runReport struct {
+	DurationSeconds float64 `json:"durationSeconds"`
+	Questions       int     `json:"questions"`
+	WithImages      int     `json:"withImages"`
+
+	// Certificates and Types count the questions with each certificate and
+	// type.
+	Certificates map[string]int `json:"certificates"`
+	Types        map[string]int `json:"types"`
+
+	// Failures counts the items of each kind that failed, by the status the
+	// site answered with, or "other" for failures without one.
+	Failures map[string]map[string]int `json:"failures"`
+
+	// Requests counts the requests made, including retries, and BytesRead
+	// the bytes read from their responses.
+	Requests  int   `json:"requests"`
+	BytesRead int64 `json:"bytesRead"`
+}
+
+// newRunReport counts the questions in data, which includes any kept from
+// earlier runs, the failures recorded in progress, and the requests recorded
+// in metrics.
+func newRunReport(data []Question, progress *runProgress, metrics *requestMetrics, duration time.Duration) runReport {
+	report := runReport{
+		DurationSeconds: duration.Seconds(),
+		Questions:       len(data),
+		Certificates:    make(map[string]int),
+		Types:           make(map[string]int),
+		Failures:        progress.Failures(),
+		Requests:        metrics.Requests(),
+		BytesRead:       metrics.Bytes(),
+	}
+
+	for _, q := range data {
+		report.Certificates[string(q.Certificate)]++
+		report.Types[string(q.Type)]++
+		if q.ImageFile != nil {
+			report.WithImages++
+		}
+	}
+
+	return report
+}
+
+// writeRunReport writes the report as a table for a person to read, with
+// the total of each group of counts beside its heading.
+func writeRunReport(w io.Writer, report runReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Questions\t%d\n", report.Questions)
+	fmt.Fprintf(tw, "With images\t%d\n", report.WithImages)
+
+	writeCounts := func(heading string, counts map[string]int) {
+		if len(counts) == 0 {
+			return
+		}
+
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\n", heading, total)
+		for _, key := range slices.Sorted(maps.Keys(counts)) {
+			fmt.Fprintf(tw, "  %s\t%d\n", cmp.Or(key, "(none)"), counts[key])
+		}
+	}
+
+	writeCounts("Certificates", report.Certificates)
+	writeCounts("Types", report.Types)
+	for _, kind := range slices.Sorted(maps.Keys(report.Failures)) {
+		writeCounts("Failed "+kind, report.Failures[kind])
+	}
+
+	fmt.Fprintf(tw, "Requests\t%d\t%s\n", report.Requests, formatBytes(report.BytesRead))
+
+	duration := time.Duration(report.DurationSeconds * float64(time.Second))
+	fmt.Fprintf(tw, "Duration\t%s\n", duration.Round(time.Second))
+
+	return tw.Flush()
+}
+
+// failureStatus is the key a failure is counted under in a report.
+func failureStatus(err error) string {
+	if attr := statusAttr(err); attr.Key != "" {
+		return strconv.FormatInt(attr.Value.Int64(), 10)
+	}
+
+	return "other"
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// manifestFileName is the manifest of the work done by a run that hasn't
+// finished, kept in the data directory.
+const manifestFileName = "manifest.jsonl"
+
+// manifestEntry is one line of the manifest: the hash of the options the run
+// was started with, along with the seed it sampled questions with, a
+// question as it will be written out, or an image and the name it was
+// stored as.
+type manifestEntry struct {
+	Options    string    `json:"options,omitempty"`
+	SampleSeed int64     `json:"sampleSeed,omitempty"`
+	Question   *Question `json:"question,omitempty"`
+	Image      string    `json:"image,omitempty"`
+	Stored     string    `json:"stored,omitempty"`
+}
+
+// runOptions are the options that decide what a run writes. A run is only
+// resumed by one started with the same options, so that a different scrape
+// into the same directory doesn't mix in the questions of the one that
+// didn't finish.
+type runOptions struct {
+	BaseURL        string   `json:"baseUrl"`
+	IDs            string   `json:"ids"`
+	Sample         int      `json:"sample"`
+	SampleSeed     int64    `json:"sampleSeed"`
+	Certificates   []string `json:"certificates"`
+	Fields         []string `json:"fields"`
+	ASCII          bool     `json:"ascii"`
+	Lenient        bool     `json:"lenient"`
+	Incremental    bool     `json:"incremental"`
+	Force          bool     `json:"force"`
+	HashImageNames bool     `json:"hashImageNames"`
+}
+
+// chooseSampleSeed returns the seed a run samples with: seed, if -sample-seed was
+// given, or the one the unfinished run being resumed sampled with, so that
+// the same command resumes it, or a new random one.
+func chooseSampleSeed(seed int64, resumed resumedWork) int64 {
+	if seed != 0 {
+		return seed
+	}
+
+	if resumed.SampleSeed != 0 {
+		return resumed.SampleSeed
+	}
+
+	return time.Now().UnixNano()
+}
+
+// Hash returns a hash of the options, to record in the manifest. Lists are
+// sorted first, so that the order they were given in doesn't matter.
+func (o runOptions) Hash() string {
+	o.Certificates = slices.Sorted(slices.Values(o.Certificates))
+	o.Fields = slices.Sorted(slices.Values(o.Fields))
+
+	encoded, _ := json.Marshal(o)
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// runManifest records each question and image as soon as it has been
+// fetched, so that a run which crashes or is interrupted can be resumed
+// from where it stopped. Lines are appended and written straight away, so
+// at most the line being written when the run stopped is lost.
+type runManifest struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// resumedWork is what an unfinished run had completed, and the hash of the
+// options it was started with. Options is empty for a manifest written
+// before they were recorded.
+type resumedWork struct {
+	Options   string
+	Questions []Question
+	Images    map[string]string
+
+	// SampleSeed is the seed the run sampled questions with, if it was
+	// started with -sample. Zero for a manifest written before it was
+	// recorded.
+	SampleSeed int64
+}
+
+// Any reports whether the unfinished run had completed anything.
+func (w resumedWork) Any() bool {
+	return len(w.Questions) > 0 || len(w.Images) > 0
+}
+
+// corruptManifestError is returned by loadManifest for a manifest with a
+// line that can't be decoded before its last, which wasn't cut off by the
+// run stopping and so means the manifest is damaged.
+type corruptManifestError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *corruptManifestError) Error() string {
+	return fmt.Sprintf("failed to decode %s:%d: %v", e.Path, e.Line, e.Err)
+}
+
+// loadManifest reads the manifest at path, returning no work if there is
+// none. A final line that can't be decoded was cut off when the run stopped,
+// and is ignored. Any other line that can't be decoded is reported with a
+// *corruptManifestError.
+func loadManifest(path string) (resumedWork, error) {
+	work := resumedWork{Images: make(map[string]string)}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return work, nil
+	} else if err != nil {
+		return resumedWork{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(contents), []byte("\n"))
+	for n, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry manifestEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if n == len(lines)-1 {
+				break
+			}
+
+			return resumedWork{}, &corruptManifestError{Path: path, Line: n + 1, Err: err}
+		}
+
+		if entry.Options != "" {
+			work.Options, work.SampleSeed = entry.Options, entry.SampleSeed
+		} else if entry.Question != nil {
+			work.Questions = append(work.Questions, *entry.Question)
+		} else if entry.Image != "" {
+			work.Images[entry.Image] = entry.Stored
+		}
+	}
+
+	return work, nil
+}
+
+// openManifest opens the manifest at path for appending, creating it if it
+// doesn't exist.
+func openManifest(path string) (*runManifest, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	return &runManifest{path: path, file: file}, nil
+}
+
+func (m *runManifest) record(entry manifestEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest entry: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := bufio.NewWriter(m.file)
+	w.Write(line)
+	w.WriteByte('\n')
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", m.path, err)
+	}
+
+	return nil
+}
+
+// RecordOptions records the hash of the options the run was started with,
+// and the seed it samples with, as the first line of a new manifest.
+func (m *runManifest) RecordOptions(options runOptions) error {
+	return m.record(manifestEntry{Options: options.Hash(), SampleSeed: options.SampleSeed})
+}
+
+// RecordQuestion records a question that has been fetched.
+func (m *runManifest) RecordQuestion(q Question) error {
+	return m.record(manifestEntry{Question: &q})
+}
+
+// RecordImage records an image that has been downloaded and the name it was
+// stored as.
+func (m *runManifest) RecordImage(image string, stored string) error {
+	return m.record(manifestEntry{Image: image, Stored: stored})
+}
+
+func (m *runManifest) Close() error {
+	return m.file.Close()
+}
+
+// Remove closes and deletes the manifest once the run it belongs to has
+// finished.
+func (m *runManifest) Remove() error {
+	m.Close()
+
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %v", m.path, err)
+	}
+
+	return nil
+}
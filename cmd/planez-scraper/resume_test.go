@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startRun writes the manifest of a run started with options that fetched
+// one question before it stopped.
+func startRun(t *testing.T, path string, options runOptions) {
+	t.Helper()
+
+	manifest, err := openManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer manifest.Close()
+
+	if err := manifest.RecordOptions(options); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manifest.RecordQuestion(Question{planezQuestion: planezQuestion{QuestionID: 1000}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResumeSampledRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), manifestFileName)
+
+	// The first run was given no -sample-seed, so it picked one at random.
+	first := runOptions{IDs: "1000-1305", Sample: 20}
+	first.SampleSeed = chooseSampleSeed(0, resumedWork{})
+	startRun(t, path, first)
+
+	resumed, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+
+	if resumed.SampleSeed != first.SampleSeed || len(resumed.Questions) != 1 {
+		t.Fatalf("loadManifest() = seed %d and %d questions, want seed %d and 1 question", resumed.SampleSeed, len(resumed.Questions), first.SampleSeed)
+	}
+
+	// Running the same command again picks the first run's seed, so its
+	// options match and it resumes.
+	again := runOptions{IDs: "1000-1305", Sample: 20}
+	again.SampleSeed = chooseSampleSeed(0, resumed)
+	if again.Hash() != resumed.Options {
+		t.Errorf("the same command without -sample-seed has options %s, want %s to resume the run", again.Hash(), resumed.Options)
+	}
+
+	// An explicit seed still wins, and then doesn't resume a run sampled
+	// with another.
+	if seed := chooseSampleSeed(first.SampleSeed+1, resumed); seed != first.SampleSeed+1 {
+		t.Errorf("chooseSampleSeed() = %d with -sample-seed %d", seed, first.SampleSeed+1)
+	}
+}
+
+func TestResumeUnsampledRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), manifestFileName)
+	options := runOptions{IDs: "1000-1305"}
+	startRun(t, path, options)
+
+	resumed, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+
+	if resumed.SampleSeed != 0 || resumed.Options != options.Hash() {
+		t.Errorf("loadManifest() = seed %d, options %s, want no seed and options %s", resumed.SampleSeed, resumed.Options, options.Hash())
+	}
+}
+
+func TestLoadManifestCorrupt(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		line     int
+	}{
+		{name: "cut off", manifest: `{"options":"a"}` + "\n" + `{"question":{"questionId":1000}}` + "\n" + `{"question":{"quest`},
+		{name: "corrupt", manifest: `{"options":"a"}` + "\n" + "\x00\x00\x00" + "\n" + `{"question":{"questionId":1000}}` + "\n", line: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), manifestFileName)
+			if err := os.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			resumed, err := loadManifest(path)
+			if tt.line == 0 {
+				if err != nil || len(resumed.Questions) != 1 {
+					t.Errorf("loadManifest() = %d questions, %v, want the question before the cut off line", len(resumed.Questions), err)
+				}
+
+				return
+			}
+
+			var corruptErr *corruptManifestError
+			if !errors.As(err, &corruptErr) || corruptErr.Line != tt.line {
+				t.Fatalf("loadManifest() error = %v, want a *corruptManifestError for line %d", err, tt.line)
+			}
+
+			aside, err := setAside(path, time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("setAside() error = %v", err)
+			}
+
+			if want := filepath.Join(filepath.Dir(path), "manifest.corrupt-20260305T120000Z.jsonl"); aside != want {
+				t.Errorf("setAside() = %s, want %s", aside, want)
+			}
+
+			if resumed, err := loadManifest(path); err != nil || resumed.Any() {
+				t.Errorf("loadManifest() after setting it aside = %+v, %v, want no work", resumed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// maxServeLimit caps how many questions one response from serve lists, so
+// that clients page through a large dataset instead of taking it all at once.
+const maxServeLimit = 1000
+
+// servedData is the scraped data behind serve. It reloads the data when
+// questions.json changes, so that a scrape into the same directory shows up
+// without restarting the server. It is safe for concurrent use.
+type servedData struct {
+	dir string
+
+	mu       sync.Mutex
+	modified time.Time
+	data     exportDataset
+	byID     map[int]Question
+}
+
+// Current returns the data as of the last change to questions.json. If the
+// data can't be reloaded, the data loaded last is kept.
+func (d *servedData) Current() (exportDataset, map[int]Question) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.reload(); err != nil {
+		slog.Warn("Failed to reload the data, serving what was loaded before", "error", err)
+	}
+
+	return d.data, d.byID
+}
+
+// reload loads the data if questions.json has changed since it was last
+// loaded. The caller must hold d.mu.
+func (d *servedData) reload() error {
+	info, err := os.Stat(filepath.Join(d.dir, "questions.json"))
+	if err != nil {
+		return err
+	} else if info.ModTime().Equal(d.modified) {
+		return nil
+	}
+
+	data, err := loadExportDataset(d.dir)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(data.Questions, func(a, b Question) int { return a.QuestionID - b.QuestionID })
+
+	byID := make(map[int]Question, len(data.Questions))
+	for _, q := range data.Questions {
+		byID[q.QuestionID] = q
+	}
+
+	if !d.modified.IsZero() {
+		slog.Info("Reloaded the data", "questions", len(data.Questions))
+	}
+
+	d.modified, d.data, d.byID = info.ModTime(), data, byID
+
+	return nil
+}
+
+// questionFilter is the filters given in the query of a request for
+// /questions. Filters left out match every question.
+type questionFilter struct {
+	certificates *Set[planez.Certificate]
+	types        *Set[planez.QuestionType]
+	ids          *Set[int]
+	text         string
+	hasImage     *bool
+}
+
+// parseQuestionFilter parses the filters in a query: certificate and type,
+// which take comma separated lists as -certificate does; ids, which takes
+// ranges as -ids does; q, for text the question or answer contains; and
+// has_image.
+func parseQuestionFilter(query url.Values) (questionFilter, error) {
+	var filter questionFilter
+	var err error
+	if spec := query.Get("certificate"); spec != "" {
+		if filter.certificates, err = parseCertificates(spec); err != nil {
+			return filter, fmt.Errorf("certificate: %v", err)
+		}
+	}
+
+	if spec := query.Get("type"); spec != "" {
+		if filter.types, err = parseQuestionTypes(spec); err != nil {
+			return filter, fmt.Errorf("type: %v", err)
+		}
+	}
+
+	if spec := query.Get("ids"); spec != "" {
+		ranges, err := parseIDRanges(spec)
+		if err != nil {
+			return filter, fmt.Errorf("ids: %v", err)
+		}
+
+		filter.ids = NewSet[int]()
+		for _, id := range ranges.IDs() {
+			filter.ids.Add(id)
+		}
+	}
+
+	if spec := query.Get("has_image"); spec != "" {
+		hasImage, err := strconv.ParseBool(spec)
+		if err != nil {
+			return filter, fmt.Errorf("has_image: expected true or false, got %q", spec)
+		}
+
+		filter.hasImage = &hasImage
+	}
+
+	filter.text = strings.ToLower(query.Get("q"))
+
+	return filter, nil
+}
+
+// Match reports whether q passes every filter.
+func (f questionFilter) Match(q Question) bool {
+	switch {
+	case f.certificates != nil && !f.certificates.Contains(q.Certificate):
+		return false
+	case f.types != nil && !f.types.Contains(q.Type):
+		return false
+	case f.ids != nil && !f.ids.Contains(q.QuestionID):
+		return false
+	case f.hasImage != nil && *f.hasImage != (q.ImageFile != nil):
+		return false
+	case f.text != "" && !strings.Contains(strings.ToLower(stripHTML(q.Question)+"\n"+stripHTML(q.Answer)), f.text):
+		return false
+	}
+
+	return true
+}
+
+// questionPage is the response to /questions: the questions matching the
+// filters, starting at offset, and how many match in all.
+type questionPage struct {
+	Total     int        `json:"total"`
+	Offset    int        `json:"offset"`
+	Questions []Question `json:"questions"`
+}
+
+// serveJSON writes value as the JSON body of a response with status.
+func serveJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		slog.Debug("Failed to write response", "error", err)
+	}
+}
+
+// serveError writes an error as a JSON body, so that clients of the API
+// don't have to handle plain text responses too.
+func serveError(w http.ResponseWriter, status int, format string, args ...any) {
+	serveJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// newServeMux returns the handler for serve: the questions as JSON under
+// /questions, and the images under /images by the names questions reference
+// them by.
+func newServeMux(served *servedData) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /questions", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := parseQuestionFilter(query)
+		if err != nil {
+			serveError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+
+		limit, offset := maxServeLimit, 0
+		if spec := query.Get("limit"); spec != "" {
+			if limit, err = strconv.Atoi(spec); err != nil || limit < 1 || limit > maxServeLimit {
+				serveError(w, http.StatusBadRequest, "limit: expected a number from 1 to %d, got %q", maxServeLimit, spec)
+				return
+			}
+		}
+
+		if spec := query.Get("offset"); spec != "" {
+			if offset, err = strconv.Atoi(spec); err != nil || offset < 0 {
+				serveError(w, http.StatusBadRequest, "offset: expected a number of at least 0, got %q", spec)
+				return
+			}
+		}
+
+		data, _ := served.Current()
+		page := questionPage{Offset: offset, Questions: []Question{}}
+		for _, q := range data.Questions {
+			if !filter.Match(q) {
+				continue
+			}
+
+			if page.Total >= offset && len(page.Questions) < limit {
+				page.Questions = append(page.Questions, q)
+			}
+
+			page.Total++
+		}
+
+		serveJSON(w, http.StatusOK, page)
+	})
+
+	mux.HandleFunc("GET /questions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			serveError(w, http.StatusBadRequest, "invalid question ID %q", r.PathValue("id"))
+			return
+		}
+
+		_, byID := served.Current()
+		q, ok := byID[id]
+		if !ok {
+			serveError(w, http.StatusNotFound, "no question %d", id)
+			return
+		}
+
+		serveJSON(w, http.StatusOK, q)
+	})
+
+	// Images can be stored under names of their own, such as the hash of
+	// their contents, so the manifest maps the names questions use to the
+	// files.
+	mux.HandleFunc("GET /images/{name...}", func(w http.ResponseWriter, r *http.Request) {
+		data, _ := served.Current()
+		name := r.PathValue("name")
+		if stored, ok := data.Images[name]; ok {
+			name = strings.TrimPrefix(stored, "images/")
+		}
+
+		images := os.DirFS(filepath.Join(served.dir, "images"))
+		if info, err := fs.Stat(images, name); err != nil || !info.Mode().IsRegular() {
+			serveError(w, http.StatusNotFound, "no image %s", r.PathValue("name"))
+			return
+		}
+
+		http.ServeFileFS(w, r, images, name)
+	})
+
+	return mux
+}
+
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := flags.String("data", activeWorkspace.DataDir(), "Directory containing scraped data")
+	addr := flags.String("addr", "127.0.0.1:8090", "Address to listen on")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.CheckFile("-data", filepath.Join(*dir, "questions.json"))
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	served := &servedData{dir: *dir}
+	if err := served.reload(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Serving scraped questions", "questions", len(served.data.Questions), "data_dir", *dir, "url", "http://"+listener.Addr().String()+"/questions")
+
+	return http.Serve(listener, newServeMux(served))
+}
@@ -0,0 +1,53 @@
+package main
+
+import "sync"
+
+type Set[T comparable] struct {
+	data map[T]struct{}
+	mu   sync.RWMutex
+}
+
+func NewSet[T comparable]() *Set[T] {
+	return &Set[T]{data: make(map[T]struct{})}
+}
+
+func (s *Set[T]) Add(value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[value] = struct{}{}
+}
+
+func (s *Set[T]) Remove(value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data, value)
+}
+
+func (s *Set[T]) Contains(value T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.data[value]
+	return ok
+}
+
+func (s *Set[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.data)
+}
+
+func (s *Set[T]) Values() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make([]T, 0, len(s.data))
+	for key := range s.data {
+		values = append(values, key)
+	}
+
+	return values
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// readStars returns the IDs of the starred questions, which are kept in
+// their own file so that the scraped data is never modified.
+func readStars(path string) (*Set[int], error) {
+	stars := NewSet[int]()
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stars, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var ids []int
+	if err := json.Unmarshal(contents, &ids); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	for _, id := range ids {
+		stars.Add(id)
+	}
+
+	return stars, nil
+}
+
+func writeStars(path string, stars *Set[int]) error {
+	ids := stars.Values()
+	slices.Sort(ids)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	return writeJSONFile(path, ids)
+}
+
+// updateStars parses the question IDs given to star or unstar and applies
+// change to the starred set for each one.
+func updateStars(name string, args []string, change func(stars *Set[int], id int)) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: planez-scraper %s ID...\n", name)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() > 0, "expected at least one question ID")
+	var ids []int
+	for _, arg := range flags.Args() {
+		id, err := parseQuestionID(arg)
+		v.CheckErr("ID", err)
+		ids = append(ids, id)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	path := activeWorkspace.StarsPath()
+	stars, err := readStars(path)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		change(stars, id)
+	}
+
+	return writeStars(path, stars)
+}
+
+func runStar(args []string) error {
+	if len(args) == 0 {
+		return listStars()
+	}
+
+	return updateStars("star", args, func(stars *Set[int], id int) { stars.Add(id) })
+}
+
+func runUnstar(args []string) error {
+	return updateStars("unstar", args, func(stars *Set[int], id int) { stars.Remove(id) })
+}
+
+// listStars prints the IDs of the starred questions, one per line.
+func listStars() error {
+	stars, err := readStars(activeWorkspace.StarsPath())
+	if err != nil {
+		return err
+	}
+
+	ids := stars.Values()
+	slices.Sort(ids)
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+
+	return nil
+}
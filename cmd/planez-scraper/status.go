@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// phaseProgress counts the items of one kind handled so far.
+type phaseProgress struct {
+	total   int
+	done    int
+	failed  int
+	skipped int
+
+	// statuses counts the failures by failureStatus.
+	statuses map[string]int
+}
+
+// runProgress tracks a scrape as it runs, so that its status can be printed
+// on request without interrupting it. It is safe for concurrent use by the
+// workers.
+type runProgress struct {
+	mu       sync.Mutex
+	start    time.Time
+	phases   map[string]*phaseProgress
+	order    []string
+	inFlight map[string]time.Time
+}
+
+func newRunProgress() *runProgress {
+	return &runProgress{
+		start:    time.Now(),
+		phases:   make(map[string]*phaseProgress),
+		inFlight: make(map[string]time.Time),
+	}
+}
+
+func (p *runProgress) phase(kind string) *phaseProgress {
+	phase, ok := p.phases[kind]
+	if !ok {
+		phase = &phaseProgress{statuses: make(map[string]int)}
+		p.phases[kind] = phase
+		p.order = append(p.order, kind)
+	}
+
+	return phase
+}
+
+// Expect records how many items of a kind are going to be fetched.
+func (p *runProgress) Expect(kind string, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.phase(kind).total += total
+}
+
+// Begin records that a worker has started fetching an item, such as
+// "question 1000".
+func (p *runProgress) Begin(item string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.inFlight[item] = time.Now()
+}
+
+// Finish records the outcome of fetching an item of a kind.
+func (p *runProgress) Finish(kind string, item string, class errorClass, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.inFlight, item)
+
+	// Fetches cut off by an interrupt or -max-duration haven't been handled,
+	// and are fetched again when the run is resumed. Some are cut off with
+	// the cause of the cancellation instead of context.Canceled.
+	if errors.Is(err, context.Canceled) || errors.Is(err, errInterrupted) {
+		return
+	}
+
+	phase := p.phase(kind)
+	switch {
+	case err == nil:
+		phase.done++
+	case class == classSkip:
+		phase.skipped++
+	default:
+		phase.failed++
+		phase.statuses[failureStatus(err)]++
+	}
+}
+
+// Failures returns the number of failures of each kind of item by
+// failureStatus, leaving out kinds without any.
+func (p *runProgress) Failures() map[string]map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failures := make(map[string]map[string]int)
+	for kind, phase := range p.phases {
+		if len(phase.statuses) > 0 {
+			failures[kind] = maps.Clone(phase.statuses)
+		}
+	}
+
+	return failures
+}
+
+// WriteStatus writes the counts for each kind of item and what the workers
+// are fetching right now, longest running first.
+func (p *runProgress) WriteStatus(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	fmt.Fprintf(w, "\nStatus after %s\n", now.Sub(p.start).Round(time.Second))
+
+	for _, kind := range p.order {
+		phase := p.phases[kind]
+		handled := phase.done + phase.failed + phase.skipped
+		fmt.Fprintf(w, "  %-10s %d/%d handled, %d failed, %d skipped\n", kind, handled, phase.total, phase.failed, phase.skipped)
+	}
+
+	items := make([]string, 0, len(p.inFlight))
+	for item := range p.inFlight {
+		items = append(items, item)
+	}
+
+	slices.SortFunc(items, func(a, b string) int { return p.inFlight[a].Compare(p.inFlight[b]) })
+
+	fmt.Fprintf(w, "  %d in flight\n", len(items))
+	for _, item := range items {
+		fmt.Fprintf(w, "    %-20s %s\n", item, now.Sub(p.inFlight[item]).Round(time.Millisecond))
+	}
+
+	fmt.Fprintln(w)
+}
+
+// progressBarWidth is the number of cells in the bar drawn by Line.
+const progressBarWidth = 20
+
+// Line summarizes the run on one line short enough for any terminal: a bar
+// of the items handled out of those expected so far, the counts for each
+// kind of item, the rate they're being handled at, and an estimate of the
+// time left for the rest.
+func (p *runProgress) Line() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var handled, total int
+	var counts []string
+	for _, kind := range p.order {
+		phase := p.phases[kind]
+		done := phase.done + phase.failed + phase.skipped
+		handled += done
+		total += phase.total
+
+		count := fmt.Sprintf("%d/%d %s", done, phase.total, kind)
+		if phase.failed > 0 {
+			count += fmt.Sprintf(" (%d failed)", phase.failed)
+		}
+
+		counts = append(counts, count)
+	}
+
+	filled := 0
+	if total > 0 {
+		filled = min(handled*progressBarWidth/total, progressBarWidth)
+	}
+
+	bar := "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+
+	elapsed := time.Since(p.start)
+	rate := float64(handled) / elapsed.Seconds()
+	eta := "ETA --"
+	if rate > 0 {
+		left := time.Duration(float64(total-handled) / rate * float64(time.Second))
+		eta = "ETA " + left.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%s %s, %.1f/s, %s", bar, strings.Join(counts, ", "), rate, eta)
+}
+
+// watchControls lets a person control a run while it's going. The status
+// of the run is written to w when the process receives one of statusSignals,
+// and the gate is paused or resumed on one of pauseSignals. When stdin is a
+// terminal, pressing Enter writes the status too, and entering "p" pauses or
+// resumes. The returned function stops watching.
+func watchControls(p *runProgress, gate *pauseGate, w io.Writer) func() {
+	lines := make(chan string)
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				lines <- strings.TrimSpace(scanner.Text())
+			}
+		}()
+	}
+
+	statusRequests := make(chan os.Signal, 1)
+	if len(statusSignals) > 0 {
+		signal.Notify(statusRequests, statusSignals...)
+	}
+
+	pauseRequests := make(chan os.Signal, 1)
+	if len(pauseSignals) > 0 {
+		signal.Notify(pauseRequests, pauseSignals...)
+	}
+
+	togglePause := func() {
+		if gate.Toggle() {
+			slog.Info("Paused, requests in flight will finish but no new ones will start")
+		} else {
+			slog.Info("Resumed")
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case line := <-lines:
+				if line == "p" {
+					togglePause()
+				} else {
+					p.WriteStatus(w)
+				}
+			case <-statusRequests:
+				p.WriteStatus(w)
+			case <-pauseRequests:
+				togglePause()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(statusRequests)
+		signal.Stop(pauseRequests)
+		close(done)
+	}
+}
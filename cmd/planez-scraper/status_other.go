@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// statusSignals and pauseSignals are empty where SIGUSR1 and SIGUSR2 don't
+// exist, leaving the keyboard as the only way to control a run.
+var (
+	statusSignals []os.Signal
+	pauseSignals  []os.Signal
+)
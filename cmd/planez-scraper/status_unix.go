@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statusSignals ask a running scrape to print its status, and pauseSignals
+// pause or resume it.
+var (
+	statusSignals = []os.Signal{syscall.SIGUSR1}
+	pauseSignals  = []os.Signal{syscall.SIGUSR2}
+)
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// machineSummary is the JSON summary of a run written by -summary-file and
+// -summary-fd, for automation wrapping the scraper.
+type machineSummary struct {
+	Result    string    `json:"result"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  float64   `json:"durationSeconds"`
+	BaseURL   string    `json:"baseUrl"`
+	DataDir   string    `json:"dataDir"`
+	Profile   string    `json:"profile,omitempty"`
+
+	Questions struct {
+		Scraped int `json:"scraped"`
+		Failed  int `json:"failed"`
+		Skipped int `json:"skipped"`
+		Kept    int `json:"kept"`
+	} `json:"questions"`
+
+	Images struct {
+		Downloaded int `json:"downloaded"`
+		Failed     int `json:"failed"`
+	} `json:"images"`
+
+	Latency struct {
+		P50 float64 `json:"p50Ms"`
+		P95 float64 `json:"p95Ms"`
+		Max float64 `json:"maxMs"`
+	} `json:"latency"`
+
+	Errors    []string `json:"errors"`
+	Anomalies []string `json:"anomalies"`
+
+	// NewValues lists the certificates and types seen that aren't among the
+	// known values, such as `certificate "INSTRUMENT"`.
+	NewValues []string `json:"newValues"`
+}
+
+func newMachineSummary(run hookEnv, skipped int, kept int, latency latencyStats, errors []string, anomalies []string, newValues []string) machineSummary {
+	summary := machineSummary{
+		Result:    run.Result,
+		StartedAt: run.StartedAt,
+		Duration:  run.Duration.Seconds(),
+		BaseURL:   run.BaseURL,
+		DataDir:   run.DataDir,
+		Profile:   run.Profile,
+		Errors:    append([]string{}, errors...),
+		Anomalies: append([]string{}, anomalies...),
+		NewValues: append([]string{}, newValues...),
+	}
+
+	summary.Questions.Scraped = run.Scraped
+	summary.Questions.Failed = run.Failed
+	summary.Questions.Skipped = skipped
+	summary.Questions.Kept = kept
+	summary.Images.Downloaded = run.Images
+	summary.Images.Failed = run.ImageFailures
+
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	summary.Latency.P50 = ms(latency.P50)
+	summary.Latency.P95 = ms(latency.P95)
+	summary.Latency.Max = ms(latency.Max)
+
+	return summary
+}
+
+// writeMachineSummary writes the summary to the file at path and to the open
+// file descriptor fd, each of which is skipped if unset.
+func writeMachineSummary(path string, fd int, summary machineSummary) error {
+	contents, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode run summary: %v", err)
+	}
+
+	contents = append(contents, '\n')
+
+	if path != "" {
+		if err := replaceFileContents(path, contents); err != nil {
+			return err
+		}
+	}
+
+	if fd != 0 {
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+		if _, err := file.Write(contents); err != nil {
+			return fmt.Errorf("failed to write to file descriptor %d: %v", fd, err)
+		}
+	}
+
+	return nil
+}
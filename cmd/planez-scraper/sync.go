@@ -0,0 +1,569 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// syncFiles are the files of annotations that sync shares, each kept under
+// the member's name on the remote.
+var syncFiles = []string{"progress.json", "notes.json", "stars.json"}
+
+// syncRemote is somewhere study partners share their annotations. Names are
+// slash separated paths within it, such as "alex/notes.json".
+type syncRemote interface {
+	// Get returns the contents of a file, or an error wrapping
+	// fs.ErrNotExist if there is none.
+	Get(name string) ([]byte, error)
+	Put(name string, contents []byte) error
+
+	// Members lists the names of the top level directories.
+	Members() ([]string, error)
+}
+
+// openRemote returns the remote for a WebDAV URL or a directory, which may be
+// given as a file URL.
+func openRemote(raw string) (syncRemote, error) {
+	u, err := url.Parse(raw)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+
+		return &webdavRemote{base: u, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	} else if err == nil && u.Scheme == "file" {
+		raw = u.Path
+	} else if err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return nil, fmt.Errorf("unsupported remote %q, expected a directory or a WebDAV URL", raw)
+	}
+
+	info, err := os.Stat(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote: %v", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("remote %s is not a directory", raw)
+	}
+
+	return dirRemote{root: raw}, nil
+}
+
+// dirRemote is a directory, such as a shared drive or a git checkout.
+type dirRemote struct {
+	root string
+}
+
+func (r dirRemote) Get(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(r.root, filepath.FromSlash(name)))
+}
+
+func (r dirRemote) Put(name string, contents []byte) error {
+	p := filepath.Join(r.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	return replaceFileContents(p, contents)
+}
+
+func (r dirRemote) Members() ([]string, error) {
+	entries, err := os.ReadDir(r.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	for _, entry := range entries {
+		if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) {
+			members = append(members, entry.Name())
+		}
+	}
+
+	return members, nil
+}
+
+// gitRoot returns the top of the git checkout the directory is in, or an
+// empty string if it isn't in one.
+func (r dirRemote) gitRoot() string {
+	out, err := exec.Command("git", "-C", r.root, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// git runs a git command in the remote's checkout.
+func (r dirRemote) git(args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", r.root}, args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+
+	return nil
+}
+
+// webdavRemote is a WebDAV collection, such as a Nextcloud folder.
+type webdavRemote struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func (r *webdavRemote) do(method string, name string, body []byte, header http.Header) (*http.Response, error) {
+	// JoinPath drops the trailing slash that marks a collection, so the
+	// base itself is used as it is.
+	u := r.base
+	if name != "" {
+		u = r.base.JoinPath(name)
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if user := r.base.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	return r.client.Do(req)
+}
+
+func (r *webdavRemote) Get(name string) ([]byte, error) {
+	res, err := r.do(http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to get %s: received status %d", name, res.StatusCode)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+func (r *webdavRemote) Put(name string, contents []byte) error {
+	if dir := path.Dir(name); dir != "." {
+		res, err := r.do("MKCOL", dir+"/", nil, nil)
+		if err != nil {
+			return err
+		}
+
+		res.Body.Close()
+
+		// 405 means the collection already exists.
+		if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create %s: received status %d", dir, res.StatusCode)
+		}
+	}
+
+	res, err := r.do(http.MethodPut, name, contents, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("failed to put %s: received status %d", name, res.StatusCode)
+	}
+
+	return nil
+}
+
+// webdavMultistatus is the part of a PROPFIND response that lists the
+// collection's members.
+type webdavMultistatus struct {
+	Responses []struct {
+		Href       string    `xml:"href"`
+		Collection *struct{} `xml:"propstat>prop>resourcetype>collection"`
+	} `xml:"response"`
+}
+
+func (r *webdavRemote) Members() ([]string, error) {
+	body := []byte(`<?xml version="1.0"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`)
+	res, err := r.do("PROPFIND", "", body, http.Header{"Depth": {"1"}, "Content-Type": {"application/xml"}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to list %s: received status %d", r.base.Redacted(), res.StatusCode)
+	}
+
+	var listing webdavMultistatus
+	if err := xml.NewDecoder(res.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("failed to decode listing of %s: %v", r.base.Redacted(), err)
+	}
+
+	var members []string
+	for _, response := range listing.Responses {
+		href, err := url.PathUnescape(response.Href)
+		if err != nil || response.Collection == nil {
+			continue
+		}
+
+		name := path.Base(strings.TrimSuffix(href, "/"))
+		if strings.TrimSuffix(href, "/") != strings.TrimSuffix(r.base.Path, "/") && profileNamePattern.MatchString(name) {
+			members = append(members, name)
+		}
+	}
+
+	return members, nil
+}
+
+// encodeJSON encodes v the same way as writeJSONFile.
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeSets combines the local and remote copies of a set of annotations,
+// using base, the set both had after the last sync, to tell additions from
+// removals. Anything added on either side is kept and anything removed on
+// either side is dropped. The local value wins where both sides have a key.
+func mergeSets[K comparable, V any](base, local, remote map[K]V) map[K]V {
+	merged := make(map[K]V)
+	for k, v := range remote {
+		_, inBase := base[k]
+		if _, inLocal := local[k]; inLocal || !inBase {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range local {
+		_, inBase := base[k]
+		if _, inRemote := remote[k]; inRemote || !inBase {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// noteKey identifies a note for merging. The same text on the same question
+// is the same note, whenever it was added.
+type noteKey struct {
+	QuestionID int
+	Text       string
+}
+
+func notesByKey(notes questionNotes) map[noteKey]questionNote {
+	keyed := make(map[noteKey]questionNote)
+	for id, list := range notes {
+		for _, note := range list {
+			keyed[noteKey{id, note.Text}] = note
+		}
+	}
+
+	return keyed
+}
+
+// mergeAnnotation combines the local and remote copies of one of syncFiles
+// with the copy from the last sync, returning the merged contents. Reviews
+// are only ever added, so every review from either side is kept. Notes and
+// stars are merged with mergeSets, so removing one on any machine removes it
+// everywhere.
+func mergeAnnotation(name string, base, local, remote []byte) (any, error) {
+	switch name {
+	case "progress.json":
+		var a, b progressStore
+		if err := errors.Join(decodeAnnotation(local, &a), decodeAnnotation(remote, &b)); err != nil {
+			return nil, err
+		}
+
+		a.Add(b.Reviews)
+		if a.Reviews == nil {
+			a.Reviews = []review{}
+		}
+
+		return a, nil
+	case "notes.json":
+		o, a, b := questionNotes{}, questionNotes{}, questionNotes{}
+		if err := errors.Join(decodeAnnotation(base, &o), decodeAnnotation(local, &a), decodeAnnotation(remote, &b)); err != nil {
+			return nil, err
+		}
+
+		merged := questionNotes{}
+		for key, note := range mergeSets(notesByKey(o), notesByKey(a), notesByKey(b)) {
+			merged[key.QuestionID] = append(merged[key.QuestionID], note)
+		}
+
+		for id := range merged {
+			slices.SortFunc(merged[id], func(x, y questionNote) int {
+				if c := x.Added.Compare(y.Added); c != 0 {
+					return c
+				}
+
+				return strings.Compare(x.Text, y.Text)
+			})
+		}
+
+		return merged, nil
+	case "stars.json":
+		var o, a, b []int
+		if err := errors.Join(decodeAnnotation(base, &o), decodeAnnotation(local, &a), decodeAnnotation(remote, &b)); err != nil {
+			return nil, err
+		}
+
+		byID := func(ids []int) map[int]bool {
+			set := make(map[int]bool)
+			for _, id := range ids {
+				set[id] = true
+			}
+
+			return set
+		}
+
+		stars := slices.Sorted(maps.Keys(mergeSets(byID(o), byID(a), byID(b))))
+		if stars == nil {
+			stars = []int{}
+		}
+
+		return stars, nil
+	}
+
+	return nil, fmt.Errorf("unknown file %s", name)
+}
+
+// decodeAnnotation decodes the contents of one of syncFiles into v, leaving
+// v as it is if there are none.
+func decodeAnnotation(contents []byte, v any) error {
+	if len(contents) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(contents, v)
+}
+
+// readOptional reads a file that may not exist yet, treating a missing one
+// as empty.
+func readOptional(read func() ([]byte, error)) ([]byte, error) {
+	contents, err := read()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	return contents, err
+}
+
+func defaultMember() string {
+	if u, err := user.Current(); err == nil && profileNamePattern.MatchString(u.Username) {
+		return u.Username
+	}
+
+	return ""
+}
+
+func runSync(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	remoteSpec := flags.String("remote", "", "Directory or WebDAV URL shared with the study group")
+	member := flags.String("member", defaultMember(), "Name to share your progress, notes, and stars under")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.Check(*remoteSpec != "", "-remote: required")
+	v.Check(profileNamePattern.MatchString(*member), "-member: invalid name %q, use letters, digits, '.', '-', and '_'", *member)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	remote, err := openRemote(*remoteSpec)
+	if err != nil {
+		return err
+	}
+
+	// A directory in a git checkout is brought up to date first, and the
+	// merged files are committed and pushed after.
+	dir, isDir := remote.(dirRemote)
+	gitRoot := ""
+	if isDir {
+		gitRoot = dir.gitRoot()
+	}
+
+	if gitRoot != "" {
+		if err := dir.git("pull", "--rebase", "--autostash"); err != nil {
+			return err
+		}
+	}
+
+	annotationPaths := map[string]string{
+		"progress.json": activeWorkspace.ProgressPath(),
+		"notes.json":    activeWorkspace.NotesPath(),
+		"stars.json":    activeWorkspace.StarsPath(),
+	}
+
+	syncedDir := filepath.Join(activeWorkspace.SyncedDir(), *member)
+	for _, dir := range []string{activeWorkspace.Dir, syncedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	for _, name := range syncFiles {
+		localPath := annotationPaths[name]
+		remoteName := *member + "/" + name
+		basePath := filepath.Join(syncedDir, name)
+
+		base, err := readOptional(func() ([]byte, error) { return os.ReadFile(basePath) })
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", basePath, err)
+		}
+
+		local, err := readOptional(func() ([]byte, error) { return os.ReadFile(localPath) })
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", localPath, err)
+		}
+
+		theirs, err := readOptional(func() ([]byte, error) { return remote.Get(remoteName) })
+		if err != nil {
+			return fmt.Errorf("failed to get %s from the remote: %v", remoteName, err)
+		}
+
+		merged, err := mergeAnnotation(name, base, local, theirs)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %v", name, err)
+		}
+
+		contents, err := encodeJSON(merged)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", name, err)
+		}
+
+		if err := replaceFileContents(localPath, contents); err != nil {
+			return err
+		}
+
+		if err := remote.Put(remoteName, contents); err != nil {
+			return fmt.Errorf("failed to put %s on the remote: %v", remoteName, err)
+		}
+
+		if err := replaceFileContents(basePath, contents); err != nil {
+			return err
+		}
+	}
+
+	members, err := remote.Members()
+	if err != nil {
+		return fmt.Errorf("failed to list the study group: %v", err)
+	}
+
+	// Everyone else's files are copied down as they are, for exports and
+	// reports to read. Partners who have left the group are cleared out.
+	groupDir := activeWorkspace.GroupDir()
+	if err := os.RemoveAll(groupDir); err != nil {
+		return fmt.Errorf("failed to clear %s: %v", groupDir, err)
+	}
+
+	partners := 0
+	for _, partner := range members {
+		if partner == *member {
+			continue
+		}
+
+		partners++
+		partnerDir := filepath.Join(groupDir, partner)
+		if err := os.MkdirAll(partnerDir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %v", partnerDir, err)
+		}
+
+		for _, name := range syncFiles {
+			contents, err := readOptional(func() ([]byte, error) { return remote.Get(partner + "/" + name) })
+			if err != nil {
+				return fmt.Errorf("failed to get %s/%s from the remote: %v", partner, name, err)
+			} else if contents == nil {
+				continue
+			}
+
+			if err := replaceFileContents(filepath.Join(partnerDir, name), contents); err != nil {
+				return err
+			}
+		}
+	}
+
+	if gitRoot != "" {
+		if err := dir.git("add", "--", *member); err != nil {
+			return err
+		}
+
+		// Committing fails when nothing changed, which isn't a problem.
+		if exec.Command("git", "-C", dir.root, "diff", "--cached", "--quiet").Run() != nil {
+			if err := dir.git("commit", "-m", "Sync "+*member); err != nil {
+				return err
+			}
+
+			if err := dir.git("push"); err != nil {
+				return err
+			}
+		}
+	}
+
+	slog.Info("Synced", "member", *member, "partners", partners)
+
+	return nil
+}
+
+// readGroupNotes reads the notes each study partner shared, keyed by their
+// name, from the files sync copied down.
+func readGroupNotes(dir string) (map[string]questionNotes, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+
+	group := make(map[string]questionNotes)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		notes, err := readNotes(filepath.Join(dir, entry.Name(), "notes.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		group[entry.Name()] = notes
+	}
+
+	return group, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncDirRemote(t *testing.T) {
+	remote := t.TempDir()
+
+	defer func(w workspace) { activeWorkspace = w }(activeWorkspace)
+
+	sam := workspace{Dir: t.TempDir()}
+	writeTestFiles(t, sam.Dir, map[string]string{"stars.json": "[1000, 1002]"})
+	activeWorkspace = sam
+	if err := runSync([]string{"-remote", remote, "-member", "sam"}); err != nil {
+		t.Fatalf("sync as sam error = %v", err)
+	}
+
+	// Alex hasn't used the tool yet, so their workspace doesn't exist.
+	alex := workspace{Dir: filepath.Join(t.TempDir(), "planez-scraper")}
+	activeWorkspace = alex
+	if err := runSync([]string{"-remote", remote, "-member", "alex"}); err != nil {
+		t.Fatalf("sync as alex error = %v", err)
+	}
+
+	stars, err := readStars(filepath.Join(alex.GroupDir(), "sam", "stars.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stars.Len() != 2 || !stars.Contains(1000) || !stars.Contains(1002) {
+		t.Errorf("alex's copy of sam's stars = %v, want 1000 and 1002", stars.Values())
+	}
+
+	for _, dir := range []string{remote, sam.Dir, alex.Dir} {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && strings.HasSuffix(p, ".tmp") {
+				t.Errorf("sync left %s behind", p)
+			}
+
+			return nil
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// largeScrape is the number of question requests above which a run needs
+// the site's terms to have been acknowledged.
+const largeScrape = 50
+
+const termsNotice = `Before scraping %s:
+
+  - The questions and images belong to the site and their authors. Review the
+    site's terms of use before downloading or sharing them.
+  - Keep what you download for your own study. Use "export -attribution" and
+    "export -strip" if you pass an export on to anyone.
+  - This run makes about %d question requests, plus one per image. Requests
+    are paced to %s.
+  - Don't raise -rate or run several copies at once without the site's
+    permission.
+
+`
+
+// confirmTerms asks the user to acknowledge the usage constraints of the
+// site at baseURL before a large scrape. Each site only has to be
+// acknowledged once. Local servers, such as the fake server, are exempt.
+func confirmTerms(baseURL string, requests int, rate float64, assumeYes bool) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+
+	if assumeYes || requests < largeScrape || isLoopbackHost(u.Hostname()) {
+		return nil
+	}
+
+	path, err := termsAcceptedPath()
+	if err != nil {
+		return err
+	}
+
+	accepted, err := readAcceptedHosts(path)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(accepted, u.Host) {
+		return nil
+	}
+
+	pace := "no limit"
+	if rate > 0 {
+		pace = fmt.Sprintf("%g per second", rate)
+	}
+
+	fmt.Fprintf(os.Stderr, termsNotice, u.Host, requests, pace)
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return fmt.Errorf("the terms for %s have not been acknowledged, run interactively once or pass -yes", u.Host)
+	}
+
+	fmt.Fprint(os.Stderr, `Type "yes" to continue: `)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+		return fmt.Errorf("the terms for %s were not acknowledged", u.Host)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = replaceFileContents(path, []byte(strings.Join(append(accepted, u.Host), "\n")+"\n"))
+	}
+	if err != nil {
+		slog.Warn("Failed to record acknowledgment", "path", path, "error", err)
+	}
+
+	return nil
+}
+
+func termsAcceptedPath() (string, error) {
+	dir, err := configHome()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "terms-accepted"), nil
+}
+
+// readAcceptedHosts returns the hosts whose terms have been acknowledged,
+// one per line in the file at path.
+func readAcceptedHosts(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return strings.Fields(string(contents)), nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// defaultTorProxy is where the tor daemon takes SOCKS connections. Tor
+// Browser takes them on 127.0.0.1:9150 instead.
+const defaultTorProxy = "127.0.0.1:9050"
+
+// Requests through Tor go over a circuit of relays, which takes a while to
+// build and is slower than a direct connection, so -tor relaxes the timeouts
+// that aren't set explicitly.
+const (
+	torConnectTimeout = time.Minute
+	torRequestTimeout = 2 * time.Minute
+)
+
+// torProxyURL returns the URL of the Tor SOCKS proxy at addr. Requests
+// through a SOCKS5 proxy send it the site's hostname, so that Tor resolves
+// it and the lookup doesn't leave the home network either.
+func torProxyURL(addr string) *url.URL {
+	return &url.URL{Scheme: "socks5", Host: addr}
+}
+
+// checkTorProxy connects to the Tor SOCKS proxy at addr, so that a run with
+// Tor not running stops straight away instead of failing every request.
+func checkTorProxy(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the Tor SOCKS proxy at %s, is Tor running? %v", addr, err)
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cdriehuys/planez-scraper/v2/pkg/planez"
+)
+
+// middleware wraps a transport in one that handles a single concern, such
+// as pacing or retrying requests, before passing them on.
+type middleware func(next http.RoundTripper) http.RoundTripper
+
+// chain wraps base in layers, the first of which is outermost: it sees each
+// request first and each response last.
+//
+// A scrape stacks its layers in this order, each of which can be left out:
+//
+//   - withRetries, so that each attempt passes through every layer below.
+//   - withPause, so that a paused run holds back retries too.
+//   - withLogging, to log each attempt as it's made.
+//   - withMetrics, to count each attempt and what it read.
+//   - withAdaptiveConcurrency, so that injected failures back it off like
+//     the site's own.
+//   - withFaults, so that injected failures are logged, counted, and retried
+//     like the site's own, without costing a turn under the rate limit.
+//   - withHostSlots, so that an attempt waiting for a slot hasn't taken a
+//     turn under the rate limit.
+//   - withRateLimit, so that each attempt that reaches the site waits its
+//     turn, behind those with a higher priority.
+//   - withTimeout, so that an attempt's time limit starts once it's allowed
+//     to be made.
+//   - withCache, innermost, so that a response answered from the cache has
+//     still been asked for and paced like any other.
+func chain(base http.RoundTripper, layers ...middleware) http.RoundTripper {
+	for _, layer := range slices.Backward(layers) {
+		base = layer(base)
+	}
+
+	return base
+}
+
+// withRetries retries requests that fail, as classified by rules, according
+// to policy.
+func withRetries(rules statusRules, policy retryPolicy) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &retryingTransport{next: next, rules: rules, policy: policy}
+	}
+}
+
+// withPause holds requests back while gate is paused.
+func withPause(gate *pauseGate) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &pausedTransport{next: next, gate: gate}
+	}
+}
+
+// withLogging logs each request at debug level.
+func withLogging() middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &loggingTransport{next: next}
+	}
+}
+
+// withMetrics counts requests in m.
+func withMetrics(m *requestMetrics) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &metricsTransport{next: next, metrics: m}
+	}
+}
+
+// withFaults fails a seeded, random selection of requests according to
+// rules.
+func withFaults(rules []faultRule, seed int64) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return newFaultTransport(next, rules, seed)
+	}
+}
+
+// withAdaptiveConcurrency lets up to n requests to each host be in flight at
+// once, starting from one and backing off when the host is overloaded.
+func withAdaptiveConcurrency(n int) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return newAdaptiveTransport(next, n)
+	}
+}
+
+// withHostSlots lets up to n requests to each host be in flight at once.
+func withHostSlots(n int) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &hostSlotsTransport{next: next, n: n}
+	}
+}
+
+// withRateLimit paces requests to each host to rate per second, letting up
+// to burst go back to back.
+func withRateLimit(rate float64, burst int) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return newLimitedTransport(next, rate, burst)
+	}
+}
+
+// withTimeout gives up on each request that takes longer than timeout,
+// including the time to read its body.
+func withTimeout(timeout time.Duration) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &timeoutTransport{next: next, timeout: timeout}
+	}
+}
+
+// withCache answers unchanged responses from cache.
+func withCache(cache *responseCache) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &cachingTransport{next: next, cache: cache}
+	}
+}
+
+// Unless flags say otherwise, connections and requests give up after these
+// timeouts.
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// newBaseTransport returns the transport at the bottom of a chain, which
+// makes the requests to the site. It gives up on connecting, including the
+// TLS handshake, after connectTimeout, so that an unreachable host fails
+// the request instead of stalling it.
+func newBaseTransport(connectTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	return transport
+}
+
+// newSiteClient returns a client for the commands other than the scrape that
+// fetch questions from the site. Its requests go through the same layers as
+// a scrape's with the scrape's default flags: they are retried, logged with
+// -debug, paced to rate per second (0 for no limit), and given up on after
+// the default timeouts.
+func newSiteClient(baseURL string, rate float64) *planez.Client {
+	layers := []middleware{withRetries(statusRules{}, retryPolicy{maxAttempts: 3, delay: time.Second})}
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		layers = append(layers, withLogging())
+	}
+
+	if rate > 0 {
+		layers = append(layers, withRateLimit(rate, 1))
+	}
+
+	layers = append(layers, withTimeout(defaultRequestTimeout))
+
+	return planez.NewClient(baseURL, &http.Client{Transport: chain(newBaseTransport(defaultConnectTimeout), layers...)})
+}
+
+// retryingTransport makes a request again when it fails with no response,
+// or with a status classified as retry, until it has been attempted as many
+// times as the policy allows. The last failure is returned as is. Attempts
+// are logged to the logger carried by the request's context.
+type retryingTransport struct {
+	next   http.RoundTripper
+	rules  statusRules
+	policy retryPolicy
+}
+
+func (t *retryingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	logger := loggerFrom(ctx)
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		res, err := t.next.RoundTrip(req)
+
+		// A failure either has a response with a status, or an error.
+		reason := slog.Any("error", err)
+		if err == nil {
+			if res.StatusCode < 300 || t.rules.classifyStatus(res.StatusCode) != classRetry {
+				return res, nil
+			}
+
+			reason = slog.Int("status", res.StatusCode)
+		}
+
+		if ctx.Err() != nil || attempt >= t.policy.maxAttempts || (req.Body != nil && req.GetBody == nil) {
+			logger.Debug("Giving up on failed request", "attempt", attempt, reason, "duration", time.Since(start))
+			return res, err
+		}
+
+		if res != nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, maxDrain))
+			res.Body.Close()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		// Retries wait behind first attempts under the rate limit, unless
+		// the request already goes after them.
+		if priorityFrom(ctx) < priorityRetry {
+			req = req.WithContext(withPriority(ctx, priorityRetry))
+		}
+
+		delay := t.policy.backoff(attempt)
+		logger.Info("Retrying failed request", "attempt", attempt, reason, "duration", time.Since(start), "delay", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// timeoutTransport cancels each request that hasn't been answered and read
+// within its timeout. The time limit applies to each attempt on its own,
+// rather than to a request and all of its retries.
+type timeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	res, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, t.timedOut(req.Context(), ctx, err)
+	}
+
+	res.Body = &timeoutBody{ReadCloser: res.Body, transport: t, parent: req.Context(), ctx: ctx, cancel: cancel}
+	return res, nil
+}
+
+// timedOut replaces err with one that says the request timed out, if it
+// failed because ctx, derived from parent, ran out of time.
+func (t *timeoutTransport) timedOut(parent, ctx context.Context, err error) error {
+	if parent.Err() == nil && ctx.Err() == context.DeadlineExceeded {
+		return &timeoutError{timeout: t.timeout}
+	}
+
+	return err
+}
+
+// timeoutError is the error of a request that timed out. It is a net.Error,
+// like the timeouts of the transports below it.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// timeoutBody releases the timeout of the request it answers once it's
+// closed.
+type timeoutBody struct {
+	io.ReadCloser
+	transport *timeoutTransport
+	parent    context.Context
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.transport.timedOut(b.parent, b.ctx, err)
+	}
+
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// loggingTransport logs each request and its outcome at debug level, to the
+// logger carried by the request's context.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+
+	logger := loggerFrom(req.Context())
+	if err != nil {
+		logger.Debug("Request failed", "method", req.Method, "url", req.URL.String(), "duration", time.Since(start), "error", err)
+	} else {
+		logger.Debug("Request", "method", req.Method, "url", req.URL.String(), "status", res.StatusCode, "duration", time.Since(start))
+	}
+
+	return res, err
+}
+
+// requestMetrics totals the requests made through a metricsTransport. It is
+// safe for concurrent use.
+type requestMetrics struct {
+	requests atomic.Int64
+	bytes    atomic.Int64
+
+	mu       sync.Mutex
+	statuses map[int]int
+}
+
+// Requests returns the number of requests made.
+func (m *requestMetrics) Requests() int {
+	return int(m.requests.Load())
+}
+
+// Bytes returns the number of bytes read from response bodies.
+func (m *requestMetrics) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+// Statuses returns the number of responses with each status. Requests that
+// failed without a response aren't counted.
+func (m *requestMetrics) Statuses() map[int]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return maps.Clone(m.statuses)
+}
+
+// metricsTransport counts the requests made through it and the bytes read
+// from their responses.
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *requestMetrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m := t.metrics
+	m.requests.Add(1)
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.statuses == nil {
+		m.statuses = make(map[int]int)
+	}
+	m.statuses[res.StatusCode]++
+	m.mu.Unlock()
+
+	res.Body = &countingReader{ReadCloser: res.Body, n: &m.bytes}
+	return res, nil
+}
+
+// countingReader adds the bytes read through it to n.
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
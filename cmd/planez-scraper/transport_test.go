@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// quietContext returns a context whose requests log nowhere.
+func quietContext() context.Context {
+	return withLogger(context.Background(), slog.New(slog.DiscardHandler))
+}
+
+// get makes a GET request to url through transport and reads the response.
+func get(t *testing.T, transport http.RoundTripper, url string) (*http.Response, string, error) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(quietContext(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	return res, string(body), err
+}
+
+// failFirst returns a server that responds with status to the first n
+// requests, then with "ok", and a count of the requests it has answered.
+func failFirst(t *testing.T, n int, status int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= int64(n) {
+			w.WriteHeader(status)
+			return
+		}
+
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+// recordPriorities is a layer that records the priority each request
+// passes through it with.
+func recordPriorities(mu *sync.Mutex, seen *[]requestPriority) middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			*seen = append(*seen, priorityFrom(req.Context()))
+			mu.Unlock()
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	layer := func(name string) middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	server, _ := failFirst(t, 0, 0)
+	if _, _, err := get(t, chain(http.DefaultTransport, layer("outer"), layer("middle"), layer("inner")), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"outer", "middle", "inner"}; !slices.Equal(order, want) {
+		t.Errorf("layers saw the request in the order %q, want %q", order, want)
+	}
+}
+
+func TestRetryingTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		rules        string
+		maxAttempts  int
+		wantStatus   int
+		wantRequests int64
+	}{
+		{name: "succeeds", maxAttempts: 3, wantStatus: http.StatusOK, wantRequests: 1},
+		{name: "retries 5xx", failures: 2, status: http.StatusServiceUnavailable, maxAttempts: 3, wantStatus: http.StatusOK, wantRequests: 3},
+		{name: "retries 429", failures: 1, status: http.StatusTooManyRequests, maxAttempts: 3, wantStatus: http.StatusOK, wantRequests: 2},
+		{name: "gives up", failures: 5, status: http.StatusBadGateway, maxAttempts: 3, wantStatus: http.StatusBadGateway, wantRequests: 3},
+		{name: "doesn't retry 404", failures: 1, status: http.StatusNotFound, maxAttempts: 3, wantStatus: http.StatusNotFound, wantRequests: 1},
+		{name: "follows rules", failures: 1, status: http.StatusNotFound, rules: "404=retry", maxAttempts: 3, wantStatus: http.StatusOK, wantRequests: 2},
+		{name: "rules can stop retries", failures: 1, status: http.StatusServiceUnavailable, rules: "503=fatal", maxAttempts: 3, wantStatus: http.StatusServiceUnavailable, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseStatusRules(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server, requests := failFirst(t, tt.failures, tt.status)
+			var metrics requestMetrics
+			transport := chain(http.DefaultTransport, withRetries(rules, retryPolicy{maxAttempts: tt.maxAttempts}), withMetrics(&metrics))
+
+			res, _, err := get(t, transport, server.URL)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+
+			if res.StatusCode != tt.wantStatus || requests.Load() != tt.wantRequests {
+				t.Errorf("RoundTrip() status, requests = %d, %d, want %d, %d", res.StatusCode, requests.Load(), tt.wantStatus, tt.wantRequests)
+			}
+
+			// Each attempt passes through the layers below the retries.
+			if metrics.Requests() != int(tt.wantRequests) {
+				t.Errorf("metrics counted %d requests, want %d", metrics.Requests(), tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryingTransportResendsBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	transport := chain(http.DefaultTransport, withRetries(nil, retryPolicy{maxAttempts: 2}))
+	req, err := http.NewRequestWithContext(quietContext(), http.MethodPost, server.URL, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+
+	res.Body.Close()
+
+	if want := []string{"payload", "payload"}; res.StatusCode != http.StatusOK || !slices.Equal(bodies, want) {
+		t.Errorf("RoundTrip() status = %d, server read %q, want 200 after reading %q", res.StatusCode, bodies, want)
+	}
+}
+
+func TestRetriesWaitBehindFirstAttempts(t *testing.T) {
+	var mu sync.Mutex
+	var seen []requestPriority
+
+	server, _ := failFirst(t, 2, http.StatusServiceUnavailable)
+	transport := chain(http.DefaultTransport, withRetries(nil, retryPolicy{maxAttempts: 3}), recordPriorities(&mu, &seen))
+	if _, _, err := get(t, transport, server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []requestPriority{priorityQuestion, priorityRetry, priorityRetry}; !slices.Equal(seen, want) {
+		t.Errorf("attempts reached the layers below with priorities %v, want %v", seen, want)
+	}
+}
+
+func TestTimeoutTransport(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			// The first attempt is never answered.
+			<-r.Context().Done()
+		case 2:
+			// The second is answered, but its body never finishes.
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	timeout := chain(http.DefaultTransport, withTimeout(50*time.Millisecond))
+	if _, _, err := get(t, timeout, server.URL); err == nil || err.Error() != "timed out after 50ms" {
+		t.Errorf("RoundTrip() error = %v, want a timeout", err)
+	}
+
+	if _, _, err := get(t, timeout, server.URL); err == nil || err.Error() != "timed out after 50ms" {
+		t.Errorf("reading the body error = %v, want a timeout", err)
+	}
+
+	// Under retries, each attempt has a time limit of its own.
+	requests.Store(0)
+	retried := chain(http.DefaultTransport, withRetries(nil, retryPolicy{maxAttempts: 3}), withTimeout(50*time.Millisecond))
+
+	req, err := http.NewRequestWithContext(quietContext(), http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := retried.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v, want the first attempt's timeout to be retried", err)
+	}
+
+	res.Body.Close()
+
+	// The second attempt was answered, so it was returned rather than
+	// retried, even though its body would have timed out.
+	if requests.Load() != 2 {
+		t.Errorf("server answered %d requests, want 2", requests.Load())
+	}
+}
+
+func TestTokenBucketPriority(t *testing.T) {
+	// The bucket starts empty and all but stops refilling until every
+	// request is waiting, so that none is let through as they arrive.
+	b := &tokenBucket{rate: 0.001, burst: 1, last: time.Now()}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	waiters := []struct {
+		name     string
+		priority requestPriority
+	}{
+		{"image", priorityImage},
+		{"retry", priorityRetry},
+		{"first question", priorityQuestion},
+		{"second retry", priorityRetry},
+		{"second question", priorityQuestion},
+	}
+
+	b.mu.Lock()
+	for i, w := range waiters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.acquire(context.Background(), w.priority); err != nil {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			order = append(order, w.name)
+			mu.Unlock()
+		}()
+
+		// Let the request join the queue before the next one, so they
+		// arrive in order.
+		b.mu.Unlock()
+		for {
+			b.mu.Lock()
+			if len(b.waiting) == i+1 {
+				break
+			}
+
+			b.mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Then it refills quickly, letting them through one at a time.
+	b.timer.Stop()
+	b.timer = nil
+	b.rate = 50
+	b.tokens = 1
+	b.last = time.Now()
+	b.grant()
+	b.mu.Unlock()
+	wg.Wait()
+
+	want := []string{"first question", "second question", "retry", "second retry", "image"}
+	if !slices.Equal(order, want) {
+		t.Errorf("requests were let through in the order %q, want %q", order, want)
+	}
+}
+
+func TestRateLimitPacesAttempts(t *testing.T) {
+	server, _ := failFirst(t, 2, http.StatusServiceUnavailable)
+	rate := 20.0
+	transport := chain(http.DefaultTransport, withRetries(nil, retryPolicy{maxAttempts: 3}), withRateLimit(rate, 1))
+
+	start := time.Now()
+	if _, _, err := get(t, transport, server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every attempt, not just the first, waits for a turn under the limit.
+	if elapsed, want := time.Since(start), 2*time.Duration(float64(time.Second)/rate); elapsed < want*9/10 {
+		t.Errorf("three attempts took %s, want at least %s", elapsed, want)
+	}
+}
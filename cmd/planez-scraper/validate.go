@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// stringsFlag is a flag that can be repeated to build a list of values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type validationError []string
+
+func (e validationError) Error() string {
+	return "invalid options:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// validator collects every problem with a set of options so they can be
+// reported together instead of failing on the first one.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) Check(ok bool, format string, args ...any) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) CheckErr(name string, err error) {
+	if err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s: %v", name, err))
+	}
+}
+
+func (v *validator) CheckDir(name string, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s: %s does not exist", name, path))
+	} else if !info.IsDir() {
+		v.problems = append(v.problems, fmt.Sprintf("%s: %s is not a directory", name, path))
+	}
+}
+
+func (v *validator) CheckFile(name string, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s: %s does not exist", name, path))
+	} else if info.IsDir() {
+		v.problems = append(v.problems, fmt.Sprintf("%s: %s is a directory", name, path))
+	}
+}
+
+// CheckParentDir reports a problem if the directory that path would be
+// created in does not exist.
+func (v *validator) CheckParentDir(name string, path string) {
+	if dir := filepath.Dir(path); dir != "." {
+		v.CheckDir(name, dir)
+	}
+}
+
+func (v *validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	return validationError(v.problems)
+}
+
+func checkBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http or https URL")
+	}
+
+	return nil
+}
+
+// hideFlags leaves the named flags out of the usage message for flags, for
+// options that are only meant for development.
+func hideFlags(flags *flag.FlagSet, names ...string) {
+	hidden := NewSet[string]()
+	for _, name := range names {
+		hidden.Add(name)
+	}
+
+	flags.Usage = func() {
+		visible := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+		visible.SetOutput(flags.Output())
+		flags.VisitAll(func(f *flag.Flag) {
+			if !hidden.Contains(f.Name) {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", flags.Name())
+		visible.PrintDefaults()
+	}
+}
+
+// commandNames returns the names of the commands, sorted.
+func commandNames() []string {
+	return slices.Sorted(maps.Keys(commands))
+}
+
+// suggest returns the option closest to input, or an empty string if
+// nothing is close enough to be a plausible typo.
+func suggest(input string, options []string) string {
+	best := ""
+	bestDistance := max(2, len(input)/3) + 1
+
+	for _, option := range options {
+		if d := editDistance(input, option); d < bestDistance {
+			best = option
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func didYouMean(input string, options []string) string {
+	if s := suggest(input, options); s != "" {
+		return fmt.Sprintf(" (did you mean %q?)", s)
+	}
+
+	return ""
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
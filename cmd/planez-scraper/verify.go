@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func runVerifyRemote(args []string) error {
+	flags := flag.NewFlagSet("verify-remote", flag.ExitOnError)
+	path := flags.String("questions", filepath.Join(activeWorkspace.DataDir(), "questions.json"), "Local questions file to verify")
+	n := flags.Int("n", 20, "Number of questions to sample")
+	seed := flags.Int64("seed", 0, "Seed for choosing the sample (default random)")
+	ascii := flags.Bool("ascii", false, "Normalize fetched text to ASCII before comparing, for data scraped with -ascii")
+	baseURL := flags.String("base-url", defaultBaseURL, "Base URL of the site to compare against")
+	rate := flags.Float64("rate", defaultRate, "Maximum requests per second to the site (0 for no limit)")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	v.Check(*n > 0, "-n: must be at least 1, got %d", *n)
+	v.CheckFile("-questions", *path)
+	v.CheckErr("-base-url", checkBaseURL(*baseURL))
+	v.Check(*rate >= 0, "-rate: must not be negative, got %g", *rate)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	local, err := readQuestions(*path)
+	if err != nil {
+		return err
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	rng.Shuffle(len(local), func(i, j int) { local[i], local[j] = local[j], local[i] })
+	sample := local[:min(*n, len(local))]
+
+	api := newSiteClient(*baseURL, *rate)
+	drifted := 0
+	for _, want := range sample {
+		got, err := scrape(context.Background(), api, NewSet[string](), want.QuestionID)
+		if err != nil {
+			fmt.Printf("%d: %v\n", want.QuestionID, err)
+			drifted++
+			continue
+		}
+
+		if *ascii {
+			got = normalizeASCII(got)
+		}
+
+		if changed := changedFields(want, got); len(changed) > 0 {
+			fmt.Printf("%d: changed %s\n", want.QuestionID, strings.Join(changed, ", "))
+			drifted++
+		}
+	}
+
+	fmt.Printf("Checked %d of %d questions (seed %d): %d drifted\n", len(sample), len(local), *seed, drifted)
+
+	if drifted > 0 {
+		return errors.New("local data has drifted from upstream")
+	}
+
+	return nil
+}
+
+// changedFields lists the upstream fields that differ between two versions
+// of the same question.
+func changedFields(a, b Question) []string {
+	var changed []string
+
+	if a.Question != b.Question {
+		changed = append(changed, "question")
+	}
+
+	if a.Answer != b.Answer {
+		changed = append(changed, "answer")
+	}
+
+	if a.Certificate != b.Certificate {
+		changed = append(changed, "certificate")
+	}
+
+	if a.Type != b.Type {
+		changed = append(changed, "type")
+	}
+
+	if a.CreatedDate != b.CreatedDate {
+		changed = append(changed, "createdDate")
+	}
+
+	if (a.ImageFile == nil) != (b.ImageFile == nil) || (a.ImageFile != nil && *a.ImageFile != *b.ImageFile) {
+		changed = append(changed, "imageFile")
+	}
+
+	return changed
+}
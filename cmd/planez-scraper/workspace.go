@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// profilesDir holds a workspace for each named profile, under both the data
+// and configuration roots.
+const profilesDir = "profiles"
+
+var profileNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// workspaceRoots are the directories the default workspace keeps its data
+// and its configuration in, and the directory caches shared by every
+// workspace are kept in.
+type workspaceRoots struct {
+	Data   string
+	Config string
+	Cache  string
+}
+
+// localRoots keeps everything in the current directory, as older versions
+// did, with caches out of the way in .cache.
+var localRoots = workspaceRoots{Data: ".", Config: ".", Cache: ".cache"}
+
+func xdgRoots() (workspaceRoots, error) {
+	data, err := dataHome()
+	if err != nil {
+		return workspaceRoots{}, err
+	}
+
+	config, err := configHome()
+	if err != nil {
+		return workspaceRoots{}, err
+	}
+
+	cache, err := cacheHome()
+	if err != nil {
+		return workspaceRoots{}, err
+	}
+
+	return workspaceRoots{Data: data, Config: config, Cache: cache}, nil
+}
+
+// roots is where workspaces live for this run, and localPaths is set when
+// they are in the current directory.
+var (
+	roots      = localRoots
+	localPaths = true
+)
+
+// workspace is one set of scraped data along with the state and
+// configuration that go with it. The default workspace is at the roots, and
+// each profile has its own under profilesDir.
+type workspace struct {
+	Profile   string
+	Dir       string
+	ConfigDir string
+}
+
+var activeWorkspace = defaultWorkspace()
+
+func defaultWorkspace() workspace {
+	return workspace{Dir: roots.Data, ConfigDir: roots.Config}
+}
+
+func profileWorkspace(profile string) (workspace, error) {
+	if !profileNamePattern.MatchString(profile) {
+		return workspace{}, fmt.Errorf("invalid profile name %q, use letters, digits, '.', '-', and '_'", profile)
+	}
+
+	return workspace{
+		Profile:   profile,
+		Dir:       filepath.Join(roots.Data, profilesDir, profile),
+		ConfigDir: filepath.Join(roots.Config, profilesDir, profile),
+	}, nil
+}
+
+func (w workspace) DataDir() string {
+	return filepath.Join(w.Dir, "data")
+}
+
+// CustomDir holds user-authored questions, which are kept apart from the
+// scraped data so that scraping never touches them.
+func (w workspace) CustomDir() string {
+	return filepath.Join(w.Dir, "custom")
+}
+
+// NotesPath returns the file holding personal notes on questions.
+func (w workspace) NotesPath() string {
+	return filepath.Join(w.Dir, "notes.json")
+}
+
+// ProgressPath returns the file holding the results of practice sessions.
+func (w workspace) ProgressPath() string {
+	return filepath.Join(w.Dir, "progress.json")
+}
+
+// StarsPath returns the file holding the IDs of starred questions.
+func (w workspace) StarsPath() string {
+	return filepath.Join(w.Dir, "stars.json")
+}
+
+// AssignmentsDir holds the assignments made with assignment create, one file
+// each.
+func (w workspace) AssignmentsDir() string {
+	return filepath.Join(w.Dir, "assignments")
+}
+
+// GroupDir holds the progress, notes, and stars of study partners, copied
+// down from the remote by sync.
+func (w workspace) GroupDir() string {
+	return filepath.Join(w.Dir, "group")
+}
+
+// SyncedDir holds the annotations as they were after the last sync, which
+// tell sync whether something missing on one side was added or removed.
+func (w workspace) SyncedDir() string {
+	return filepath.Join(w.Dir, "synced")
+}
+
+func (w workspace) HistoryPath() string {
+	return filepath.Join(w.Dir, historyFileName)
+}
+
+// StatePaths returns the files and directories holding the workspace's
+// scraped data and everything recorded alongside it, which a backup holds.
+// Debug captures are left out, being only of use to the run that saved them.
+func (w workspace) StatePaths() []string {
+	return []string{
+		w.DataDir(),
+		w.CustomDir(),
+		w.NotesPath(),
+		w.ProgressPath(),
+		w.StarsPath(),
+		w.AssignmentsDir(),
+		w.GroupDir(),
+		w.SyncedDir(),
+		w.HistoryPath(),
+	}
+}
+
+// FlagsPath returns the file holding the default flags for a command run in
+// the workspace, such as scrape.flags.
+func (w workspace) FlagsPath(command string) string {
+	return filepath.Join(w.ConfigDir, command+".flags")
+}
+
+// configuredArgs reads the default flags a profile sets for a command. Each
+// line of the file holds flags separated by spaces, which can be quoted to
+// include spaces, and lines starting with "#" are comments. The default
+// workspace only has configuration when it isn't in the current directory.
+func (w workspace) configuredArgs(command string) ([]string, error) {
+	if w.Profile == "" && localPaths {
+		return nil, nil
+	}
+
+	path := w.FlagsPath(command)
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	defer file.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields, err := splitQuoted(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+
+		args = append(args, fields...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return args, nil
+}
+
+// splitQuoted splits a line into fields separated by spaces. Single or
+// double quotes keep spaces within a field, and don't nest.
+func splitQuoted(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inField = r, true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
+
+// globalOptions apply to every command, and come before the command name.
+type globalOptions struct {
+	Profile   string
+	Local     bool
+	LogLevel  string
+	LogFormat string
+
+	// Config is the config file given with -config, if any.
+	Config string
+}
+
+// globalFlags are the names of the global options, which commands reject if
+// they are given after the command.
+var globalFlags = []string{"config", "local", "log-format", "log-level", "profile"}
+
+// parseGlobalArgs removes the global options from the start of args,
+// returning the remaining arguments.
+func parseGlobalArgs(args []string) (globalOptions, []string, error) {
+	opts := globalOptions{LogLevel: "info", LogFormat: "text"}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+
+		switch name {
+		case "local":
+			local, err := strconv.ParseBool(cmp.Or(value, "true"))
+			if err != nil {
+				return globalOptions{}, nil, fmt.Errorf("-local: invalid value %q", value)
+			}
+
+			opts.Local = local
+		case "profile":
+			if !hasValue && len(args) > 1 {
+				value = args[1]
+				args = args[1:]
+			}
+
+			if value == "" {
+				return globalOptions{}, nil, errors.New("-profile: missing profile name")
+			}
+
+			opts.Profile = value
+		case "config", "log-level", "log-format":
+			if !hasValue && len(args) > 1 {
+				value = args[1]
+				args = args[1:]
+			}
+
+			if value == "" {
+				return globalOptions{}, nil, fmt.Errorf("-%s: missing value", name)
+			}
+
+			switch name {
+			case "config":
+				opts.Config = value
+			case "log-level":
+				opts.LogLevel = value
+			default:
+				opts.LogFormat = value
+			}
+		default:
+			return opts, args, nil
+		}
+
+		args = args[1:]
+	}
+
+	return opts, args, nil
+}
+
+// checkLegacyData points out data left in the current directory by an older
+// version, which kept everything there.
+func checkLegacyData() {
+	legacy := filepath.Join(localRoots.Data, "data", "questions.json")
+	if _, err := os.Stat(legacy); err != nil {
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(activeWorkspace.DataDir(), "questions.json")); err == nil {
+		return
+	}
+
+	slog.Warn("Found data from an older version, pass -local to keep using it or move it to the data directory", "dir", filepath.Dir(legacy), "data_dir", activeWorkspace.DataDir())
+}
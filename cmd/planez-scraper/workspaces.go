@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var workspaceCommands = map[string]func(args []string) error{
+	"clean": runWorkspacesClean,
+	"list":  runWorkspacesList,
+	"path":  runWorkspacesPath,
+}
+
+func runWorkspaces(args []string) error {
+	names := make([]string, 0, len(workspaceCommands))
+	for name := range workspaceCommands {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: %s", strings.Join(names, ", "))
+	}
+
+	command, ok := workspaceCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q%s", args[0], didYouMean(args[0], names))
+	}
+
+	return command(args[1:])
+}
+
+// listWorkspaces returns the default workspace followed by every profile
+// with data or configuration, sorted by name.
+func listWorkspaces() ([]workspace, error) {
+	profiles := NewSet[string]()
+	for _, root := range []string{roots.Data, roots.Config} {
+		dir := filepath.Join(root, profilesDir)
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && profileNamePattern.MatchString(entry.Name()) {
+				profiles.Add(entry.Name())
+			}
+		}
+	}
+
+	names := profiles.Values()
+	slices.Sort(names)
+
+	workspaces := []workspace{defaultWorkspace()}
+	for _, name := range names {
+		ws, err := profileWorkspace(name)
+		if err != nil {
+			return nil, err
+		}
+
+		workspaces = append(workspaces, ws)
+	}
+
+	return workspaces, nil
+}
+
+// paths returns the files and directories the tool has created in the
+// workspace, leaving out its configuration.
+func (w workspace) paths() []string {
+	var paths []string
+	for _, p := range []string{w.DataDir(), w.HistoryPath()} {
+		if _, err := os.Stat(p); err == nil {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+func diskUsage(paths ...string) int64 {
+	var total int64
+	for _, p := range paths {
+		filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+
+			return nil
+		})
+	}
+
+	return total
+}
+
+func runWorkspacesList(args []string) error {
+	flags := flag.NewFlagSet("workspaces list", flag.ExitOnError)
+	all := flags.Bool("all", false, "Include workspaces with no data")
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() == 0, "unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	workspaces, err := listWorkspaces()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tPATH\tSIZE\tQUESTIONS\tLAST SCRAPED")
+
+	for _, ws := range workspaces {
+		paths := ws.paths()
+		if len(paths) == 0 && !*all {
+			continue
+		}
+
+		profile := ws.Profile
+		if profile == "" {
+			profile = "(default)"
+		}
+
+		count, scraped := "-", "-"
+		questionsPath := filepath.Join(ws.DataDir(), "questions.json")
+		if info, err := os.Stat(questionsPath); err == nil {
+			scraped = info.ModTime().UTC().Format(time.RFC3339)
+			if questions, err := readQuestions(questionsPath); err == nil {
+				count = fmt.Sprint(len(questions))
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", profile, ws.Dir, formatBytes(diskUsage(paths...)), count, scraped)
+	}
+
+	return w.Flush()
+}
+
+func runWorkspacesPath(args []string) error {
+	flags := flag.NewFlagSet("workspaces path", flag.ExitOnError)
+	config := flags.Bool("config", false, "Print the directory holding the workspace's flags files instead")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper workspaces path [PROFILE]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() <= 1, "expected at most one profile, got %d", flags.NArg())
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	ws := activeWorkspace
+	if flags.NArg() == 1 {
+		var err error
+		if ws, err = profileWorkspace(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	dir := ws.Dir
+	if *config {
+		dir = ws.ConfigDir
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(dir)
+
+	return nil
+}
+
+func runWorkspacesClean(args []string) error {
+	flags := flag.NewFlagSet("workspaces clean", flag.ExitOnError)
+	all := flags.Bool("all", false, "Remove the whole profile, including its configuration")
+	dryRun := flags.Bool("dry-run", false, "Print what would be removed without removing it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: planez-scraper workspaces clean [flags] PROFILE...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var v validator
+	v.Check(flags.NArg() > 0, "expected at least one profile to clean")
+	var workspaces []workspace
+	for _, profile := range flags.Args() {
+		ws, err := profileWorkspace(profile)
+		v.CheckErr(profile, err)
+		if err == nil {
+			_, dataErr := os.Stat(ws.Dir)
+			_, configErr := os.Stat(ws.ConfigDir)
+			v.Check(dataErr == nil || configErr == nil, "%s: no such profile", profile)
+		}
+		workspaces = append(workspaces, ws)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	for _, ws := range workspaces {
+		paths := ws.paths()
+		if *all {
+			paths = []string{ws.Dir}
+			if ws.ConfigDir != ws.Dir {
+				paths = append(paths, ws.ConfigDir)
+			}
+		}
+
+		for _, p := range paths {
+			size := formatBytes(diskUsage(p))
+			if *dryRun {
+				fmt.Printf("Would remove %s (%s)\n", p, size)
+				continue
+			}
+
+			if err := os.RemoveAll(p); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", p, err)
+			}
+
+			fmt.Printf("Removed %s (%s)\n", p, size)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// inPlaceWriters are the functions allowed to write files in place rather
+// than through replaceFile, and why doing so is safe.
+var inPlaceWriters = map[string]string{
+	"openManifest":       "the manifest is a log that is only appended to",
+	"extractTarball":     "restore extracts into a temporary directory",
+	"writeAssignmentPDF": "the LaTeX source is written to a temporary directory",
+	"runDoctor":          "the doctor writes to a temporary directory",
+}
+
+// TestWritesReplaceFiles checks that files are only written through
+// replaceFile and pendingFile, which never leave a file half written, apart
+// from the writers in inPlaceWriters.
+func TestWritesReplaceFiles(t *testing.T) {
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+
+			ast.Inspect(fn, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+
+				pkg, ok := sel.X.(*ast.Ident)
+				if !ok || pkg.Name != "os" || !slices.Contains([]string{"Create", "WriteFile", "OpenFile"}, sel.Sel.Name) {
+					return true
+				}
+
+				if _, ok := inPlaceWriters[fn.Name.Name]; !ok {
+					t.Errorf("%s: %s calls os.%s, write through replaceFile or pendingFile instead", fset.Position(call.Pos()), fn.Name.Name, sel.Sel.Name)
+				}
+
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const appName = "planez-scraper"
+
+// xdgDir returns the tool's directory under the XDG base directory named by
+// env, or under fallback in the home directory if env is unset. Relative
+// paths in env are ignored, as the specification requires.
+func xdgDir(env string, fallback string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %v", err)
+	}
+
+	return filepath.Join(home, fallback, appName), nil
+}
+
+func configHome() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+func dataHome() (string, error) {
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+func cacheHome() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
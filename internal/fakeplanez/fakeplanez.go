@@ -0,0 +1,284 @@
+// Package fakeplanez implements a stand-in for the planez API so the scraper
+// can be developed and measured without touching the real site.
+package fakeplanez
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options controls the latency and failures injected into responses.
+type Options struct {
+	// Latency is added to every response.
+	Latency time.Duration
+
+	// Jitter is the maximum additional random delay added to each response.
+	Jitter time.Duration
+
+	// ErrorRate is the fraction of requests, from 0 to 1, that fail with
+	// ErrorStatus instead of being served.
+	ErrorRate float64
+
+	// ErrorStatus is the status code used for injected failures. It defaults
+	// to 500.
+	ErrorStatus int
+
+	// Seed seeds the random source used for jitter and failures.
+	Seed int64
+}
+
+// Server serves questions at /api/question/{id} and images at
+// /images/{name}, mirroring the paths used by the real site.
+type Server struct {
+	opts Options
+
+	mu        sync.Mutex
+	rng       *rand.Rand
+	questions map[int][]byte
+	images    map[string][]byte
+}
+
+func New(opts Options) *Server {
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusInternalServerError
+	}
+
+	return &Server{
+		opts:      opts,
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		questions: make(map[int][]byte),
+		images:    make(map[string][]byte),
+	}
+}
+
+// Start serves s on a new httptest server listening on a random local port.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+func (s *Server) AddQuestion(id int, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.questions[id] = body
+}
+
+func (s *Server) AddImage(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.images[name] = data
+}
+
+// Len returns the number of questions being served.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.questions)
+}
+
+// LoadDir serves the questions.json and images written by a previous scrape
+// into dir. Fields the scraper adds to each question are stripped so the
+// responses look like the ones upstream sends.
+func (s *Server) LoadDir(dir string) error {
+	path := filepath.Join(dir, "questions.json")
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var questions []map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &questions); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+
+	for _, q := range questions {
+		var id int
+		if err := json.Unmarshal(q["questionId"], &id); err != nil {
+			return fmt.Errorf("question in %s has an invalid questionId: %v", path, err)
+		}
+
+		delete(q, "provenance")
+		delete(q, "warnings")
+
+		body, err := json.Marshal(q)
+		if err != nil {
+			return fmt.Errorf("failed to encode question %d: %v", id, err)
+		}
+
+		s.AddQuestion(id, body)
+	}
+
+	imageDir := filepath.Join(dir, "images")
+	entries, err := os.ReadDir(imageDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", imageDir, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(imageDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read image %s: %v", entry.Name(), err)
+		}
+
+		s.AddImage(entry.Name(), data)
+	}
+
+	// Images stored under the hash of their contents are served under the
+	// names questions reference them by, as recorded in images.json.
+	manifestPath := filepath.Join(dir, "images.json")
+	manifest, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %v", manifestPath, err)
+	}
+
+	var stored map[string]string
+	if err := json.Unmarshal(manifest, &stored); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", manifestPath, err)
+	}
+
+	for original, path := range stored {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			return fmt.Errorf("failed to read image %s: %v", path, err)
+		}
+
+		s.AddImage(original, data)
+	}
+
+	return nil
+}
+
+// AddSynthetic generates n questions with consecutive IDs starting at start.
+// Every tenth question references a generated image.
+func (s *Server) AddSynthetic(start int, n int) {
+	for i := 0; i < n; i++ {
+		id := start + i
+
+		var image *string
+		if i%10 == 0 {
+			name := fmt.Sprintf("synthetic-%d.png", id)
+			image = &name
+			s.AddImage(name, syntheticPNG)
+		}
+
+		body, _ := json.Marshal(map[string]any{
+			"answer":      fmt.Sprintf("Synthetic answer %d.", id),
+			"certificate": "PRIVATE",
+			"createdDate": 1577921928079 + int64(i),
+			"imageFile":   image,
+			"question":    fmt.Sprintf("Synthetic question %d?", id),
+			"questionId":  id,
+			"type":        "ALL",
+		})
+
+		s.AddQuestion(id, body)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	delay, fail := s.roll()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail {
+		http.Error(w, http.StatusText(s.opts.ErrorStatus), s.opts.ErrorStatus)
+		return
+	}
+
+	if rawID, ok := strings.CutPrefix(r.URL.Path, "/api/question/"); ok {
+		id, err := strconv.Atoi(rawID)
+		s.mu.Lock()
+		body, found := s.questions[id]
+		s.mu.Unlock()
+
+		if err != nil || !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		serveBody(w, r, body)
+		return
+	}
+
+	if name, ok := strings.CutPrefix(r.URL.Path, "/images/"); ok {
+		s.mu.Lock()
+		data, found := s.images[name]
+		s.mu.Unlock()
+
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		serveBody(w, r, data)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// serveBody writes body with an ETag of its hash, responding with 304 Not
+// Modified instead when the request's If-None-Match names that ETag, so that
+// conditional requests can be tried against the fake site.
+func serveBody(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if strings.TrimSpace(candidate) == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.Write(body)
+}
+
+// roll decides the delay for a request and whether it should fail.
+func (s *Server) roll() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		delay += time.Duration(s.rng.Int63n(int64(s.opts.Jitter)))
+	}
+
+	return delay, s.opts.ErrorRate > 0 && s.rng.Float64() < s.opts.ErrorRate
+}
+
+// syntheticPNG is a 1x1 transparent PNG.
+var syntheticPNG = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d,
+	0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4, 0x89, 0x00, 0x00, 0x00,
+	0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49,
+	0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
@@ -0,0 +1,190 @@
+package planez
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Client retrieves questions and images from a planez site.
+type Client struct {
+	// BaseURL is the site to retrieve from, without a trailing slash.
+	BaseURL string
+
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// UserAgent is sent with each request. If empty, DefaultUserAgent is
+	// sent.
+	UserAgent string
+}
+
+// NewClient returns a client for the site at baseURL that makes its requests
+// with httpClient, or http.DefaultClient if it's nil.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTPClient: httpClient}
+}
+
+// StatusError is returned when the site responds with an unexpected status
+// code, so that the failure can be handled according to its status.
+type StatusError struct {
+	What   string
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to retrieve %s: received status %d", e.What, e.Status)
+}
+
+// QuestionURL returns the API URL of a question.
+func (c *Client) QuestionURL(id int) string {
+	return c.BaseURL + "/api/question/" + strconv.Itoa(id)
+}
+
+// ImageURL returns the URL of an image referenced by a question's ImageFile.
+func (c *Client) ImageURL(name string) string {
+	return c.BaseURL + "/images/" + name
+}
+
+// get requests url, returning the response if it has a 200 status. Failures
+// to get a response at all wrap the error from the HTTP client.
+func (c *Client) get(ctx context.Context, url string, what string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %v", what, err)
+	}
+
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s: %w", what, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &StatusError{What: what, Status: res.StatusCode}
+	}
+
+	return res, nil
+}
+
+// Question retrieves the question with the given ID.
+//
+// A response that doesn't have the shape of a question, such as an HTML page,
+// a body that isn't JSON, or a question missing one of RequiredQuestionFields,
+// fails with a *ShapeError holding the response.
+func (c *Client) Question(ctx context.Context, id int) (Question, error) {
+	what := fmt.Sprintf("question %d", id)
+	res, err := c.get(ctx, c.QuestionURL(id), what)
+	if err != nil {
+		return Question{}, err
+	}
+
+	defer res.Body.Close()
+
+	body := bufio.NewReader(res.Body)
+	if err := checkJSONResponse(res, body, what); err != nil {
+		return Question{}, capturePage(err, res, body)
+	}
+
+	// The body is kept to check which fields it has, which decoding it
+	// doesn't tell apart from fields that are empty, and for the ShapeError
+	// of a response that isn't a question.
+	var raw bytes.Buffer
+	q, err := DecodeQuestion(io.TeeReader(body, &raw))
+	if err != nil {
+		if shapeErr := typeShapeError(err, what); shapeErr != nil {
+			return Question{}, capturePage(shapeErr, res, io.MultiReader(&raw, body))
+		}
+
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return Question{}, capturePage(&ShapeError{What: what, Reason: "response isn't JSON: " + err.Error()}, res, io.MultiReader(&raw, body))
+		}
+
+		return Question{}, fmt.Errorf("failed to retrieve question %d: failed to decode response body: %v", id, err)
+	}
+
+	if err := checkQuestionFields(raw.Bytes(), what); err != nil {
+		return Question{}, capturePage(err, res, &raw)
+	}
+
+	if q.QuestionID != id {
+		return Question{}, fmt.Errorf("failed to retrieve question %d: response is for question %d", id, q.QuestionID)
+	}
+
+	return q, nil
+}
+
+// Image is the body of an image being downloaded. It must be closed once it
+// has been read.
+type Image struct {
+	// Requested is the name the image was requested by.
+	Requested string
+
+	// Name is the name to store the image under, which is Requested with its
+	// extension corrected to match the image's contents.
+	Name string
+
+	// ContentType is the type sniffed from the start of the image.
+	ContentType string
+
+	body   *bufio.Reader
+	closer io.Closer
+}
+
+func (i *Image) Read(p []byte) (int, error) {
+	return i.body.Read(p)
+}
+
+func (i *Image) Close() error {
+	return i.closer.Close()
+}
+
+// Image starts downloading the image with the given name, as referenced by a
+// question's ImageFile.
+func (c *Client) Image(ctx context.Context, name string) (*Image, error) {
+	res, err := c.get(ctx, c.ImageURL(name), "image "+name)
+	if err != nil {
+		return nil, err
+	}
+
+	body := bufio.NewReader(res.Body)
+	head, err := body.Peek(512)
+	if err != nil && err != io.EOF {
+		res.Body.Close()
+		return nil, fmt.Errorf("failed to read image %s: %v", name, err)
+	}
+
+	contentType := http.DetectContentType(head)
+	if strings.HasPrefix(contentType, "text/html") {
+		defer res.Body.Close()
+		return nil, capturePage(&ShapeError{What: "image " + name, Reason: "received an HTML page instead of an image"}, res, body)
+	}
+
+	return &Image{
+		Requested:   name,
+		Name:        CorrectImageName(name, contentType),
+		ContentType: contentType,
+		body:        body,
+		closer:      res.Body,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package planez
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// newTestSite serves the routes of handlers, keyed by path, and 404 for
+// everything else.
+func newTestSite(t *testing.T, handlers map[string]http.HandlerFunc) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.HandleFunc(path, handler)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL+"/", server.Client())
+}
+
+// respond returns a handler that responds with status, contentType and body.
+// An empty contentType sends no Content-Type at all.
+func respond(status int, contentType string, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A nil header stops the server from sniffing one.
+		w.Header()["Content-Type"] = nil
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func TestClientQuestion(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		page    PageKind
+		reason  string
+		err     string
+	}{
+		{name: "question", handler: respond(http.StatusOK, "application/json", validQuestion)},
+		{name: "no content type", handler: respond(http.StatusOK, "", validQuestion)},
+		{name: "not found", handler: respond(http.StatusNotFound, "application/json", `{}`), status: http.StatusNotFound},
+		{name: "unavailable", handler: respond(http.StatusServiceUnavailable, "text/html", "<html>down</html>"), status: http.StatusServiceUnavailable},
+		{
+			name:    "maintenance page",
+			handler: respond(http.StatusOK, "text/html", "<!DOCTYPE html><html><body>Down for maintenance, we'll be right back.</body></html>"),
+			page:    PageMaintenance,
+			reason:  "received an HTML page instead of JSON",
+		},
+		{
+			name:    "wrong content type",
+			handler: respond(http.StatusOK, "text/plain", validQuestion),
+			reason:  `received content type "text/plain" instead of JSON`,
+		},
+		{
+			name:    "missing fields",
+			handler: respond(http.StatusOK, "application/json", `{"questionId":1000,"question":"What controls yaw?"}`),
+			reason:  "response is missing answer, certificate, type, createdDate, imageFile",
+		},
+		{
+			name:    "wrong type",
+			handler: respond(http.StatusOK, "application/json", `{"questionId":"1000"}`),
+			reason:  "field questionId is a string instead of a number",
+		},
+		{
+			name:    "another question",
+			handler: respond(http.StatusOK, "application/json", strings.Replace(validQuestion, "1000", "1001", 1)),
+			err:     "failed to retrieve question 1000: response is for question 1001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userAgent string
+			client := newTestSite(t, map[string]http.HandlerFunc{
+				"/api/question/1000": func(w http.ResponseWriter, r *http.Request) {
+					userAgent = r.UserAgent()
+					tt.handler(w, r)
+				},
+			})
+
+			q, err := client.Question(context.Background(), 1000)
+			if userAgent != DefaultUserAgent {
+				t.Errorf("request User-Agent = %q, want %q", userAgent, DefaultUserAgent)
+			}
+
+			var statusErr *StatusError
+			var shapeErr *ShapeError
+			switch {
+			case tt.status != 0:
+				if !errors.As(err, &statusErr) || statusErr.Status != tt.status {
+					t.Errorf("Question() error = %v, want a *StatusError with status %d", err, tt.status)
+				}
+			case tt.reason != "":
+				if !errors.As(err, &shapeErr) {
+					t.Fatalf("Question() error = %v, want a *ShapeError", err)
+				}
+
+				if !strings.HasPrefix(shapeErr.Reason, tt.reason) || shapeErr.Page != tt.page {
+					t.Errorf("Question() error reason, page = %q, %q, want %q, %q", shapeErr.Reason, shapeErr.Page, tt.reason, tt.page)
+				}
+
+				if len(shapeErr.Body) == 0 || !strings.HasSuffix(shapeErr.URL, "/api/question/1000") {
+					t.Errorf("Question() error body, URL = %q, %q, want the response", shapeErr.Body, shapeErr.URL)
+				}
+			case tt.err != "":
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("Question() error = %v, want %q", err, tt.err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Question() error = %v", err)
+				}
+
+				if q.QuestionID != 1000 || q.Answer != "Rudder" {
+					t.Errorf("Question() = %+v, want question 1000", q)
+				}
+			}
+		})
+	}
+}
+
+func TestClientQuestionCanceled(t *testing.T) {
+	client := newTestSite(t, map[string]http.HandlerFunc{
+		"/api/question/1000": func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.Question(ctx, 1000); !errors.Is(err, context.Canceled) {
+		t.Errorf("Question() error = %v, want one wrapping context.Canceled", err)
+	}
+}
+
+func TestClientImage(t *testing.T) {
+	client := newTestSite(t, map[string]http.HandlerFunc{
+		"/images/diagram.jpg": respond(http.StatusOK, "image/jpeg", pngHeader+"rest of the image"),
+		"/images/login.png":   respond(http.StatusOK, "text/html", "<html><form><input type=\"password\"></form></html>"),
+	})
+
+	img, err := client.Image(context.Background(), "diagram.jpg")
+	if err != nil {
+		t.Fatalf("Image() error = %v", err)
+	}
+
+	defer img.Close()
+
+	if img.Requested != "diagram.jpg" || img.Name != "diagram.png" || img.ContentType != "image/png" {
+		t.Errorf("Image() = %q, %q, %q, want diagram.jpg stored as a PNG", img.Requested, img.Name, img.ContentType)
+	}
+
+	body, err := io.ReadAll(img)
+	if err != nil || string(body) != pngHeader+"rest of the image" {
+		t.Errorf("ReadAll(image) = %q, %v, want the whole image", body, err)
+	}
+
+	var shapeErr *ShapeError
+	if _, err := client.Image(context.Background(), "login.png"); !errors.As(err, &shapeErr) || shapeErr.Page != PageLogin {
+		t.Errorf("Image() error = %v, want a *ShapeError for a login page", err)
+	}
+
+	var statusErr *StatusError
+	if _, err := client.Image(context.Background(), "missing.png"); !errors.As(err, &statusErr) || statusErr.Status != http.StatusNotFound {
+		t.Errorf("Image() error = %v, want a *StatusError with status 404", err)
+	}
+}
@@ -0,0 +1,89 @@
+package planez
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxQuestionSize bounds how much of a response body is read when decoding a
+// question. Real questions are a few kilobytes at most.
+const maxQuestionSize = 1 << 20
+
+// ErrQuestionTooLarge is returned when a response body is too large to be a
+// question.
+var ErrQuestionTooLarge = fmt.Errorf("response body exceeds %d bytes", maxQuestionSize)
+
+var (
+	// ErrEmptyQuestion is returned when a response body is empty.
+	ErrEmptyQuestion = errors.New("response body is empty")
+
+	// ErrNullQuestion is returned when a response body is a JSON null.
+	ErrNullQuestion = errors.New("response body is null")
+
+	// ErrTrailingData is returned when a question is followed by more data.
+	ErrTrailingData = errors.New("unexpected data after question")
+)
+
+// DecodeError is returned by DecodeQuestion for a body that isn't a
+// question. Err is one of the errors above, or the error from decoding the
+// JSON, such as a *json.SyntaxError or *json.UnmarshalTypeError.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeQuestion decodes a single question from an upstream response body,
+// rejecting bodies that are oversized, empty, or followed by trailing data.
+// Every error it returns is a *DecodeError, so the errors above are matched
+// with errors.Is rather than compared with ==.
+func DecodeQuestion(r io.Reader) (Question, error) {
+	q, err := decodeQuestion(r)
+	if err != nil {
+		return Question{}, &DecodeError{Err: err}
+	}
+
+	return q, nil
+}
+
+func decodeQuestion(r io.Reader) (Question, error) {
+	limited := &io.LimitedReader{R: r, N: maxQuestionSize + 1}
+	decoder := json.NewDecoder(limited)
+
+	var q *Question
+	if err := decoder.Decode(&q); err != nil {
+		if limited.N <= 0 {
+			return Question{}, ErrQuestionTooLarge
+		} else if err == io.EOF {
+			return Question{}, ErrEmptyQuestion
+		}
+
+		return Question{}, err
+	}
+
+	if q == nil {
+		return Question{}, ErrNullQuestion
+	}
+
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		if limited.N <= 0 {
+			return Question{}, ErrQuestionTooLarge
+		}
+
+		return Question{}, ErrTrailingData
+	}
+
+	if limited.N <= 0 {
+		return Question{}, ErrQuestionTooLarge
+	}
+
+	return *q, nil
+}
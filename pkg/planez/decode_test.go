@@ -0,0 +1,148 @@
+package planez
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const validQuestion = `{"answer":"Rudder","certificate":"PRIVATE","createdDate":1700000000000,"imageFile":null,"question":"What controls yaw?","questionId":1000,"type":"ALL"}`
+
+// oversizedQuestion is a question with a body one byte too large.
+var oversizedQuestion = func() string {
+	prefix, suffix := `{"question":"`, `"}`
+	return prefix + strings.Repeat("a", maxQuestionSize+1-len(prefix)-len(suffix)) + suffix + " "
+}()
+
+func TestDecodeQuestion(t *testing.T) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	tests := []struct {
+		name  string
+		body  string
+		is    error
+		as    any
+		wantQ bool
+	}{
+		{name: "valid", body: validQuestion, wantQ: true},
+		{name: "surrounding whitespace", body: "\n " + validQuestion + "\n", wantQ: true},
+		{name: "empty", body: "", is: ErrEmptyQuestion},
+		{name: "whitespace", body: " \n", is: ErrEmptyQuestion},
+		{name: "null", body: "null", is: ErrNullQuestion},
+		{name: "trailing object", body: validQuestion + "{}", is: ErrTrailingData},
+		{name: "trailing garbage", body: validQuestion + "<html>", is: ErrTrailingData},
+		{name: "oversized", body: oversizedQuestion, is: ErrQuestionTooLarge},
+		{name: "oversized garbage", body: strings.Repeat(" ", maxQuestionSize+1) + "{}", is: ErrQuestionTooLarge},
+		{name: "truncated", body: validQuestion[:40], is: io.ErrUnexpectedEOF},
+		{name: "html", body: "<html></html>", as: &syntaxErr},
+		{name: "string ID", body: `{"questionId":"1000"}`, as: &typeErr},
+		{name: "numeric image", body: `{"imageFile":3}`, as: &typeErr},
+		{name: "array", body: `[` + validQuestion + `]`, as: &typeErr},
+		{name: "number", body: `42`, as: &typeErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := DecodeQuestion(strings.NewReader(tt.body))
+			if tt.wantQ {
+				if err != nil {
+					t.Fatalf("DecodeQuestion() error = %v", err)
+				}
+
+				if q.QuestionID != 1000 || q.Answer != "Rudder" {
+					t.Errorf("DecodeQuestion() = %+v, want question 1000", q)
+				}
+
+				return
+			}
+
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("DecodeQuestion() error = %#v, want a *DecodeError", err)
+			}
+
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("DecodeQuestion() error = %v, want %v", err, tt.is)
+			}
+
+			if tt.as != nil && !errors.As(err, tt.as) {
+				t.Errorf("DecodeQuestion() error = %#v, want a %T", decodeErr.Err, tt.as)
+			}
+		})
+	}
+}
+
+func FuzzDecodeQuestion(f *testing.F) {
+	for _, seed := range []string{
+		validQuestion,
+		"",
+		"null",
+		validQuestion + "{}",
+		validQuestion + " null",
+		oversizedQuestion,
+		`{"questionId":"1000"}`,
+		`{"imageFile":3,"createdDate":"today"}`,
+		`{"certificate":["PRIVATE"],"type":{}}`,
+		`{"provenance":{"firstSeen":{"at":1}}}`,
+		`{"questionId":1,"QUESTIONID":2,"extra":{"a":[1,2]}}`,
+		`[]`,
+		`"question"`,
+		`{"question":"\ud800"}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		q, err := DecodeQuestion(bytes.NewReader(body))
+		if err != nil {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || decodeErr.Err == nil {
+				t.Fatalf("DecodeQuestion(%q) error = %#v, want a *DecodeError", body, err)
+			}
+
+			return
+		}
+
+		if len(body) > maxQuestionSize {
+			t.Fatalf("DecodeQuestion() accepted a body of %d bytes", len(body))
+		}
+
+		// A decoded question encodes to a question that decodes back to
+		// the same encoding, unless escaping made the encoding too large.
+		encoded, err := json.Marshal(q)
+		if err != nil {
+			t.Fatalf("Marshal(%+v) error = %v", q, err)
+		} else if len(encoded) > maxQuestionSize {
+			return
+		}
+
+		again, err := DecodeQuestion(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("DecodeQuestion(%s) error = %v", encoded, err)
+		}
+
+		reencoded, err := json.Marshal(again)
+		if err != nil {
+			t.Fatalf("Marshal(%+v) error = %v", again, err)
+		}
+
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("question encoded as %s, then %s", encoded, reencoded)
+		}
+	})
+}
+
+func BenchmarkDecodeQuestion(b *testing.B) {
+	body := []byte(validQuestion)
+	b.SetBytes(int64(len(body)))
+
+	for b.Loop() {
+		if _, err := DecodeQuestion(bytes.NewReader(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
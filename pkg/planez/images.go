@@ -0,0 +1,89 @@
+package planez
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var imageExtensions = map[string][]string{
+	"image/bmp":    {".bmp"},
+	"image/gif":    {".gif"},
+	"image/jpeg":   {".jpg", ".jpeg"},
+	"image/png":    {".png"},
+	"image/webp":   {".webp"},
+	"image/x-icon": {".ico"},
+}
+
+// CorrectImageName returns the name an image should be stored under given
+// its sniffed content type. Names whose extension already matches the
+// content, or whose content type isn't a recognized image, are unchanged.
+func CorrectImageName(name string, contentType string) string {
+	exts, ok := imageExtensions[contentType]
+	if !ok {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if slices.Contains(exts, strings.ToLower(ext)) {
+		return name
+	}
+
+	return strings.TrimSuffix(name, ext) + exts[0]
+}
+
+// ImageStore saves downloaded images to a directory.
+type ImageStore struct {
+	Dir string
+
+	// ContentAddressed stores each image under the SHA-256 of its contents,
+	// keeping its corrected extension, instead of its name. Images that are
+	// the same under different names are then stored once.
+	ContentAddressed bool
+}
+
+// Save writes an image to the store under its corrected name, or its hash if
+// the store is content addressed, returning the name it was stored as. The
+// image is written to a temporary file and renamed into place once complete,
+// so a failed download leaves any image already stored under the name as it
+// was.
+func (s ImageStore) Save(img *Image) (string, error) {
+	file, err := os.CreateTemp(s.Dir, "."+img.Name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Join(s.Dir, img.Name), err)
+	}
+
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), img); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write %s: %v", filepath.Join(s.Dir, img.Name), err)
+	}
+
+	name := img.Name
+	if s.ContentAddressed {
+		name = hex.EncodeToString(hash.Sum(nil)) + strings.ToLower(filepath.Ext(img.Name))
+	}
+
+	path := filepath.Join(s.Dir, name)
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,134 @@
+// Package planez retrieves questions and images from the planez oral exam
+// question bank, and writes them out in the layout used by planez-scraper.
+//
+// The package keeps a stable API so that other programs can use the site
+// without depending on the command line tool, which has none. See Version
+// for what a release may change. Retries, rate limiting, and the rest of
+// what the tool adds are left to callers.
+package planez
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the site questions are retrieved from unless a client is
+// given another.
+const DefaultBaseURL = "https://oral.planez.co"
+
+type Question struct {
+	Answer      string       `json:"answer"`
+	Certificate Certificate  `json:"certificate"`
+	CreatedDate int          `json:"createdDate"`
+	ImageFile   *string      `json:"imageFile"`
+	Question    string       `json:"question"`
+	QuestionID  int          `json:"questionId"`
+	Type        QuestionType `json:"type"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Extra holds fields of the upstream data that Question doesn't model,
+	// so that fields added upstream are kept when the question is written
+	// back out rather than dropped. Fields that programs such as
+	// planez-scraper add to the questions they store are kept the same way.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// questionJSON has the same fields as Question without its JSON methods, so
+// that they can encode and decode the modeled fields with the defaults.
+type questionJSON Question
+
+// questionJSONNames are the JSON names of the modeled fields.
+var questionJSONNames = func() []string {
+	var names []string
+	t := reflect.TypeFor[questionJSON]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}()
+
+func (q *Question) UnmarshalJSON(data []byte) error {
+	var modeled questionJSON
+	if err := json.Unmarshal(data, &modeled); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+
+	// Object keys match the modeled fields without regard to case, the
+	// same as when decoding them.
+	for name := range all {
+		if slices.ContainsFunc(questionJSONNames, func(known string) bool { return strings.EqualFold(name, known) }) {
+			delete(all, name)
+		}
+	}
+
+	modeled.Extra = nil
+	if len(all) > 0 {
+		modeled.Extra = all
+	}
+
+	*q = Question(modeled)
+	return nil
+}
+
+func (q Question) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(questionJSON(q))
+	if err != nil || len(q.Extra) == 0 {
+		return data, err
+	}
+
+	names := slices.Sorted(maps.Keys(q.Extra))
+
+	// The extra fields are appended to the object in name order, after the
+	// modeled ones.
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var value bytes.Buffer
+		if err := json.Compact(&value, q.Extra[name]); err != nil {
+			return nil, fmt.Errorf("invalid value for field %s: %v", name, err)
+		}
+
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value.Bytes())
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Provenance records when a question was first and most recently retrieved.
+type Provenance struct {
+	FirstSeen   RunRef `json:"firstSeen"`
+	LastFetched RunRef `json:"lastFetched"`
+}
+
+// RunRef identifies the run a question was retrieved in and the URL it was
+// retrieved from.
+type RunRef struct {
+	At     time.Time `json:"at"`
+	Source string    `json:"source"`
+}
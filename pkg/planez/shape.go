@@ -0,0 +1,199 @@
+package planez
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RequiredQuestionFields are the JSON fields every question from the API
+// has. imageFile is null for questions without an image, but is still
+// present.
+var RequiredQuestionFields = []string{"questionId", "question", "answer", "certificate", "type", "createdDate", "imageFile"}
+
+// ShapeError is returned when a response doesn't have the shape the API is
+// known to have, such as an HTML page where JSON was expected or a question
+// missing one of its fields. One of these usually means the API has
+// changed, rather than that a single request failed.
+type ShapeError struct {
+	What   string
+	Reason string
+
+	// Page is the kind of page received, when it isn't JSON and looks like
+	// one a site serves in place of its API.
+	Page PageKind
+
+	// URL is where the response came from, after any redirects.
+	URL string
+
+	// ContentType is the Content-Type of the response.
+	ContentType string
+
+	// Body is the body of the response, up to maxPageSize bytes, for looking
+	// at what was received instead.
+	Body []byte
+}
+
+// PageKind is a kind of page a site serves in place of its API, such as while
+// it is down for maintenance.
+type PageKind string
+
+const (
+	PageMaintenance PageKind = "maintenance"
+	PageCaptcha     PageKind = "captcha"
+	PageLogin       PageKind = "login"
+)
+
+// maxPageSize bounds how much of a response is kept in a ShapeError.
+const maxPageSize = 1 << 20
+
+// pageMarkers are the lowercased phrases that identify each kind of page, in
+// the order they are checked. A captcha page often mentions signing in, and
+// either can mention maintenance, so the more specific kinds go first.
+var pageMarkers = []struct {
+	kind    PageKind
+	phrases []string
+}{
+	{PageCaptcha, []string{"captcha", "cf-challenge", "challenge-platform", "verify you are human", "are you a robot"}},
+	{PageLogin, []string{`type="password"`, "type='password'", "sign in to continue", "log in to continue", "please log in", "please sign in"}},
+	{PageMaintenance, []string{"maintenance", "be right back", "temporarily unavailable", "down for scheduled", "back shortly"}},
+}
+
+// loginPaths are the parts of a path that a redirect to a login page has.
+var loginPaths = []string{"login", "signin", "sign-in", "sign_in", "auth"}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("failed to retrieve %s: %s", e.What, e.Reason)
+}
+
+// checkJSONResponse returns a ShapeError if a response, whose body is peeked
+// at, isn't JSON. A response without a Content-Type is judged by its body
+// alone.
+func checkJSONResponse(res *http.Response, body *bufio.Reader, what string) *ShapeError {
+	head, _ := body.Peek(512)
+	if strings.HasPrefix(http.DetectContentType(head), "text/html") {
+		return &ShapeError{What: what, Reason: "received an HTML page instead of JSON"}
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &ShapeError{What: what, Reason: fmt.Sprintf("received content type %q instead of JSON", contentType)}
+	}
+
+	return nil
+}
+
+// capturePage fills in where the response that err is about came from and
+// its body, which is read from body, and recognizes the kind of page it is
+// when it isn't JSON.
+func capturePage(err *ShapeError, res *http.Response, body io.Reader) *ShapeError {
+	err.URL = res.Request.URL.String()
+	err.ContentType = res.Header.Get("Content-Type")
+	err.Body, _ = io.ReadAll(io.LimitReader(body, maxPageSize))
+	if json.Valid(err.Body) {
+		return err
+	}
+
+	// A response to a redirected request records the redirect that led to
+	// it.
+	redirected := res.Request.Response != nil
+	if redirected && containsAny(strings.ToLower(res.Request.URL.Path), loginPaths) {
+		err.Page = PageLogin
+		err.Reason += " (redirected to a login page at " + err.URL + ")"
+		return err
+	}
+
+	text := strings.ToLower(string(err.Body))
+	for _, marker := range pageMarkers {
+		if containsAny(text, marker.phrases) {
+			err.Page = marker.kind
+			err.Reason += fmt.Sprintf(" (looks like a %s page)", marker.kind)
+			break
+		}
+	}
+
+	return err
+}
+
+// containsAny reports whether s contains any of substrs.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkQuestionFields returns a ShapeError naming the required fields that
+// the question encoded in data is missing. Names match without regard to
+// case, as when decoding.
+func checkQuestionFields(data []byte, what string) *ShapeError {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var missing []string
+	for _, required := range RequiredQuestionFields {
+		found := false
+		for name := range fields {
+			found = found || strings.EqualFold(name, required)
+		}
+
+		if !found {
+			missing = append(missing, required)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &ShapeError{What: what, Reason: "response is missing " + strings.Join(missing, ", ")}
+	}
+
+	return nil
+}
+
+// typeShapeError returns a ShapeError describing the JSON value of the wrong
+// type that err is about, or nil if it isn't about one.
+func typeShapeError(err error, what string) *ShapeError {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return nil
+	}
+
+	if typeErr.Field == "" {
+		return &ShapeError{What: what, Reason: fmt.Sprintf("response is a JSON %s instead of an object", typeErr.Value)}
+	}
+
+	return &ShapeError{What: what, Reason: fmt.Sprintf("field %s is a %s instead of a %s", typeErr.Field, typeErr.Value, jsonKind(typeErr.Type))}
+}
+
+// jsonKind names the kind of JSON value that decodes into t.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	}
+
+	return "number"
+}
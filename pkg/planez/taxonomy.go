@@ -0,0 +1,40 @@
+package planez
+
+import "slices"
+
+// Certificate is the pilot certificate a question is for.
+type Certificate string
+
+const (
+	CertificatePrivate    Certificate = "PRIVATE"
+	CertificateCommercial Certificate = "COMMERCIAL"
+)
+
+// Certificates are the certificates the site is known to use. Questions may
+// still have others if the site has added them since.
+var Certificates = []Certificate{CertificatePrivate, CertificateCommercial}
+
+// Known reports whether c is one of Certificates.
+func (c Certificate) Known() bool {
+	return slices.Contains(Certificates, c)
+}
+
+// QuestionType is the aircraft a question is specific to, or TypeAll for
+// questions that apply to any of them.
+type QuestionType string
+
+const (
+	TypeAll     QuestionType = "ALL"
+	TypeC152    QuestionType = "C152"
+	TypeC172    QuestionType = "C172"
+	TypeWarrior QuestionType = "WARRIOR"
+)
+
+// QuestionTypes are the types the site is known to use. Questions may still
+// have others if the site has added them since.
+var QuestionTypes = []QuestionType{TypeAll, TypeC152, TypeC172, TypeWarrior}
+
+// Known reports whether t is one of QuestionTypes.
+func (t QuestionType) Known() bool {
+	return slices.Contains(QuestionTypes, t)
+}
@@ -0,0 +1,15 @@
+package planez
+
+// Version is the version of this package's API. It follows semantic
+// versioning: a change to the minor version adds to the API, and only a
+// change to the major version removes or changes anything already in it.
+//
+// Releases of the module are tagged vX.Y.Z with this version, so the package
+// can be required at a version like any other, and from version 2 on the
+// module path ends in the major version, as Go requires. A release that only
+// changes the command line tool is a patch release.
+const Version = "2.0.0"
+
+// DefaultUserAgent is the User-Agent header sent by a client that doesn't set
+// its own.
+const DefaultUserAgent = "planez-go/" + Version
@@ -0,0 +1,27 @@
+package planez
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// QuestionWriter writes questions as the indented JSON array stored in
+// questions.json.
+type QuestionWriter struct {
+	encoder *json.Encoder
+}
+
+func NewQuestionWriter(w io.Writer) *QuestionWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return &QuestionWriter{encoder: encoder}
+}
+
+func (w *QuestionWriter) Write(questions []Question) error {
+	if questions == nil {
+		questions = []Question{}
+	}
+
+	return w.encoder.Encode(questions)
+}